@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"library-api-user/internal/config"
+	"os"
+)
+
+const configUsage = "usage: config print [--redacted]"
+
+// runConfig handles the config subcommand. print shows the configuration
+// even when it is invalid, followed by what is wrong with it.
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	redacted := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--redacted", "-redacted":
+			redacted = true
+		default:
+			return fmt.Errorf("unknown argument %q, %s", arg, configUsage)
+		}
+	}
+
+	if err := cfg.Print(os.Stdout, redacted); err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
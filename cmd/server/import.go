@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"library-api-user/internal/models"
+	"library-api-user/internal/repositories"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const importUsage = "usage: import activities FILE"
+
+// importBatchSize is the number of rows sent in one COPY.
+const importBatchSize = 5000
+
+// activityColumns is the header an activity import file must start with.
+var activityColumns = []string{"user_id", "book_id", "activity_type", "activity_timestamp"}
+
+// runImport handles the import subcommand. The whole file is loaded in one
+// transaction, so a bad row imports nothing.
+func runImport(db *sql.DB, args []string) error {
+	if len(args) != 2 || args[0] != "activities" {
+		return errors.New(importUsage)
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	imported, err := importActivities(ctx, tx, repositories.NewUserActivityRepository(), file)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("[Import] Imported %d activities\n", imported)
+	return nil
+}
+
+// importActivities copies the activities of a CSV file with an
+// activityColumns header, timestamps in RFC 3339.
+func importActivities(ctx context.Context, tx *sql.Tx, repository repositories.UserActivityRepository, r io.Reader) (int64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(activityColumns)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the header: %w", err)
+	}
+	if !slices.Equal(header, activityColumns) {
+		return 0, fmt.Errorf("header must be %v, got %v", activityColumns, header)
+	}
+
+	var imported int64
+	batch := make([]*models.UserActivity, 0, importBatchSize)
+	flush := func() error {
+		copied, err := repository.CreateActivities(ctx, tx, batch)
+		imported += copied
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		activity, err := parseActivity(record)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		batch = append(batch, activity)
+
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return 0, err
+		}
+	}
+	return imported, nil
+}
+
+func parseActivity(record []string) (*models.UserActivity, error) {
+	userID, err := strconv.ParseUint(record[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user_id %q", record[0])
+	}
+	bookID, err := strconv.ParseUint(record[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book_id %q", record[1])
+	}
+	switch record[2] {
+	case "borrowed", "returned", "search":
+	default:
+		return nil, fmt.Errorf("invalid activity_type %q", record[2])
+	}
+	timestamp, err := time.Parse(time.RFC3339, record[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid activity_timestamp %q", record[3])
+	}
+
+	return &models.UserActivity{
+		UserID:            userID,
+		BookID:            bookID,
+		ActivityType:      record[2],
+		ActivityTimestamp: timestamp,
+	}, nil
+}
@@ -1,67 +1,250 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"library-api-user/internal/config"
 	"library-api-user/internal/factory"
-	"library-api-user/internal/grpc/handlers"
+	"library-api-user/internal/grpc/interceptors"
+	"library-api-user/internal/lifecycle"
+	"library-api-user/internal/metrics"
 	"library-api-user/internal/routes"
+	"library-api-user/internal/tracing"
 	"library-api-user/pkg/database"
+	"library-api-user/pkg/token"
+	auditpb "library-api-user/proto/audit"
 	"library-api-user/proto/auth"
+	"library-api-user/proto/loan"
+	"library-api-user/proto/role"
+	"library-api-user/proto/user"
+	"library-api-user/proto/webhook"
 	"log"
 	"net"
-	"sync"
+	"net/http"
+	"os"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
-	config.LoadConfig()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("[Config] %v", err)
+	}
+	if len(args) > 0 && args[0] == "config" {
+		if err := runConfig(cfg, args[1:]); err != nil {
+			log.Fatalf("[Config] %v", err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("[Config] %v", err)
+	}
+	config.ENV = cfg
+
+	if config.ENV.TokenKey == "" {
+		// Only allowed in development: tokens stop validating on restart.
+		log.Println("[Token] TOKEN_KEY is not set, signing tokens with a random key")
+		key, err := randomKey()
+		if err != nil {
+			log.Fatalf("[Token] %v", err)
+		}
+		config.ENV.TokenKey = key
+	}
+	token.SetKey(config.ENV.TokenKey)
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatalf("[Tracing] %v", err)
+	}
+
 	psqlDB, err := database.NewPqSQLClient()
 	if err != nil {
 		log.Fatal("Could not connect to PqSQL:", err)
 	}
 
-	provider := factory.InitFactory(psqlDB)
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(psqlDB, args[1:]); err != nil {
+			log.Fatalf("[Migrate] %v", err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "import" {
+		if err := runImport(psqlDB, args[1:]); err != nil {
+			log.Fatalf("[Import] %v", err)
+		}
+		return
+	}
+	if config.ENV.AutoMigrate {
+		autoMigrate(psqlDB)
+	}
+
+	replicaDB, err := database.NewReplicaClient()
+	if err != nil {
+		log.Fatal("Could not connect to PqSQL replica:", err)
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	provider := factory.InitFactory(psqlDB, replicaDB)
 
-	go func() {
-		defer wg.Done()
-		runGRPCServer()
-	}()
+	manager := lifecycle.New(duration(config.ENV.ShutdownTimeout), duration(config.ENV.DrainDelay))
+	manager.OnShutdown(provider.HealthMonitor.Shutdown)
 
-	go func() {
-		defer wg.Done()
-		runHTTPServer(provider)
-	}()
+	manager.Go(provider.HealthMonitor.Run)
+	manager.Go(provider.OutboxRelay.Run)
+	manager.Go(provider.WebhookDispatcher.Run)
+	manager.Go(provider.OverdueScanner.Run)
+	manager.Go(provider.Eraser.Run)
+	manager.Go(provider.AuditRecorder.Run)
+
+	if err := provider.BookEvents.Subscribe(manager.Context(), provider.BookProjector.Handle); err != nil {
+		log.Fatal("Could not subscribe to book events:", err)
+	}
 
-	wg.Wait()
+	// The HTTP server stops first, so v2 requests still in flight can reach
+	// the gRPC server through the gateway.
+	httpServer := newHTTPServer(provider)
+	manager.Serve("REST server", func() error {
+		log.Printf("REST API server running on port %s\n", config.ENV.ServerPort)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, httpServer.Shutdown)
+
+	grpcServer := newGRPCServer(provider)
+	manager.Serve("gRPC server", func() error {
+		listener, err := net.Listen("tcp", ":"+config.ENV.GRPCPort)
+		if err != nil {
+			return fmt.Errorf("failed to listen on port %s: %w", config.ENV.GRPCPort, err)
+		}
+		log.Printf("gRPC server running on port %s\n", config.ENV.GRPCPort)
+		return grpcServer.Serve(listener)
+	}, func(ctx context.Context) error {
+		return gracefulStop(ctx, grpcServer)
+	})
+
+	// Metrics have a listener of their own, so that they are not exposed
+	// on the API port.
+	metricsServer := newMetricsServer()
+	manager.Serve("metrics server", func() error {
+		log.Printf("Metrics server running on port %s\n", config.ENV.MetricsPort)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}, metricsServer.Shutdown)
+
+	manager.Close("book events subscriber", provider.BookEvents.Close)
+	manager.Close("event publisher", provider.EventPublisher.Close)
+	manager.Close("book service connection", provider.BookClient.Close)
+	if replicaDB != nil {
+		manager.Close("replica database", replicaDB.Close)
+	}
+	manager.Close("database", psqlDB.Close)
+	manager.Close("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
+	if closer, ok := provider.Logger.(io.Closer); ok {
+		manager.Close("log file", closer.Close)
+	}
+
+	if err := manager.Run(); err != nil {
+		log.Fatalf("[Lifecycle] %v", err)
+	}
 }
 
-func runGRPCServer() {
-	listener, err := net.Listen("tcp", ":"+config.ENV.GRPCPort)
-	if err != nil {
-		log.Fatalf("Failed to listen on port %s: %v", config.ENV.GRPCPort, err)
+func newGRPCServer(provider *factory.Provider) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.MaxRecvMsgSize(provider.MaxMessageSize),
+		grpc.ChainUnaryInterceptor(
+			interceptors.RequestIDUnaryInterceptor(),
+			interceptors.MetricsUnaryInterceptor(map[string]*metrics.Operation{
+				auth.AuthService_Register_FullMethodName:   metrics.Registrations,
+				auth.AuthService_Login_FullMethodName:      metrics.Logins,
+				loan.LoanService_BorrowBook_FullMethodName: metrics.Borrows,
+				loan.LoanService_ReturnBook_FullMethodName: metrics.Returns,
+			}),
+			interceptors.ClientInfoUnaryInterceptor(),
+			interceptors.AuthUnaryInterceptor(provider.AccountChecker),
+		),
+	)
+
+	auth.RegisterAuthServiceServer(grpcServer, provider.AuthHandler)
+	user.RegisterUserServiceServer(grpcServer, provider.UserHandler)
+	user.RegisterProfileServiceServer(grpcServer, provider.ProfileHandler)
+	loan.RegisterLoanServiceServer(grpcServer, provider.LoanHandler)
+	webhook.RegisterWebhookServiceServer(grpcServer, provider.WebhookHandler)
+	role.RegisterRoleServiceServer(grpcServer, provider.RoleHandler)
+	auditpb.RegisterAuditServiceServer(grpcServer, provider.AuditHandler)
+	healthpb.RegisterHealthServer(grpcServer, provider.HealthMonitor.Server())
+
+	if config.ENV.GRPCReflection {
+		reflection.Register(grpcServer)
 	}
 
-	grpcServer := grpc.NewServer()
+	return grpcServer
+}
 
-	authHandler := handlers.NewAuthService()
-	auth.RegisterAuthServiceServer(grpcServer, authHandler)
+// gracefulStop stops the gRPC server once the calls in flight are done, or
+// cancels them when ctx is done first.
+func gracefulStop(ctx context.Context, grpcServer *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
 
-	log.Printf("gRPC server running on port %s\n", config.ENV.GRPCPort)
-	if err := grpcServer.Serve(listener); err != nil {
-		log.Fatalf("Failed to serve gRPC: %v", err)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		grpcServer.Stop()
+		return ctx.Err()
 	}
 }
 
-func runHTTPServer(provider *factory.Provider) {
+func newHTTPServer(provider *factory.Provider) *http.Server {
 	// Register HTTP routes
 	router := routes.RegisterRoutes(provider)
 
-	log.Printf("REST API server running on port %s\n", config.ENV.ServerPort)
-	if err := router.Run(":" + config.ENV.ServerPort); err != nil {
-		log.Fatalf("Failed to start REST server: %v", err)
+	return &http.Server{
+		Addr:    ":" + config.ENV.ServerPort,
+		Handler: router,
+	}
+}
+
+func newMetricsServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	return &http.Server{
+		Addr:    ":" + config.ENV.MetricsPort,
+		Handler: mux,
+	}
+}
+
+// duration parses a validated configuration duration.
+func duration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
+// randomKey returns a token signing key of 32 random bytes, hex encoded.
+func randomKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(key), nil
 }
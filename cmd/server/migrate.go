@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library-api-user/pkg/database/migration"
+	"log"
+	"strconv"
+)
+
+const migrateUsage = "usage: migrate up | down [N] | status | force VERSION"
+
+// runMigrate handles the migrate subcommand. down reverts one migration
+// unless told otherwise.
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migration.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("[Migrate] Applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("down takes a positive number of steps, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("[Migrate] Reverted %d migrations\n", reverted)
+	case "status":
+		state, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(migrator, state)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		log.Printf("[Migrate] Forced version %d\n", version)
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
+
+func printStatus(migrator *migration.Migrator, state *migration.State) {
+	fmt.Printf("version: %d", state.Version)
+	if state.Dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Println()
+
+	for _, m := range migrator.Migrations {
+		status := "pending"
+		if m.Version <= state.Version {
+			status = "applied"
+		}
+		fmt.Printf("%06d %-40s %s\n", m.Version, m.Name, status)
+	}
+}
+
+// autoMigrate brings the schema up to date before the service starts.
+// Replicas starting together queue on the migration lock.
+func autoMigrate(db *sql.DB) {
+	migrator, err := migration.New(db)
+	if err != nil {
+		log.Fatalf("[Migrate] Failed to load migrations: %v", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		log.Fatalf("[Migrate] Failed to migrate: %v", err)
+	}
+	log.Printf("[Migrate] Applied %d migrations\n", applied)
+}
@@ -0,0 +1,37 @@
+package audit
+
+import "context"
+
+type clientKey struct{}
+
+type actorKey struct{}
+
+// Client is the caller of a request as seen by the transport.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// Actor is the authenticated user making a request.
+type Actor struct {
+	ID   uint64
+	Role string
+}
+
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
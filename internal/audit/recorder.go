@@ -0,0 +1,327 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"library-api-user/internal/models"
+	"library-api-user/internal/repositories"
+	"reflect"
+	"time"
+)
+
+// Redacted replaces the value of secrets in recorded diffs, so that the log
+// shows that they changed without storing them.
+const Redacted = "[redacted]"
+
+const (
+	verifyBatchSize = 1000
+	appendBatchSize = 500
+	appendInterval  = time.Second
+)
+
+// Entry is what a service reports about an action. The actor, client IP
+// and user agent are taken from the context unless ActorID is set, which
+// is the case for logins where the caller is not authenticated yet.
+type Entry struct {
+	Action     string
+	ActorID    *uint64
+	ActorRole  string
+	TargetType string
+	TargetID   *uint64
+	Before     interface{}
+	After      interface{}
+}
+
+// Logger is the part of logger.Logger the recorder uses. The logger
+// package reads the actor from this one, so it cannot be imported here.
+type Logger interface {
+	Error(message string, fields map[string]interface{})
+}
+
+// Recorder appends events to the audit log. Every event stores the hash of
+// the event before it, and its own hash covers that link, so the log can be
+// verified end to end.
+//
+// Events are first queued in the transaction of the audited action, which
+// takes no lock, and then appended to the chain by Run in short
+// transactions of their own. Only those serialize on the chain.
+type Recorder struct {
+	DB                   *sql.DB
+	AuditEventRepository repositories.AuditEventRepository
+	Logger               Logger
+}
+
+func NewRecorder(db *sql.DB, auditEventRepository repositories.AuditEventRepository, log Logger) *Recorder {
+	return &Recorder{
+		DB:                   db,
+		AuditEventRepository: auditEventRepository,
+		Logger:               log,
+	}
+}
+
+// Record queues the entry inside the caller's transaction, so it is kept
+// only if the change it describes is committed. It reaches the audit log
+// within appendInterval of the commit.
+func (recorder *Recorder) Record(ctx context.Context, tx *sql.Tx, entry Entry) error {
+	event, err := newEvent(ctx, entry)
+	if err != nil {
+		return err
+	}
+	return recorder.AuditEventRepository.CreatePending(ctx, tx, event)
+}
+
+// RecordNow appends the entry in a transaction of its own. It is used for
+// reads and for failures, which have no transaction that commits.
+func (recorder *Recorder) RecordNow(ctx context.Context, entry Entry) (err error) {
+	tx, err := recorder.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return recorder.Record(ctx, tx, entry)
+}
+
+// Run appends the queued events to the chain every appendInterval until
+// ctx is done.
+func (recorder *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(appendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				appended, err := recorder.AppendPending(ctx)
+				if err != nil {
+					recorder.Logger.Error("[AuditRecorder] Failed to append pending events", map[string]interface{}{
+						"error": err.Error(),
+					})
+				}
+				if err != nil || appended < appendBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// AppendPending moves a batch of queued events onto the chain, in the
+// order they were queued, and returns how many it appended. The lock of
+// the chain is held only for this transaction.
+func (recorder *Recorder) AppendPending(ctx context.Context) (int, error) {
+	tx, err := recorder.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := recorder.AuditEventRepository.LockChain(ctx, tx); err != nil {
+		return 0, err
+	}
+	pending, err := recorder.AuditEventRepository.FindPending(ctx, tx, appendBatchSize)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	prevHash, err := recorder.AuditEventRepository.FindLastHash(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]uint64, len(pending))
+	for i, event := range pending {
+		ids[i] = event.ID
+		event.PrevHash = prevHash
+		if event.Hash, err = Hash(event); err != nil {
+			return 0, err
+		}
+		if err := recorder.AuditEventRepository.CreateEvent(ctx, tx, event); err != nil {
+			return 0, err
+		}
+		prevHash = event.Hash
+	}
+	if err := recorder.AuditEventRepository.DeletePending(ctx, tx, ids); err != nil {
+		return 0, err
+	}
+
+	return len(pending), tx.Commit()
+}
+
+// Verification is the outcome of walking the hash chain.
+type Verification struct {
+	Valid    bool    `json:"valid"`
+	Checked  int     `json:"checked"`
+	HeadID   uint64  `json:"head_id"`
+	HeadHash string  `json:"head_hash"`
+	BrokenAt *uint64 `json:"broken_at,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+// Verify recomputes every hash from the first event on. It reports the
+// first event whose link or content does not match. Removing events from
+// the end of the log cannot be detected from the log alone, so the head
+// hash should be compared with a copy kept elsewhere.
+func (recorder *Recorder) Verify(ctx context.Context) (*Verification, error) {
+	tx, err := recorder.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &Verification{Valid: true, HeadHash: models.AuditGenesisHash}
+	for {
+		events, err := recorder.AuditEventRepository.FindEventsAfter(ctx, tx, result.HeadID, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := result.check(events); err != nil {
+			return nil, err
+		}
+		if !result.Valid || len(events) < verifyBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// check continues the verification with the next events of the log, in ID
+// order, and stops at the first broken one.
+func (result *Verification) check(events []*models.AuditEvent) error {
+	for _, event := range events {
+		reason := ""
+		if event.PrevHash != result.HeadHash {
+			reason = "previous hash does not match the event before it"
+		} else if hash, err := Hash(event); err != nil {
+			return err
+		} else if hash != event.Hash {
+			reason = "hash does not match the event content"
+		}
+		if reason != "" {
+			id := event.ID
+			result.Valid = false
+			result.BrokenAt = &id
+			result.Reason = reason
+			return nil
+		}
+
+		result.Checked++
+		result.HeadID = event.ID
+		result.HeadHash = event.Hash
+	}
+	return nil
+}
+
+// hashedEvent fixes the fields and their order that go into an event hash.
+type hashedEvent struct {
+	OccurredAt string          `json:"occurred_at"`
+	ActorID    *uint64         `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   *uint64         `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+}
+
+// Hash returns the hex SHA-256 of the previous hash followed by the
+// canonical JSON of the event.
+func Hash(event *models.AuditEvent) (string, error) {
+	body, err := json.Marshal(hashedEvent{
+		OccurredAt: event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		ActorID:    event.ActorID,
+		ActorRole:  event.ActorRole,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Before:     event.Before,
+		After:      event.After,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(event.PrevHash))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// Diff keeps the fields whose value differs between before and after, so
+// that events only carry what changed.
+func Diff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			changedBefore[key] = before[key]
+			changedAfter[key] = value
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			changedBefore[key] = value
+			changedAfter[key] = nil
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+func newEvent(ctx context.Context, entry Entry) (*models.AuditEvent, error) {
+	before, err := marshalState(entry.Before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	after, err := marshalState(entry.After)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit state: %w", err)
+	}
+
+	client := ClientFromContext(ctx)
+	event := &models.AuditEvent{
+		// Stored at microsecond precision, so hash the value the database
+		// will give back.
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		ActorID:    entry.ActorID,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Before:     before,
+		After:      after,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+	}
+	if event.ActorID == nil {
+		if actor, ok := ActorFromContext(ctx); ok {
+			event.ActorID = &actor.ID
+			event.ActorRole = actor.Role
+		}
+	}
+	return event, nil
+}
+
+func marshalState(state interface{}) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	if value := reflect.ValueOf(state); value.Kind() == reflect.Map && value.Len() == 0 {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"encoding/json"
+	"library-api-user/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// chain builds n events linked the way AppendPending links them.
+func chain(t *testing.T, n int) []*models.AuditEvent {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 8, 0, 0, 123456000, time.UTC)
+	prevHash := models.AuditGenesisHash
+
+	events := make([]*models.AuditEvent, n)
+	for i := range events {
+		actorID := uint64(1)
+		targetID := uint64(i + 10)
+		event := &models.AuditEvent{
+			ID:         uint64(i + 1),
+			OccurredAt: start.Add(time.Duration(i) * time.Second),
+			ActorID:    &actorID,
+			ActorRole:  "admin",
+			Action:     models.AuditUserUpdate,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &targetID,
+			Before:     json.RawMessage(`{"name":"Old"}`),
+			After:      json.RawMessage(`{"name":"New"}`),
+			IP:         "10.0.0.1",
+			UserAgent:  "test",
+			PrevHash:   prevHash,
+		}
+		hash, err := Hash(event)
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		event.Hash = hash
+		prevHash = hash
+		events[i] = event
+	}
+	return events
+}
+
+func TestVerificationCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		tamper      func(events []*models.AuditEvent) []*models.AuditEvent
+		wantValid   bool
+		wantChecked int
+		wantBroken  uint64
+		wantReason  string
+	}{
+		{
+			name:        "intact",
+			tamper:      func(events []*models.AuditEvent) []*models.AuditEvent { return events },
+			wantValid:   true,
+			wantChecked: 4,
+		},
+		{
+			name:      "empty log",
+			tamper:    func([]*models.AuditEvent) []*models.AuditEvent { return nil },
+			wantValid: true,
+		},
+		{
+			name: "content changed",
+			tamper: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[2].After = json.RawMessage(`{"name":"Forged"}`)
+				return events
+			},
+			wantChecked: 2,
+			wantBroken:  3,
+			wantReason:  "hash does not match the event content",
+		},
+		{
+			name: "time changed",
+			tamper: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[0].OccurredAt = events[0].OccurredAt.Add(time.Microsecond)
+				return events
+			},
+			wantBroken: 1,
+			wantReason: "hash does not match the event content",
+		},
+		{
+			name: "event removed",
+			tamper: func(events []*models.AuditEvent) []*models.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			wantChecked: 1,
+			wantBroken:  3,
+			wantReason:  "previous hash does not match the event before it",
+		},
+		{
+			name: "event rehashed without its successors",
+			tamper: func(events []*models.AuditEvent) []*models.AuditEvent {
+				events[1].Action = models.AuditUserDelete
+				events[1].Hash, _ = Hash(events[1])
+				return events
+			},
+			wantChecked: 2,
+			wantBroken:  3,
+			wantReason:  "previous hash does not match the event before it",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := test.tamper(chain(t, 4))
+			result := &Verification{Valid: true, HeadHash: models.AuditGenesisHash}
+
+			// Split in two batches, the way Verify reads the log.
+			half := len(events) / 2
+			for _, batch := range [][]*models.AuditEvent{events[:half], events[half:]} {
+				if err := result.check(batch); err != nil {
+					t.Fatalf("check: %v", err)
+				}
+				if !result.Valid {
+					break
+				}
+			}
+
+			if result.Valid != test.wantValid || result.Checked != test.wantChecked || result.Reason != test.wantReason {
+				t.Errorf("result = %+v, want valid %v, %d checked, reason %q", result, test.wantValid, test.wantChecked, test.wantReason)
+			}
+			if test.wantBroken == 0 {
+				if result.BrokenAt != nil {
+					t.Errorf("broken at %d, want intact", *result.BrokenAt)
+				}
+			} else if result.BrokenAt == nil || *result.BrokenAt != test.wantBroken {
+				t.Errorf("broken at %v, want %d", result.BrokenAt, test.wantBroken)
+			}
+			if test.wantValid && len(events) > 0 && result.HeadHash != events[len(events)-1].Hash {
+				t.Errorf("head hash = %s, want the hash of the last event", result.HeadHash)
+			}
+		})
+	}
+}
+
+// The database gives occurred_at back in its own time zone; the hash must
+// not depend on it.
+func TestHashIgnoresTimeZone(t *testing.T) {
+	event := chain(t, 1)[0]
+	want := event.Hash
+
+	event.OccurredAt = event.OccurredAt.In(time.FixedZone("WIB", 7*60*60))
+	if got, err := Hash(event); err != nil || got != want {
+		t.Errorf("Hash in another zone = %s, %v, want %s", got, err, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     map[string]interface{}
+		after      map[string]interface{}
+		wantBefore map[string]interface{}
+		wantAfter  map[string]interface{}
+	}{
+		{
+			name:       "unchanged",
+			before:     map[string]interface{}{"name": "Reader", "role": "member"},
+			after:      map[string]interface{}{"name": "Reader", "role": "member"},
+			wantBefore: map[string]interface{}{},
+			wantAfter:  map[string]interface{}{},
+		},
+		{
+			name:       "changed field",
+			before:     map[string]interface{}{"name": "Reader", "role": "member"},
+			after:      map[string]interface{}{"name": "Reader", "role": "admin"},
+			wantBefore: map[string]interface{}{"role": "member"},
+			wantAfter:  map[string]interface{}{"role": "admin"},
+		},
+		{
+			name:       "added field",
+			before:     map[string]interface{}{},
+			after:      map[string]interface{}{"phone": "+62"},
+			wantBefore: map[string]interface{}{"phone": nil},
+			wantAfter:  map[string]interface{}{"phone": "+62"},
+		},
+		{
+			name:       "removed field",
+			before:     map[string]interface{}{"phone": "+62"},
+			after:      map[string]interface{}{},
+			wantBefore: map[string]interface{}{"phone": "+62"},
+			wantAfter:  map[string]interface{}{"phone": nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotBefore, gotAfter := Diff(test.before, test.after)
+			if !reflect.DeepEqual(gotBefore, test.wantBefore) || !reflect.DeepEqual(gotAfter, test.wantAfter) {
+				t.Errorf("Diff = %v, %v, want %v, %v", gotBefore, gotAfter, test.wantBefore, test.wantAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+// Cursor marks a position in a keyset ordered listing. Key names the
+// ordering the cursor was issued for, Values are the sort key values of the
+// row at the position, and Backward selects the rows before that row
+// instead of the rows after it.
+//
+// Cursors are opaque to clients but not signed: a forged cursor can only
+// move the position within rows the caller may list anyway.
+type Cursor struct {
+	Key      string   `json:"k"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func Encode(c Cursor) string {
+	body, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func Decode(value string) (*Cursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var c Cursor
+	if err := json.Unmarshal(body, &c); err != nil || c.Key == "" || len(c.Values) == 0 {
+		return nil, ErrInvalid
+	}
+	return &c, nil
+}
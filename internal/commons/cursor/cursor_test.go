@@ -0,0 +1,36 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	valid := Cursor{Key: "users:-created_at,id", Values: []string{"2024-01-01T00:00:00Z", "7"}, Backward: true}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    *Cursor
+		wantErr error
+	}{
+		{"round trip", Encode(valid), &valid, nil},
+		{"not base64", "not a cursor!", nil, ErrInvalid},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("users")), nil, ErrInvalid},
+		{"no key", Encode(Cursor{Values: []string{"7"}}), nil, ErrInvalid},
+		{"no values", Encode(Cursor{Key: "users:id"}), nil, ErrInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode(test.value)
+			if err != test.wantErr {
+				t.Fatalf("Decode error = %v, want %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Decode = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ type CustomError struct {
 	Status         bool        `json:"status"`
 	Message        string      `json:"message"`
 	AdditionalInfo interface{} `json:"additional_info,omitempty"`
+
+	cause error
 }
 
 var (
@@ -43,8 +45,63 @@ var (
 		Status:     false,
 		Message:    "BAD REQUEST ERROR",
 	}
+	serviceUnavailableError = CustomError{
+		Code:       "ERR0006",
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     false,
+		Message:    "SERVICE UNAVAILABLE",
+	}
+	forbiddenError = CustomError{
+		Code:       "ERR0007",
+		StatusCode: http.StatusForbidden,
+		Status:     false,
+		Message:    "FORBIDDEN",
+	}
+	conflictError = CustomError{
+		Code:       "ERR0008",
+		StatusCode: http.StatusConflict,
+		Status:     false,
+		Message:    "CONFLICT",
+	}
+	preconditionFailedError = CustomError{
+		Code:       "ERR0009",
+		StatusCode: http.StatusPreconditionFailed,
+		Status:     false,
+		Message:    "PRECONDITION FAILED",
+	}
+	payloadTooLargeError = CustomError{
+		Code:       "ERR0010",
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Status:     false,
+		Message:    "PAYLOAD TOO LARGE",
+	}
+	unsupportedMediaTypeError = CustomError{
+		Code:       "ERR0011",
+		StatusCode: http.StatusUnsupportedMediaType,
+		Status:     false,
+		Message:    "UNSUPPORTED MEDIA TYPE",
+	}
 )
 
+// Error lets a CustomError travel as an error, e.g. out of a transaction
+// function, and be recovered with errors.As.
+func (err *CustomError) Error() string {
+	return err.Message
+}
+
+// WithCause records the error that led to err, so that callers can still
+// inspect it with errors.Is and errors.As, e.g. to retry a transaction
+// that failed on a serialization conflict. The cause is never sent to
+// clients.
+func (err *CustomError) WithCause(cause error) *CustomError {
+	err.cause = cause
+	return err
+}
+
+func (err *CustomError) Unwrap() error {
+	return err.cause
+}
+
 func GeneralError(message ...string) *CustomError {
 	err := generalError
 	if len(message) != 0 {
@@ -127,3 +184,61 @@ func BadRequestErrorWithAdditionalInfo(info interface{}, message ...string) *Cus
 	}
 	return &err
 }
+
+func ServiceUnavailableErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	err := serviceUnavailableError
+	err.AdditionalInfo = info
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
+
+func ForbiddenError(message ...string) *CustomError {
+	err := forbiddenError
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
+
+func ForbiddenErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	err := forbiddenError
+	err.AdditionalInfo = info
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
+
+func ConflictError(message ...string) *CustomError {
+	err := conflictError
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
+
+func PreconditionFailedError(message ...string) *CustomError {
+	err := preconditionFailedError
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
+
+func PayloadTooLargeError(message ...string) *CustomError {
+	err := payloadTooLargeError
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
+
+func UnsupportedMediaTypeError(message ...string) *CustomError {
+	err := unsupportedMediaTypeError
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	return &err
+}
@@ -0,0 +1,23 @@
+package response
+
+import (
+	"fmt"
+	"library-api-user/pkg/database"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// A transaction function returns a CustomError; the Postgres error behind
+// it must stay visible, or WithinTx cannot tell a conflict to retry.
+func TestCustomErrorKeepsCause(t *testing.T) {
+	cause := fmt.Errorf("Failed to update a user. Reason: %w", &pgconn.PgError{Code: database.SerializationFailure})
+	var err error = GeneralError("Failed to update user: " + cause.Error()).WithCause(cause)
+
+	if code := database.Code(err); code != database.SerializationFailure {
+		t.Errorf("Code = %q, want %q", code, database.SerializationFailure)
+	}
+	if code := database.Code(GeneralError("Failed to update user")); code != "" {
+		t.Errorf("Code without cause = %q, want none", code)
+	}
+}
@@ -1,37 +1,70 @@
 package config
 
-import (
-	"github.com/spf13/viper"
-)
-
+// Config is the configuration of the service. Each field is read from the
+// key in its mapstructure tag; see Load for where keys come from. Fields
+// tagged secret are redacted by Print and can be read from a file named by
+// KEY_FILE.
 type Config struct {
-	DBHost         string `mapstructure:"DB_HOST"`
-	DBUserName     string `mapstructure:"DB_USERNAME"`
-	DBUserPassword string `mapstructure:"DB_PASSWORD"`
-	DBName         string `mapstructure:"DB_DATABASE"`
-	DBPort         string `mapstructure:"DB_PORT"`
-	ServerPort     string `mapstructure:"PORT"`
-	GRPCPort       string `mapstructure:"GRCP_PORT"`
-	BookGRCP       string `mapstructure:"BOOK_GRCP"`
-	Environtment   string `mapstructure:"ENVIRONTMENT"`
+	DBHost          string  `mapstructure:"DB_HOST" default:"localhost" validate:"required"`
+	DBUserName      string  `mapstructure:"DB_USERNAME" validate:"required"`
+	DBUserPassword  string  `mapstructure:"DB_PASSWORD" secret:"true"`
+	DBName          string  `mapstructure:"DB_DATABASE" default:"library" validate:"required"`
+	DBPort          string  `mapstructure:"DB_PORT" default:"5432" validate:"required,port"`
+	DBSSLMode       string  `mapstructure:"DB_SSLMODE" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	DBSSLRootCert   string  `mapstructure:"DB_SSLROOTCERT" validate:"omitempty,file"`
+	DBSSLCert       string  `mapstructure:"DB_SSLCERT" validate:"required_with=DBSSLKey,omitempty,file"`
+	DBSSLKey        string  `mapstructure:"DB_SSLKEY" validate:"required_with=DBSSLCert,omitempty,file"`
+	DBMaxOpenConns  int     `mapstructure:"DB_MAX_OPEN_CONNS" default:"20" validate:"min=1"`
+	DBMaxIdleConns  int     `mapstructure:"DB_MAX_IDLE_CONNS" default:"10" validate:"min=0,ltefield=DBMaxOpenConns"`
+	DBConnLifetime  string  `mapstructure:"DB_CONN_MAX_LIFETIME" default:"1h" validate:"duration"`
+	DBConnIdleTime  string  `mapstructure:"DB_CONN_MAX_IDLE_TIME" default:"10m" validate:"duration"`
+	DBStmtTimeout   string  `mapstructure:"DB_STATEMENT_TIMEOUT" default:"0" validate:"duration"`
+	DBAppName       string  `mapstructure:"DB_APPLICATION_NAME" default:"library-api-user" validate:"max=63"`
+	DBReplicaHost   string  `mapstructure:"DB_REPLICA_HOST"`
+	DBReplicaPort   string  `mapstructure:"DB_REPLICA_PORT" validate:"omitempty,port"`
+	DBReplicaMaxLag string  `mapstructure:"DB_REPLICA_MAX_LAG" default:"1s" validate:"duration"`
+	ServerPort      string  `mapstructure:"PORT" default:"8082" validate:"required,port"`
+	GRPCPort        string  `mapstructure:"GRPC_PORT" default:"50052" validate:"required,port"`
+	MetricsPort     string  `mapstructure:"METRICS_PORT" default:"9090" validate:"required,port"`
+	BookGRPC        string  `mapstructure:"BOOK_GRPC" validate:"required,hostname_port"`
+	Environment     string  `mapstructure:"ENVIRONMENT" default:"production" validate:"oneof=development staging production"`
+	GRPCReflection  bool    `mapstructure:"GRPC_REFLECTION" default:"false"`
+	TokenKey        string  `mapstructure:"TOKEN_KEY" secret:"true" validate:"required_unless=Environment development,omitempty,min=32"`
+	EventPublisher  string  `mapstructure:"EVENT_PUBLISHER" default:"memory" validate:"oneof=memory nats kafka"`
+	EventEncoding   string  `mapstructure:"EVENT_ENCODING" default:"json" validate:"oneof=json protobuf"`
+	NATSURL         string  `mapstructure:"NATS_URL" validate:"required_if=EventPublisher nats,required_if=BookEventsSub nats,omitempty,url"`
+	KafkaBrokers    string  `mapstructure:"KAFKA_BROKERS" validate:"required_if=EventPublisher kafka,required_if=BookEventsSub kafka"`
+	KafkaTopic      string  `mapstructure:"KAFKA_TOPIC" default:"library.user.events" validate:"required_if=EventPublisher kafka"`
+	BookEventsSub   string  `mapstructure:"BOOK_EVENTS_SUBSCRIBER" default:"memory" validate:"oneof=memory nats kafka"`
+	BookEventsSubj  string  `mapstructure:"BOOK_EVENTS_NATS_SUBJECT" default:"library.book.>" validate:"required_if=BookEventsSub nats"`
+	BookEventsTopic string  `mapstructure:"BOOK_EVENTS_KAFKA_TOPIC" default:"library.book.events" validate:"required_if=BookEventsSub kafka"`
+	KafkaGroupID    string  `mapstructure:"KAFKA_GROUP_ID" default:"library-api-user" validate:"required_if=BookEventsSub kafka"`
+	LoanPeriodDays  int     `mapstructure:"LOAN_PERIOD_DAYS" default:"14" validate:"min=1"`
+	ErasureGrace    int     `mapstructure:"ERASURE_GRACE_DAYS" default:"30" validate:"min=1"`
+	AvatarDir       string  `mapstructure:"AVATAR_DIR" default:"./var/avatars" validate:"required"`
+	AvatarMaxBytes  int64   `mapstructure:"AVATAR_MAX_BYTES" default:"2097152" validate:"min=1"`
+	AutoMigrate     bool    `mapstructure:"AUTO_MIGRATE" default:"false"`
+	ShutdownTimeout string  `mapstructure:"SHUTDOWN_TIMEOUT" default:"30s" validate:"duration"`
+	DrainDelay      string  `mapstructure:"SHUTDOWN_DRAIN_DELAY" default:"5s" validate:"duration"`
+	LogFile         string  `mapstructure:"LOG_FILE" default:"./var/log/user.log" validate:"required"`
+	LogMaxSizeMB    int     `mapstructure:"LOG_MAX_SIZE_MB" default:"100" validate:"min=1"`
+	LogMaxAgeDays   int     `mapstructure:"LOG_MAX_AGE_DAYS" default:"14" validate:"min=0"`
+	LogMaxBackups   int     `mapstructure:"LOG_MAX_BACKUPS" default:"10" validate:"min=0"`
+	LogRotateEvery  string  `mapstructure:"LOG_ROTATE_INTERVAL" default:"24h" validate:"duration"`
+	LogCompress     bool    `mapstructure:"LOG_COMPRESS" default:"true"`
+	TraceExporter   string  `mapstructure:"TRACE_EXPORTER" default:"none" validate:"oneof=none otlp stdout"`
+	TraceEndpoint   string  `mapstructure:"TRACE_OTLP_ENDPOINT" default:"localhost:4317" validate:"required_if=TraceExporter otlp,omitempty,hostname_port"`
+	TraceInsecure   bool    `mapstructure:"TRACE_OTLP_INSECURE" default:"false"`
+	TraceSampling   float64 `mapstructure:"TRACE_SAMPLE_RATIO" default:"1" validate:"min=0,max=1"`
 }
 
-var ENV *Config
-
-func LoadConfig() {
-	fang := viper.New()
-
-	fang.AddConfigPath(".")
-	fang.SetConfigName(".env")
-	fang.SetConfigType("env")
-
-	err := fang.ReadInConfig()
-	if err != nil {
-		panic(err)
-	}
-
-	err = fang.Unmarshal(&ENV)
-	if err != nil {
-		panic(err)
-	}
+// deprecatedKeys maps old, misspelled key names to the keys replacing
+// them. The old names are still read, with a warning.
+var deprecatedKeys = map[string]string{
+	"GRCP_PORT":    "GRPC_PORT",
+	"BOOK_GRCP":    "BOOK_GRPC",
+	"ENVIRONTMENT": "ENVIRONMENT",
 }
+
+// ENV is the configuration in use, set by main once it is valid.
+var ENV *Config
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const defaultFile = ".env"
+
+// layer is one source of configuration values, keyed like the
+// mapstructure tags of Config.
+type layer struct {
+	name   string
+	values map[string]string
+}
+
+// Load builds the configuration from, in increasing precedence: the
+// defaults in the Config tags, an optional env file, the environment and
+// command line flags. The file is ./.env unless -config or CONFIG_FILE
+// names another one, in which case it must exist. Every key has a flag
+// named after it, e.g. -db-host for DB_HOST. Load returns the arguments
+// left after the flags; it does not validate.
+func Load(args []string) (*Config, []string, error) {
+	keys := configKeys()
+
+	flags := flag.NewFlagSet("library-api-user", flag.ContinueOnError)
+	configFile := flags.String("config", "", "env file to read, ./.env by default")
+	flagValues := map[string]*string{}
+	for _, key := range keys {
+		flagValues[key] = flags.String(flagName(key), "", "overrides "+key)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = defaultFile, false
+	}
+	file, err := readFile(path, required)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmdline := layer{name: "flag", values: map[string]string{}}
+	flags.Visit(func(f *flag.Flag) {
+		for key, value := range flagValues {
+			if flagName(key) == f.Name {
+				cmdline.values[key] = *value
+			}
+		}
+	})
+
+	layers := []layer{defaults(), file, environment(keys), cmdline}
+	values, origins, err := merge(layers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := decode(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	origin = origins
+	return cfg, flags.Args(), nil
+}
+
+// origin records which layer each key was taken from, for Print.
+var origin map[string]string
+
+func configKeys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, t.NumField())
+	for i := range keys {
+		keys[i] = t.Field(i).Tag.Get("mapstructure")
+	}
+	return keys
+}
+
+func secretKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("secret") == "true" {
+			keys = append(keys, t.Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return keys
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+func defaults() layer {
+	values := map[string]string{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if value, ok := t.Field(i).Tag.Lookup("default"); ok {
+			values[t.Field(i).Tag.Get("mapstructure")] = value
+		}
+	}
+	return layer{name: "default", values: values}
+}
+
+func readFile(path string, required bool) (layer, error) {
+	file := layer{name: "file " + path, values: map[string]string{}}
+
+	fang := viper.New()
+	fang.SetConfigFile(path)
+	fang.SetConfigType("env")
+	if err := fang.ReadInConfig(); err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return file, nil
+		}
+		return file, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	for _, key := range fang.AllKeys() {
+		file.values[strings.ToUpper(key)] = fang.GetString(key)
+	}
+	return file, nil
+}
+
+// environment reads the known keys, their deprecated names and the _FILE
+// variants of secrets. Other variables are ignored.
+func environment(keys []string) layer {
+	names := append([]string{}, keys...)
+	for old := range deprecatedKeys {
+		names = append(names, old)
+	}
+	for _, key := range secretKeys() {
+		names = append(names, key+"_FILE")
+	}
+
+	env := layer{name: "env", values: map[string]string{}}
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env.values[name] = value
+		}
+	}
+	return env
+}
+
+// merge applies the layers in order. Deprecated names are renamed within
+// their layer, so a new name in a lower layer does not hide an old name in
+// a higher one. A secret is taken from the highest layer setting KEY or
+// KEY_FILE; one layer setting both is an error. Files are read last.
+func merge(layers []layer) (map[string]string, map[string]string, error) {
+	values := map[string]string{}
+	origins := map[string]string{}
+
+	for _, l := range layers {
+		for old, key := range deprecatedKeys {
+			value, ok := l.values[old]
+			if !ok {
+				continue
+			}
+			if _, both := l.values[key]; both {
+				log.Printf("[Config] %s (%s) is deprecated and ignored in favour of %s\n", old, l.name, key)
+				continue
+			}
+			log.Printf("[Config] %s (%s) is deprecated, use %s\n", old, l.name, key)
+			values[key], origins[key] = value, l.name
+		}
+		for key, value := range l.values {
+			if _, deprecated := deprecatedKeys[key]; deprecated {
+				continue
+			}
+			values[key], origins[key] = value, l.name
+		}
+		for _, key := range secretKeys() {
+			_, inline := l.values[key]
+			if path := l.values[key+"_FILE"]; path != "" {
+				if inline {
+					return nil, nil, fmt.Errorf("%s and %s_FILE are both set (%s), set only one", key, key, l.name)
+				}
+				continue
+			}
+			// A secret set directly overrides a file named by a lower layer.
+			if inline {
+				delete(values, key+"_FILE")
+				delete(origins, key+"_FILE")
+			}
+		}
+	}
+
+	for _, key := range secretKeys() {
+		path, ok := values[key+"_FILE"]
+		if !ok || path == "" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s_FILE: %w", key, err)
+		}
+		values[key] = strings.TrimRight(string(content), "\r\n")
+		origins[key] = "file " + path
+	}
+	return values, origins, nil
+}
+
+func decode(values map[string]string) (*Config, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fang := viper.New()
+	for _, key := range keys {
+		fang.Set(key, values[key])
+	}
+
+	var cfg Config
+	if err := fang.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &cfg, nil
+}
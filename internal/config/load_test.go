@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "token_key")
+	if err := os.WriteFile(keyFile, []byte("key-from-file\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name       string
+		layers     []layer
+		want       map[string]string
+		wantOrigin map[string]string
+		wantErr    string
+	}{
+		{
+			name: "higher layer wins",
+			layers: []layer{
+				{name: "default", values: map[string]string{"DB_HOST": "localhost", "DB_PORT": "5432"}},
+				{name: "env", values: map[string]string{"DB_HOST": "postgres"}},
+			},
+			want:       map[string]string{"DB_HOST": "postgres", "DB_PORT": "5432"},
+			wantOrigin: map[string]string{"DB_HOST": "env", "DB_PORT": "default"},
+		},
+		{
+			name: "deprecated name is renamed",
+			layers: []layer{
+				{name: "default", values: map[string]string{"GRPC_PORT": "50052"}},
+				{name: "env", values: map[string]string{"GRCP_PORT": "6000"}},
+			},
+			want:       map[string]string{"GRPC_PORT": "6000"},
+			wantOrigin: map[string]string{"GRPC_PORT": "env"},
+		},
+		{
+			name: "new name beats deprecated name in the same layer",
+			layers: []layer{
+				{name: "env", values: map[string]string{"GRCP_PORT": "6000", "GRPC_PORT": "7000"}},
+			},
+			want: map[string]string{"GRPC_PORT": "7000"},
+		},
+		{
+			name: "secret read from file",
+			layers: []layer{
+				{name: "env", values: map[string]string{"TOKEN_KEY_FILE": keyFile}},
+			},
+			want:       map[string]string{"TOKEN_KEY": "key-from-file"},
+			wantOrigin: map[string]string{"TOKEN_KEY": "file " + keyFile},
+		},
+		{
+			name: "file in a higher layer beats inline secret",
+			layers: []layer{
+				{name: "file .env", values: map[string]string{"TOKEN_KEY": "inline"}},
+				{name: "env", values: map[string]string{"TOKEN_KEY_FILE": keyFile}},
+			},
+			want: map[string]string{"TOKEN_KEY": "key-from-file"},
+		},
+		{
+			name: "inline secret in a higher layer beats file",
+			layers: []layer{
+				{name: "file .env", values: map[string]string{"TOKEN_KEY_FILE": missing}},
+				{name: "flag", values: map[string]string{"TOKEN_KEY": "inline"}},
+			},
+			want:       map[string]string{"TOKEN_KEY": "inline"},
+			wantOrigin: map[string]string{"TOKEN_KEY": "flag"},
+		},
+		{
+			name: "both forms in one layer",
+			layers: []layer{
+				{name: "env", values: map[string]string{"TOKEN_KEY": "inline", "TOKEN_KEY_FILE": keyFile}},
+			},
+			wantErr: "TOKEN_KEY and TOKEN_KEY_FILE are both set (env)",
+		},
+		{
+			name: "unreadable file",
+			layers: []layer{
+				{name: "env", values: map[string]string{"DB_PASSWORD_FILE": missing}},
+			},
+			wantErr: "failed to read DB_PASSWORD_FILE",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, origins, err := merge(test.layers)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("merge error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("merge: %v", err)
+			}
+			for key, want := range test.want {
+				if values[key] != want {
+					t.Errorf("%s = %q, want %q", key, values[key], want)
+				}
+			}
+			for key, want := range test.wantOrigin {
+				if origins[key] != want {
+					t.Errorf("origin of %s = %q, want %q", key, origins[key], want)
+				}
+			}
+		})
+	}
+}
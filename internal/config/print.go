@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+const redactedValue = "[redacted]"
+
+// Print writes the configuration as KEY=VALUE lines, each followed by the
+// source it came from. With redacted, secrets are masked.
+func (c *Config) Print(w io.Writer, redacted bool) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+
+		value := fmt.Sprint(v.Field(i).Interface())
+		if redacted && field.Tag.Get("secret") == "true" && value != "" {
+			value = redactedValue
+		}
+		source := origin[key]
+		if source == "" {
+			source = "unset"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s # %s\n", key, value, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validate checks every field and reports all problems at once, one per
+// key.
+func (c *Config) Validate() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+	validate.RegisterValidation("port", func(fl validator.FieldLevel) bool {
+		port, err := strconv.ParseUint(fl.Field().String(), 10, 16)
+		return err == nil && port != 0
+	})
+	validate.RegisterValidation("duration", func(fl validator.FieldLevel) bool {
+		duration, err := time.ParseDuration(fl.Field().String())
+		return err == nil && duration >= 0
+	})
+
+	var errs []error
+	if err := validate.Struct(c); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return err
+		}
+		for _, fieldErr := range fieldErrs {
+			errs = append(errs, fmt.Errorf("%s %s", fieldErr.Field(), describe(fieldErr)))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+}
+
+func describe(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		condition := strings.Fields(fieldErr.Param())
+		return fmt.Sprintf("is required when %s is %s", keyOf(condition[0]), condition[1])
+	case "required_unless":
+		condition := strings.Fields(fieldErr.Param())
+		return fmt.Sprintf("is required unless %s is %s", keyOf(condition[0]), condition[1])
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", keyOf(fieldErr.Param()))
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.ReplaceAll(fieldErr.Param(), " ", ", "), fieldErr.Value())
+	case "min":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s, got %v", fieldErr.Param(), fieldErr.Value())
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s, got %v", fieldErr.Param(), fieldErr.Value())
+	case "ltefield":
+		return fmt.Sprintf("must not be greater than %s", keyOf(fieldErr.Param()))
+	case "duration":
+		return fmt.Sprintf("must be a duration like 30s or 5m, got %q", fieldErr.Value())
+	case "file":
+		return fmt.Sprintf("must name an existing file, got %q", fieldErr.Value())
+	case "port":
+		return fmt.Sprintf("must be a port number, got %q", fieldErr.Value())
+	case "hostname_port":
+		return fmt.Sprintf("must be host:port, got %q", fieldErr.Value())
+	case "url":
+		return fmt.Sprintf("must be a URL, got %q", fieldErr.Value())
+	}
+	return fmt.Sprintf("failed validation with tag '%s'", fieldErr.Tag())
+}
+
+// keyOf returns the key of the Config field named field.
+func keyOf(field string) string {
+	if f, ok := reflect.TypeOf(Config{}).FieldByName(field); ok {
+		return f.Tag.Get("mapstructure")
+	}
+	return field
+}
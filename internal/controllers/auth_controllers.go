@@ -1,70 +0,0 @@
-package controllers
-
-import (
-	"library-api-user/internal/commons/response"
-	"library-api-user/internal/params"
-	"library-api-user/internal/services"
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-)
-
-type AuthController interface {
-	Register(ctx *gin.Context)
-	Login(ctx *gin.Context)
-}
-
-type AuthControllerImpl struct {
-	AuthService services.AuthService
-}
-
-func NewAuthController(authService services.AuthService) AuthController {
-	return &AuthControllerImpl{
-		AuthService: authService,
-	}
-}
-
-func (controller *AuthControllerImpl) Register(ctx *gin.Context) {
-	var req = new(params.RegisterRequest)
-
-	err := ctx.ShouldBindJSON(&req)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"status":  false,
-			"message": err,
-		})
-		return
-	}
-
-	custErr := controller.AuthService.Register(ctx, req)
-	if custErr != nil {
-		ctx.AbortWithStatusJSON(custErr.StatusCode, custErr)
-		return
-	}
-
-	resp := response.CreatedSuccess()
-	ctx.JSON(resp.StatusCode, resp)
-}
-
-func (controller *AuthControllerImpl) Login(ctx *gin.Context) {
-	var req = new(params.LoginRequest)
-
-	err := ctx.ShouldBindJSON(&req)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"status":  false,
-			"message": err,
-		})
-		return
-	}
-
-	result, custErr := controller.AuthService.Login(ctx, req)
-
-	if custErr != nil {
-		ctx.AbortWithStatusJSON(custErr.StatusCode, custErr)
-		return
-	}
-
-	resp := response.GeneralSuccessCustomMessageAndPayload("Success login user", result)
-	ctx.JSON(resp.StatusCode, resp)
-}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController interface {
+	Liveness(ctx *gin.Context)
+	Readiness(ctx *gin.Context)
+}
+
+type HealthControllerImpl struct {
+	Monitor *health.Monitor
+}
+
+func NewHealthController(monitor *health.Monitor) HealthController {
+	return &HealthControllerImpl{
+		Monitor: monitor,
+	}
+}
+
+func (controller *HealthControllerImpl) Liveness(ctx *gin.Context) {
+	resp := response.GeneralSuccessCustomMessageAndPayload("Service is alive", controller.Monitor.Statuses())
+	ctx.JSON(resp.StatusCode, resp)
+}
+
+func (controller *HealthControllerImpl) Readiness(ctx *gin.Context) {
+	statuses := controller.Monitor.Statuses()
+	if !controller.Monitor.Ready() {
+		custErr := response.ServiceUnavailableErrorWithAdditionalInfo(statuses, "Service is not ready")
+		ctx.AbortWithStatusJSON(custErr.StatusCode, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Service is ready", statuses)
+	ctx.JSON(resp.StatusCode, resp)
+}
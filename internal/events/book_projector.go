@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/repositories"
+	"time"
+)
+
+// BookProjector keeps the local read-only copy of the book catalog in sync
+// with the events published by the book service.
+type BookProjector struct {
+	DB                       *sql.DB
+	BookProjectionRepository repositories.BookProjectionRepository
+	Logger                   logger.Logger
+}
+
+func NewBookProjector(db *sql.DB, bookProjectionRepository repositories.BookProjectionRepository, log logger.Logger) *BookProjector {
+	return &BookProjector{
+		DB:                       db,
+		BookProjectionRepository: bookProjectionRepository,
+		Logger:                   log,
+	}
+}
+
+func (projector *BookProjector) Handle(ctx context.Context, event *Event) error {
+	err := projector.apply(ctx, event)
+	if err != nil {
+		projector.Logger.Error("[BookProjector] Failed to apply book event", map[string]interface{}{
+			"event_id":   event.ID,
+			"event_type": event.Type,
+			"error":      err.Error(),
+		})
+	}
+	return err
+}
+
+func (projector *BookProjector) apply(ctx context.Context, event *Event) error {
+	switch event.Type {
+	case BookCreated, BookUpdated, BookDeleted, StockChanged:
+	default:
+		return nil
+	}
+
+	tx, err := projector.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	fresh, err := projector.BookProjectionRepository.MarkEventProcessed(ctx, tx, event.ID, event.Type)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return nil
+	}
+
+	switch event.Type {
+	case BookCreated, BookUpdated:
+		var payload BookPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return err
+		}
+		err = projector.BookProjectionRepository.UpsertBook(ctx, tx, &models.BookProjection{
+			BookID:      payload.BookID,
+			Title:       payload.Title,
+			Author:      payload.Author,
+			Stock:       payload.Stock,
+			LastEventAt: event.OccurredAt,
+			UpdatedAt:   time.Now(),
+		})
+	case BookDeleted:
+		var payload BookDeletedPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return err
+		}
+		err = projector.BookProjectionRepository.MarkDeleted(ctx, tx, payload.BookID, event.OccurredAt)
+	case StockChanged:
+		var payload StockChangedPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return err
+		}
+		err = projector.BookProjectionRepository.UpdateStock(ctx, tx, payload.BookID, payload.Stock, event.OccurredAt)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	eventspb "library-api-user/proto/events"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+type Encoder interface {
+	ContentType() string
+	Encode(event *Event) ([]byte, error)
+}
+
+func NewEncoder(encoding string) (Encoder, error) {
+	switch encoding {
+	case "", EncodingJSON:
+		return &JSONEncoder{}, nil
+	case EncodingProtobuf:
+		return &ProtoEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown event encoding %q", encoding)
+}
+
+type JSONEncoder struct {
+}
+
+func (encoder *JSONEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (encoder *JSONEncoder) Encode(event *Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// ProtoEncoder encodes events as an events.Envelope whose payload is the
+// versioned message registered for the event type.
+type ProtoEncoder struct {
+}
+
+var protoPayloads = map[string]func() proto.Message{
+	schemaKey(UserRegistered, 1): func() proto.Message { return &eventspb.UserRegisteredV1{} },
+	schemaKey(UserUpdated, 1):    func() proto.Message { return &eventspb.UserUpdatedV1{} },
+	schemaKey(BookBorrowed, 1):   func() proto.Message { return &eventspb.BookBorrowedV1{} },
+	schemaKey(BookReturned, 1):   func() proto.Message { return &eventspb.BookReturnedV1{} },
+}
+
+func (encoder *ProtoEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (encoder *ProtoEncoder) Encode(event *Event) ([]byte, error) {
+	newPayload, ok := protoPayloads[schemaKey(event.Type, event.Version)]
+	if !ok {
+		return nil, fmt.Errorf("no protobuf schema for %s v%d", event.Type, event.Version)
+	}
+
+	payload := newPayload()
+	if err := protojson.Unmarshal(event.Payload, payload); err != nil {
+		return nil, fmt.Errorf("failed to convert %s payload: %w", event.Type, err)
+	}
+
+	packed, err := anypb.New(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&eventspb.Envelope{
+		Id:          event.ID,
+		Type:        event.Type,
+		Version:     uint32(event.Version),
+		OccurredAt:  timestamppb.New(event.OccurredAt),
+		AggregateId: event.AggregateID,
+		Payload:     packed,
+	})
+}
+
+func schemaKey(eventType string, version int) string {
+	return fmt.Sprintf("%s.v%d", eventType, version)
+}
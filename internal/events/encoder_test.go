@@ -0,0 +1,111 @@
+package events
+
+import (
+	"encoding/json"
+	eventspb "library-api-user/proto/events"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestProtoEncoderCarriesEveryField encodes each published event the way
+// the relay does and checks that no payload field is lost converting the
+// JSON stored in the outbox to its protobuf message.
+func TestProtoEncoderCarriesEveryField(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		eventType string
+		payload   interface{}
+		want      proto.Message
+	}{
+		{
+			eventType: UserRegistered,
+			payload:   UserRegisteredPayload{UserID: 7, Email: "reader@example.com", Name: "Reader", Role: "member"},
+			want:      &eventspb.UserRegisteredV1{UserId: 7, Email: "reader@example.com", Name: "Reader", Role: "member"},
+		},
+		{
+			eventType: UserUpdated,
+			payload:   UserUpdatedPayload{UserID: 7, Email: "reader@example.com", Name: "New Name", Role: "admin"},
+			want:      &eventspb.UserUpdatedV1{UserId: 7, Email: "reader@example.com", Name: "New Name", Role: "admin"},
+		},
+		{
+			eventType: BookBorrowed,
+			payload:   BookBorrowedPayload{UserID: 7, BookID: 3, BorrowedAt: at},
+			want:      &eventspb.BookBorrowedV1{UserId: 7, BookId: 3, BorrowedAt: timestamppb.New(at)},
+		},
+		{
+			eventType: BookReturned,
+			payload:   BookReturnedPayload{UserID: 7, BookID: 3, ReturnedAt: at},
+			want:      &eventspb.BookReturnedV1{UserId: 7, BookId: 3, ReturnedAt: timestamppb.New(at)},
+		},
+	}
+
+	encoder := &ProtoEncoder{}
+	for _, test := range tests {
+		t.Run(test.eventType, func(t *testing.T) {
+			outbox, err := NewOutboxEvent(test.eventType, 7, test.payload)
+			if err != nil {
+				t.Fatalf("NewOutboxEvent: %v", err)
+			}
+			event := FromOutbox(outbox)
+
+			body, err := encoder.Encode(event)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			var envelope eventspb.Envelope
+			if err := proto.Unmarshal(body, &envelope); err != nil {
+				t.Fatalf("failed to decode envelope: %v", err)
+			}
+			if envelope.Id != event.ID || envelope.Type != test.eventType || envelope.Version != SchemaVersion || envelope.AggregateId != 7 {
+				t.Errorf("envelope = %v, want event %s %s v%d of 7", &envelope, event.ID, test.eventType, SchemaVersion)
+			}
+			if !envelope.OccurredAt.AsTime().Equal(event.OccurredAt) {
+				t.Errorf("occurred at %v, want %v", envelope.OccurredAt.AsTime(), event.OccurredAt)
+			}
+
+			payload, err := envelope.Payload.UnmarshalNew()
+			if err != nil {
+				t.Fatalf("failed to decode payload: %v", err)
+			}
+			if !proto.Equal(payload, test.want) {
+				t.Errorf("payload = %v, want %v", payload, test.want)
+			}
+		})
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   *Event
+		wantErr bool
+	}{
+		{"known schema", &Event{Type: UserRegistered, Version: 1, Payload: json.RawMessage(`{"user_id":1}`)}, false},
+		{"unknown type", &Event{Type: "UserRenamed", Version: 1, Payload: json.RawMessage(`{}`)}, true},
+		{"unknown version", &Event{Type: UserRegistered, Version: 2, Payload: json.RawMessage(`{}`)}, true},
+		{"payload not matching the schema", &Event{Type: UserRegistered, Version: 1, Payload: json.RawMessage(`{"user_id":"seven"}`)}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := (&ProtoEncoder{}).Encode(test.event)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Encode error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+
+	for _, encoding := range []string{"", EncodingJSON, EncodingProtobuf} {
+		if _, err := NewEncoder(encoding); err != nil {
+			t.Errorf("NewEncoder(%q): %v", encoding, err)
+		}
+	}
+	if _, err := NewEncoder("avro"); err == nil {
+		t.Errorf("NewEncoder(avro) succeeded, want an error")
+	}
+}
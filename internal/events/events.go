@@ -0,0 +1,125 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"library-api-user/internal/models"
+	"time"
+)
+
+const (
+	UserRegistered = "UserRegistered"
+	UserUpdated    = "UserUpdated"
+	BookBorrowed   = "BookBorrowed"
+	BookReturned   = "BookReturned"
+
+	// Events consumed from the book service.
+	BookCreated  = "BookCreated"
+	BookUpdated  = "BookUpdated"
+	BookDeleted  = "BookDeleted"
+	StockChanged = "StockChanged"
+
+	// SchemaVersion is the version of the payload schemas below. Bump it and
+	// add new payload types when a payload changes incompatibly.
+	SchemaVersion = 1
+)
+
+// Event is the envelope handed to publishers. Payload holds the JSON encoded
+// payload of the versioned schema for Type.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Version     int             `json:"version"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	AggregateID uint64          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type UserRegisteredPayload struct {
+	UserID uint64 `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+type UserUpdatedPayload struct {
+	UserID uint64 `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+type BookBorrowedPayload struct {
+	UserID     uint64    `json:"user_id"`
+	BookID     uint64    `json:"book_id"`
+	BorrowedAt time.Time `json:"borrowed_at"`
+}
+
+type BookReturnedPayload struct {
+	UserID     uint64    `json:"user_id"`
+	BookID     uint64    `json:"book_id"`
+	ReturnedAt time.Time `json:"returned_at"`
+}
+
+type BookPayload struct {
+	BookID uint64 `json:"book_id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Stock  int32  `json:"stock"`
+}
+
+type BookDeletedPayload struct {
+	BookID uint64 `json:"book_id"`
+}
+
+type StockChangedPayload struct {
+	BookID uint64 `json:"book_id"`
+	Stock  int32  `json:"stock"`
+}
+
+// NewOutboxEvent builds the outbox row for an event so it can be written in
+// the same transaction as the change it describes.
+func NewOutboxEvent(eventType string, aggregateID uint64, payload interface{}) (*models.OutboxEvent, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutboxEvent{
+		EventID:       id,
+		EventType:     eventType,
+		SchemaVersion: SchemaVersion,
+		AggregateID:   aggregateID,
+		Payload:       body,
+		CreatedAt:     time.Now().UTC(),
+	}, nil
+}
+
+func FromOutbox(event *models.OutboxEvent) *Event {
+	return &Event{
+		ID:          event.EventID,
+		Type:        event.EventType,
+		Version:     event.SchemaVersion,
+		OccurredAt:  event.CreatedAt,
+		AggregateID: event.AggregateID,
+		Payload:     event.Payload,
+	}
+}
+
+// NewID returns a random RFC 4122 version 4 UUID.
+func NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate event id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
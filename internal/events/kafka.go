@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	defaultKafkaTopic     = "library.user.events"
+	defaultKafkaBookTopic = "library.book.events"
+	defaultKafkaGroupID   = "library-api-user"
+	kafkaHandleAttempts   = 3
+)
+
+type KafkaPublisher struct {
+	writer  *kafka.Writer
+	encoder Encoder
+}
+
+func NewKafkaPublisher(brokers []string, topic string, encoder Encoder) *KafkaPublisher {
+	if topic == "" {
+		topic = defaultKafkaTopic
+	}
+
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		encoder: encoder,
+	}
+}
+
+// Publish keys messages by aggregate so that events of the same user keep
+// their order within a partition.
+func (publisher *KafkaPublisher) Publish(ctx context.Context, event *Event) error {
+	body, err := publisher.encoder.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	return publisher.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.FormatUint(event.AggregateID, 10)),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "event_id", Value: []byte(event.ID)},
+			{Key: "event_type", Value: []byte(Subject(event))},
+			{Key: "content_type", Value: []byte(publisher.encoder.ContentType())},
+		},
+	})
+}
+
+func (publisher *KafkaPublisher) Close() error {
+	return publisher.writer.Close()
+}
+
+type KafkaSubscriber struct {
+	reader *kafka.Reader
+}
+
+func NewKafkaSubscriber(brokers []string, topic string, groupID string) *KafkaSubscriber {
+	if topic == "" {
+		topic = defaultKafkaBookTopic
+	}
+	if groupID == "" {
+		groupID = defaultKafkaGroupID
+	}
+
+	return &KafkaSubscriber{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: brokers,
+			Topic:   topic,
+			GroupID: groupID,
+		}),
+	}
+}
+
+// Subscribe commits an offset only after the handler succeeded or gave up
+// after a few attempts, so a crash mid-handling replays the message.
+func (subscriber *KafkaSubscriber) Subscribe(ctx context.Context, handler Handler) error {
+	go func() {
+		for {
+			msg, err := subscriber.reader.FetchMessage(ctx)
+			if err != nil {
+				return
+			}
+
+			if event, err := decodeEvent(msg.Value); err == nil {
+				for attempt := 0; attempt < kafkaHandleAttempts; attempt++ {
+					if err := handler(ctx, event); err == nil {
+						break
+					}
+				}
+			}
+
+			if err := subscriber.reader.CommitMessages(ctx, msg); err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (subscriber *KafkaSubscriber) Close() error {
+	return subscriber.reader.Close()
+}
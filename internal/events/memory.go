@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+type Handler func(ctx context.Context, event *Event) error
+
+// InMemoryPublisher delivers events synchronously to the handlers registered
+// in-process. It is the default when no broker is configured and acts as
+// both Publisher and Subscriber.
+type InMemoryPublisher struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewInMemoryPublisher() *InMemoryPublisher {
+	return &InMemoryPublisher{}
+}
+
+func (publisher *InMemoryPublisher) Subscribe(ctx context.Context, handler Handler) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	publisher.handlers = append(publisher.handlers, handler)
+	return nil
+}
+
+func (publisher *InMemoryPublisher) Publish(ctx context.Context, event *Event) error {
+	publisher.mu.RLock()
+	defer publisher.mu.RUnlock()
+
+	for _, handler := range publisher.handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (publisher *InMemoryPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	natsSubjectPrefix   = "library.user."
+	natsDefaultBookSubj = "library.book.>"
+	natsQueueGroup      = "library-api-user"
+)
+
+type NATSPublisher struct {
+	conn    *nats.Conn
+	encoder Encoder
+}
+
+func NewNATSPublisher(url string, encoder Encoder) (*NATSPublisher, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+
+	conn, err := nats.Connect(url, nats.Name("library-api-user"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSPublisher{
+		conn:    conn,
+		encoder: encoder,
+	}, nil
+}
+
+func (publisher *NATSPublisher) Publish(ctx context.Context, event *Event) error {
+	body, err := publisher.encoder.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(natsSubjectPrefix + Subject(event))
+	msg.Header.Set("Content-Type", publisher.encoder.ContentType())
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	msg.Data = body
+
+	if err := publisher.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return publisher.conn.FlushWithContext(ctx)
+}
+
+func (publisher *NATSPublisher) Close() error {
+	return publisher.conn.Drain()
+}
+
+// NATSSubscriber uses a queue group so that each event is handled by a
+// single replica of the service.
+type NATSSubscriber struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewNATSSubscriber(url string, subject string) (*NATSSubscriber, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	if subject == "" {
+		subject = natsDefaultBookSubj
+	}
+
+	conn, err := nats.Connect(url, nats.Name("library-api-user"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSSubscriber{
+		conn:    conn,
+		subject: subject,
+	}, nil
+}
+
+func (subscriber *NATSSubscriber) Subscribe(ctx context.Context, handler Handler) error {
+	_, err := subscriber.conn.QueueSubscribe(subscriber.subject, natsQueueGroup, func(msg *nats.Msg) {
+		event, err := decodeEvent(msg.Data)
+		if err != nil {
+			return
+		}
+		handler(ctx, event)
+	})
+	return err
+}
+
+func (subscriber *NATSSubscriber) Close() error {
+	return subscriber.conn.Drain()
+}
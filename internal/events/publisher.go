@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	PublisherMemory = "memory"
+	PublisherNATS   = "nats"
+	PublisherKafka  = "kafka"
+)
+
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+	Close() error
+}
+
+type PublisherConfig struct {
+	Driver       string
+	Encoding     string
+	NATSURL      string
+	KafkaBrokers string
+	KafkaTopic   string
+}
+
+func NewPublisher(cfg PublisherConfig) (Publisher, error) {
+	encoder, err := NewEncoder(cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Driver {
+	case "", PublisherMemory:
+		return NewInMemoryPublisher(), nil
+	case PublisherNATS:
+		return NewNATSPublisher(cfg.NATSURL, encoder)
+	case PublisherKafka:
+		return NewKafkaPublisher(strings.Split(cfg.KafkaBrokers, ","), cfg.KafkaTopic, encoder), nil
+	}
+	return nil, fmt.Errorf("unknown event publisher %q", cfg.Driver)
+}
+
+// Subject is the routing key of an event, e.g. "UserRegistered.v1".
+func Subject(event *Event) string {
+	return schemaKey(event.Type, event.Version)
+}
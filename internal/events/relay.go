@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/repositories"
+	"time"
+)
+
+const (
+	relayInterval  = 2 * time.Second
+	relayBatchSize = 100
+)
+
+// Relay publishes the events written to the outbox table. Events only reach
+// the outbox once the transaction that produced them has committed, so
+// nothing is published for changes that were rolled back.
+type Relay struct {
+	DB               *sql.DB
+	OutboxRepository repositories.OutboxRepository
+	Publisher        Publisher
+	Logger           logger.Logger
+}
+
+func NewRelay(db *sql.DB, outboxRepository repositories.OutboxRepository, publisher Publisher, log logger.Logger) *Relay {
+	return &Relay{
+		DB:               db,
+		OutboxRepository: outboxRepository,
+		Publisher:        publisher,
+		Logger:           log,
+	}
+}
+
+func (relay *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := relay.PublishPending(ctx); err != nil {
+				relay.Logger.Error("[EventRelay] Failed to publish pending events", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+func (relay *Relay) PublishPending(ctx context.Context) error {
+	tx, err := relay.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	pending, err := relay.OutboxRepository.FindUnpublished(ctx, tx, relayBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, outboxEvent := range pending {
+		event := FromOutbox(outboxEvent)
+		if err := relay.Publisher.Publish(ctx, event); err != nil {
+			relay.Logger.Warn("[EventRelay] Failed to publish event", map[string]interface{}{
+				"event_id":   event.ID,
+				"event_type": event.Type,
+				"error":      err.Error(),
+			})
+			if err := relay.OutboxRepository.MarkFailed(ctx, tx, outboxEvent.ID, err.Error()); err != nil {
+				return err
+			}
+			// Stop here so later events are not published ahead of this one.
+			break
+		}
+
+		if err := relay.OutboxRepository.MarkPublished(ctx, tx, outboxEvent.ID, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
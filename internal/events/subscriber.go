@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Subscriber delivers events published by other services to a handler.
+// Subscribe returns once the subscription is established; delivery happens
+// in the background until ctx is done or Close is called.
+type Subscriber interface {
+	Subscribe(ctx context.Context, handler Handler) error
+	Close() error
+}
+
+type SubscriberConfig struct {
+	Driver       string
+	NATSURL      string
+	NATSSubject  string
+	KafkaBrokers string
+	KafkaTopic   string
+	KafkaGroupID string
+}
+
+func NewSubscriber(cfg SubscriberConfig) (Subscriber, error) {
+	switch cfg.Driver {
+	case "", PublisherMemory:
+		return NewInMemoryPublisher(), nil
+	case PublisherNATS:
+		return NewNATSSubscriber(cfg.NATSURL, cfg.NATSSubject)
+	case PublisherKafka:
+		return NewKafkaSubscriber(strings.Split(cfg.KafkaBrokers, ","), cfg.KafkaTopic, cfg.KafkaGroupID), nil
+	}
+	return nil, fmt.Errorf("unknown event subscriber %q", cfg.Driver)
+}
+
+// decodeEvent reads the JSON envelope shared by the library services.
+func decodeEvent(body []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+	if event.ID == "" || event.Type == "" {
+		return nil, fmt.Errorf("event is missing id or type")
+	}
+	return &event, nil
+}
@@ -1,43 +1,191 @@
 package factory
 
 import (
+	"context"
 	"database/sql"
+	"library-api-user/internal/audit"
 	"library-api-user/internal/config"
 	"library-api-user/internal/controllers"
+	"library-api-user/internal/events"
+	"library-api-user/internal/gateway"
 	"library-api-user/internal/grpc/client"
+	"library-api-user/internal/grpc/handlers"
+	"library-api-user/internal/grpc/interceptors"
+	"library-api-user/internal/health"
 	"library-api-user/internal/logger"
+	"library-api-user/internal/metrics"
+	"library-api-user/internal/middleware"
+	"library-api-user/internal/privacy"
 	"library-api-user/internal/repositories"
 	"library-api-user/internal/services"
+	"library-api-user/internal/storage"
+	"library-api-user/internal/webhooks"
+	"library-api-user/pkg/database"
 	"log"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
 )
 
 type Provider struct {
-	AuthProvider controllers.AuthController
-	UserProvider controllers.UserController
+	HealthProvider controllers.HealthController
+	HealthMonitor  *health.Monitor
+	BookClient     *client.BookClient
+	Auth           *middleware.Auth
+	AccountChecker middleware.AccountChecker
+	Logger         logger.Logger
+
+	AuthHandler    *handlers.AuthService
+	UserHandler    *handlers.UserService
+	ProfileHandler *handlers.ProfileService
+	LoanHandler    *handlers.LoanService
+	WebhookHandler *handlers.WebhookService
+	RoleHandler    *handlers.RoleService
+	AuditHandler   *handlers.AuditService
+	Gateway        http.Handler
+	LegacyGateway  http.Handler
+	// MaxMessageSize is the largest gRPC message, large enough for an
+	// avatar sent through the gateway.
+	MaxMessageSize int
+
+	OutboxRelay    *events.Relay
+	EventPublisher events.Publisher
+	BookEvents     events.Subscriber
+	BookProjector  *events.BookProjector
+
+	WebhookDispatcher *webhooks.Dispatcher
+	OverdueScanner    *webhooks.OverdueScanner
+
+	Eraser        *privacy.Eraser
+	AuditRecorder *audit.Recorder
 }
 
-func InitFactory(db *sql.DB) *Provider {
+const (
+	// defaultMaxMessageSize is the default limit of gRPC messages.
+	defaultMaxMessageSize = 4 << 20
+	// avatarMessageOverhead leaves room for the other fields of a message
+	// carrying an avatar.
+	avatarMessageOverhead = 64 << 10
+)
+
+func InitFactory(db *sql.DB, replica *sql.DB) *Provider {
 
-	newLog, err := logger.NewLogger("./var/log/user.log")
+	newLog, err := logger.NewLogger(config.ENV.LogFile)
 	if err != nil {
 		log.Fatalf("[Logger] Failed to initialize user service logger: %v", err)
 	}
-	bookClient, err := client.NewBookClient(config.ENV.BookGRCP)
+	metrics.RegisterDB("primary", db)
+	if replica != nil {
+		metrics.RegisterDB("replica", replica)
+	}
+
+	bookClient, err := client.NewBookClient(config.ENV.BookGRPC,
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(interceptors.RequestIDUnaryClientInterceptor(), interceptors.MetricsUnaryClientInterceptor()),
+	)
 	if err != nil {
 		log.Fatalf("Failed to connect to BookService: %v", err)
 	}
 	userRepo := repositories.NewUserRepository()
+	roleRepo := repositories.NewRoleRepository()
+	adminActionRepo := repositories.NewUserAdminActionRepository()
 	borrowRepo := repositories.NewBorrowRepository()
 	activityRepo := repositories.NewUserActivityRepository()
+	outboxRepo := repositories.NewOutboxRepository()
+	bookProjectionRepo := repositories.NewBookProjectionRepository()
+	webhookRepo := repositories.NewWebhookRepository()
+	webhookEnqueuer := webhooks.NewEnqueuer(webhookRepo)
+	auditEventRepo := repositories.NewAuditEventRepository()
+	auditRecorder := audit.NewRecorder(db, auditEventRepo, newLog)
+	profileRepo := repositories.NewProfileRepository()
+
+	avatarDir := config.ENV.AvatarDir
+	if avatarDir == "" {
+		avatarDir = "./var/avatars"
+	}
+	blobStore, err := storage.NewLocalBlobStore(avatarDir)
+	if err != nil {
+		log.Fatalf("Failed to initialize avatar storage: %v", err)
+	}
+
+	publisher, err := events.NewPublisher(events.PublisherConfig{
+		Driver:       config.ENV.EventPublisher,
+		Encoding:     config.ENV.EventEncoding,
+		NATSURL:      config.ENV.NATSURL,
+		KafkaBrokers: config.ENV.KafkaBrokers,
+		KafkaTopic:   config.ENV.KafkaTopic,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize event publisher: %v", err)
+	}
+
+	bookEvents, err := events.NewSubscriber(events.SubscriberConfig{
+		Driver:       config.ENV.BookEventsSub,
+		NATSURL:      config.ENV.NATSURL,
+		NATSSubject:  config.ENV.BookEventsSubj,
+		KafkaBrokers: config.ENV.KafkaBrokers,
+		KafkaTopic:   config.ENV.BookEventsTopic,
+		KafkaGroupID: config.ENV.KafkaGroupID,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize book event subscriber: %v", err)
+	}
 
-	authSvc := services.NewAuthService(db, userRepo, newLog)
-	authController := controllers.NewAuthController(authSvc)
+	authSvc := services.NewAuthService(db, userRepo, roleRepo, outboxRepo, webhookEnqueuer, auditRecorder, newLog)
+	userSvc := services.NewUserService(db, database.NewReadPool(db, replica), bookClient, userRepo, roleRepo, adminActionRepo, borrowRepo, activityRepo, outboxRepo, bookProjectionRepo, profileRepo, blobStore, webhookEnqueuer, auditRecorder, newLog)
+	webhookSvc := services.NewWebhookService(db, webhookRepo, auditRecorder, newLog)
+	roleSvc := services.NewRoleService(db, roleRepo, auditRecorder, newLog)
+	profileSvc := services.NewProfileService(db, userRepo, profileRepo, blobStore, config.ENV.AvatarMaxBytes, auditRecorder, newLog)
+	auditSvc := services.NewAuditService(db, auditEventRepo, auditRecorder, newLog)
 
-	userSvc := services.NewUserService(db, bookClient, userRepo, borrowRepo, activityRepo, newLog)
-	userController := controllers.NewUserController(userSvc)
+	maxMessageSize := defaultMaxMessageSize
+	if size := int(profileSvc.MaxAvatarSize()) + avatarMessageOverhead; size > maxMessageSize {
+		maxMessageSize = size
+	}
+
+	grpcAddr := "localhost:" + config.ENV.GRPCPort
+	gatewayHandler, err := gateway.NewHandler(context.Background(), grpcAddr, maxMessageSize)
+	if err != nil {
+		log.Fatalf("Failed to initialize gRPC gateway: %v", err)
+	}
+	legacyGateway, err := gateway.NewLegacyHandler(context.Background(), grpcAddr, maxMessageSize, profileSvc.MaxAvatarSize())
+	if err != nil {
+		log.Fatalf("Failed to initialize v1 gateway: %v", err)
+	}
+
+	healthMonitor := health.NewMonitor(db, bookClient)
+	healthController := controllers.NewHealthController(healthMonitor)
 
 	return &Provider{
-		AuthProvider: authController,
-		UserProvider: userController,
+		HealthProvider: healthController,
+		HealthMonitor:  healthMonitor,
+		BookClient:     bookClient,
+		Auth:           middleware.NewAuth(userSvc),
+		AccountChecker: userSvc,
+		Logger:         newLog,
+
+		AuthHandler:    handlers.NewAuthService(authSvc, userSvc),
+		UserHandler:    handlers.NewUserService(userSvc),
+		ProfileHandler: handlers.NewProfileService(profileSvc),
+		LoanHandler:    handlers.NewLoanService(userSvc),
+		WebhookHandler: handlers.NewWebhookService(webhookSvc),
+		RoleHandler:    handlers.NewRoleService(roleSvc),
+		AuditHandler:   handlers.NewAuditService(auditSvc),
+		Gateway:        gatewayHandler,
+		LegacyGateway:  legacyGateway,
+		MaxMessageSize: maxMessageSize,
+
+		OutboxRelay:    events.NewRelay(db, outboxRepo, publisher, newLog),
+		EventPublisher: publisher,
+		BookEvents:     bookEvents,
+		BookProjector:  events.NewBookProjector(db, bookProjectionRepo, newLog),
+
+		WebhookDispatcher: webhooks.NewDispatcher(db, webhookRepo, newLog),
+		OverdueScanner:    webhooks.NewOverdueScanner(db, borrowRepo, webhookEnqueuer, time.Duration(config.ENV.LoanPeriodDays)*24*time.Hour, newLog),
+
+		AuditRecorder: auditRecorder,
+		Eraser:        privacy.NewEraser(db, userRepo, adminActionRepo, profileRepo, blobStore, time.Duration(config.ENV.ErasureGrace)*24*time.Hour, newLog),
 	}
 }
@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"context"
+	"library-api-user/internal/grpc/handlers"
+	auditpb "library-api-user/proto/audit"
+	"library-api-user/proto/auth"
+	"library-api-user/proto/loan"
+	"library-api-user/proto/role"
+	"library-api-user/proto/user"
+	"library-api-user/proto/webhook"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewHandler builds the REST handlers generated from the proto HTTP
+// annotations. Requests are forwarded to the gRPC server listening on
+// grpcAddr, so both transports share the same interceptors and handlers.
+// maxMessageSize is the largest response accepted from the server.
+func NewHandler(ctx context.Context, grpcAddr string, maxMessageSize int) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(forwardHeader),
+		runtime.WithOutgoingHeaderMatcher(responseHeader),
+		runtime.WithErrorHandler(errorHandler),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler()),
+	)
+
+	if err := register(ctx, mux, grpcAddr, maxMessageSize); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// register adds the handlers of every service to mux.
+func register(ctx context.Context, mux *runtime.ServeMux, grpcAddr string, maxMessageSize int) error {
+	// The stats handler passes the trace of the HTTP request on to the gRPC
+	// server, so both halves of a gateway call end up in one trace.
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)),
+	}
+
+	registrations := []func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error{
+		auth.RegisterAuthServiceHandlerFromEndpoint,
+		user.RegisterUserServiceHandlerFromEndpoint,
+		user.RegisterProfileServiceHandlerFromEndpoint,
+		loan.RegisterLoanServiceHandlerFromEndpoint,
+		webhook.RegisterWebhookServiceHandlerFromEndpoint,
+		role.RegisterRoleServiceHandlerFromEndpoint,
+		auditpb.RegisterAuditServiceHandlerFromEndpoint,
+	}
+	for _, registration := range registrations {
+		if err := registration(ctx, mux, grpcAddr, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshaler renders messages with their proto field names and every field
+// present. google.api.HttpBody responses, such as avatars, are sent as they
+// are.
+func marshaler() runtime.Marshaler {
+	return &runtime.HTTPBodyMarshaler{
+		Marshaler: &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		},
+	}
+}
+
+// forwardHeader passes X-Request-ID on to the gRPC server along with the
+// headers forwarded by default.
+func forwardHeader(key string) (string, bool) {
+	if strings.EqualFold(key, "X-Request-ID") {
+		return "x-request-id", true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// responseHeaders is the header metadata set by the handlers that is sent
+// as HTTP headers of the same name. Other metadata keeps the default
+// Grpc-Metadata- prefix.
+var responseHeaders = map[string]bool{
+	"etag":                   true,
+	"content-disposition":    true,
+	"cache-control":          true,
+	"x-content-type-options": true,
+}
+
+func responseHeader(key string) (string, bool) {
+	if responseHeaders[key] {
+		return key, true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// errorHandler answers like the default handler, with the HTTP status of
+// the service error when the status carries one. Without it, errors such
+// as a failed If-Match precondition would all be answered with the status
+// of the closest gRPC code.
+func errorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if custErr, ok := handlers.FromStatus(status.Convert(err)); ok {
+		w = &statusWriter{ResponseWriter: w, statusCode: custErr.StatusCode}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// statusWriter replaces the status code written to the response.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(w.statusCode)
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"context"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/grpc/handlers"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	"library-api-user/proto/user"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+var updatedAt = time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+// users is a UserService whose only account, 1, was last updated at
+// updatedAt. Only Update is implemented.
+type users struct {
+	services.UserService
+}
+
+func (u *users) Update(ctx context.Context, id uint64, req *params.UserPatchRequest, expectedUpdatedAt *time.Time) (*params.UserResponse, *response.CustomError) {
+	if expectedUpdatedAt != nil && !expectedUpdatedAt.Equal(updatedAt) {
+		return nil, response.PreconditionFailedError("User was modified since it was read")
+	}
+	return &params.UserResponse{ID: id, Name: "Ada", Role: "user", Status: "active", CreatedAt: updatedAt, UpdatedAt: updatedAt}, nil
+}
+
+func serveUsers(t *testing.T) http.Handler {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	user.RegisterUserServiceServer(server, handlers.NewUserService(&users{}))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	handler, err := NewHandler(context.Background(), listener.Addr().String(), 4<<20)
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	return handler
+}
+
+func TestHandlerChecksIfMatch(t *testing.T) {
+	handler := serveUsers(t)
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		{"no precondition", "", http.StatusOK},
+		{"current etag", `"20260102T030405.600000"`, http.StatusOK},
+		{"stale etag", `"20250102T030405.600000"`, http.StatusPreconditionFailed},
+		{"malformed etag", `"yesterday"`, http.StatusPreconditionFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := newRequest(http.MethodPatch, "/api/v2/users/1", `{"name":"Ada"}`)
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+			rec := record(handler, req)
+
+			if rec.Code != test.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.want, rec.Body)
+			}
+			if test.want == http.StatusOK && rec.Header().Get("ETag") != `"20260102T030405.600000"` {
+				t.Errorf("ETag = %q, want the new version", rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
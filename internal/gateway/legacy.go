@@ -0,0 +1,328 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/grpc/handlers"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	legacyPrefix = "/api/v1"
+	targetPrefix = "/api/v2"
+
+	// multipartOverhead is what an upload body may carry on top of the
+	// avatar itself: boundaries, part headers and small form fields.
+	multipartOverhead = 64 << 10
+)
+
+// legacyRoute serves a v1 endpoint with the RPC of a v2 endpoint. The v1
+// response is the v2 one wrapped in the v1 envelope.
+type legacyRoute struct {
+	method string
+	// path and target are relative to /api/v1 and /api/v2. Parameters are
+	// written :name in both and copied from one to the other.
+	path   string
+	target string
+
+	// statusCode and message are those of the envelope; statusCode is 200
+	// when not set.
+	statusCode int
+	message    string
+	// data extracts the data of the envelope from the response; there is
+	// no data when it is nil.
+	data extractor
+	// raw routes send the response without envelope, like file downloads.
+	raw bool
+	// selects is the message the fields query parameter applies to.
+	selects protoreflect.FullName
+	// avatar routes take the image from the multipart field "avatar".
+	avatar bool
+}
+
+// legacyRoutes is the v1 API. Routes are matched in order, so a static
+// segment has to come before a parameter at the same position.
+var legacyRoutes = []legacyRoute{
+	{method: http.MethodPost, path: "/register", target: "/register", statusCode: http.StatusCreated, message: "CREATED SUCCESS"},
+	{method: http.MethodPost, path: "/login", target: "/login", message: "Success login user", data: whole},
+
+	{method: http.MethodGet, path: "/users", target: "/users", message: "Success get data users", data: whole, selects: "user.User"},
+	{method: http.MethodPost, path: "/users", target: "/users", statusCode: http.StatusCreated, message: "CREATED SUCCESS", data: whole},
+	{method: http.MethodGet, path: "/users/:id", target: "/users/:id", message: "Success retrieve data detail user", data: whole, selects: "user.User"},
+	{method: http.MethodPatch, path: "/users/:id", target: "/users/:id", message: "Success update user", data: field("user")},
+	{method: http.MethodDelete, path: "/users/:id", target: "/users/:id", message: "Success delete user"},
+	{method: http.MethodPost, path: "/users/:id/suspend", target: "/users/:id/suspend", message: "Success suspend user"},
+	{method: http.MethodPost, path: "/users/:id/reactivate", target: "/users/:id/reactivate", message: "Success reactivate user"},
+	{method: http.MethodGet, path: "/users/:id/export", target: "/users/:id/export", raw: true},
+	{method: http.MethodGet, path: "/me", target: "/me", message: "Success retrieve data user", data: whole, selects: "user.UserSelf"},
+	{method: http.MethodGet, path: "/me/export", target: "/me/export", raw: true},
+
+	{method: http.MethodGet, path: "/users/:id/profile", target: "/users/:id/profile", message: "Success retrieve profile", data: whole},
+	{method: http.MethodPatch, path: "/users/:id/profile", target: "/users/:id/profile", message: "Success update profile", data: whole},
+	{method: http.MethodGet, path: "/users/:id/avatar", target: "/users/:id/avatar", raw: true},
+	{method: http.MethodGet, path: "/me/profile", target: "/me/profile", message: "Success retrieve profile", data: whole},
+	{method: http.MethodPatch, path: "/me/profile", target: "/me/profile", message: "Success update profile", data: whole},
+	{method: http.MethodGet, path: "/me/avatar", target: "/me/avatar", raw: true},
+	{method: http.MethodPut, path: "/me/avatar", target: "/me/avatar", message: "Success upload avatar", data: whole, avatar: true},
+	{method: http.MethodDelete, path: "/me/avatar", target: "/me/avatar", message: "Success delete avatar"},
+
+	// v1 named the book to borrow or return :id under /users.
+	{method: http.MethodPost, path: "/users/:id/borrow", target: "/books/:id/borrow", message: "Success users borrow book"},
+	{method: http.MethodPost, path: "/users/:id/return", target: "/books/:id/return", message: "Success users return book"},
+	{method: http.MethodGet, path: "/loans", target: "/loans", message: "Success get data loans", data: fields("loans", "pagination")},
+	{method: http.MethodGet, path: "/activities", target: "/activities", message: "Success get data activities", data: whole},
+
+	{method: http.MethodPost, path: "/webhooks", target: "/webhooks", statusCode: http.StatusCreated, message: "CREATED SUCCESS", data: whole},
+	{method: http.MethodGet, path: "/webhooks", target: "/webhooks", message: "Success get data webhooks", data: field("webhooks")},
+	{method: http.MethodGet, path: "/webhooks/deliveries", target: "/webhooks/deliveries", message: "Success get data webhook deliveries", data: whole},
+	{method: http.MethodPost, path: "/webhooks/deliveries/:id/redeliver", target: "/webhooks/deliveries/:id/redeliver", message: "Success requeue webhook delivery"},
+	{method: http.MethodGet, path: "/webhooks/:id", target: "/webhooks/:id", message: "Success retrieve data detail webhook", data: whole},
+	{method: http.MethodPut, path: "/webhooks/:id", target: "/webhooks/:id", message: "SUCCESS"},
+	{method: http.MethodDelete, path: "/webhooks/:id", target: "/webhooks/:id", message: "SUCCESS"},
+	{method: http.MethodGet, path: "/webhooks/:id/deliveries", target: "/webhooks/:id/deliveries", message: "Success get data webhook deliveries", data: whole},
+
+	{method: http.MethodGet, path: "/roles", target: "/roles", message: "Success get data roles", data: field("roles")},
+	{method: http.MethodPost, path: "/roles", target: "/roles", statusCode: http.StatusCreated, message: "CREATED SUCCESS", data: whole},
+	{method: http.MethodPost, path: "/roles/:name/permissions", target: "/roles/:name/permissions", message: "SUCCESS"},
+	{method: http.MethodDelete, path: "/roles/:name/permissions/:permission", target: "/roles/:name/permissions/:permission", message: "SUCCESS"},
+	{method: http.MethodGet, path: "/permissions", target: "/permissions", message: "Success get data permissions", data: field("permissions")},
+
+	{method: http.MethodGet, path: "/audit-events", target: "/audit-events", message: "Success get audit events", data: whole},
+	{method: http.MethodGet, path: "/audit-events/verify", target: "/audit-events/verify", message: "Success verify audit chain", data: whole},
+}
+
+// NewLegacyHandler serves the v1 API through the same RPCs as v2. Each v1
+// request is rewritten to its v2 path and answered in the v1 envelope,
+// with errors in the body of response.CustomError. maxAvatarSize bounds
+// avatar uploads, which v1 takes as multipart forms.
+func NewLegacyHandler(ctx context.Context, grpcAddr string, maxMessageSize int, maxAvatarSize int64) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(forwardHeader),
+		runtime.WithOutgoingHeaderMatcher(responseHeader),
+		runtime.WithErrorHandler(legacyErrorHandler),
+		runtime.WithForwardResponseOption(legacyStatus),
+		runtime.WithForwardResponseRewriter(legacyEnvelope),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, marshaler()),
+	)
+
+	if err := register(ctx, mux, grpcAddr, maxMessageSize); err != nil {
+		return nil, err
+	}
+	return &legacyHandler{mux: mux, routes: legacyRoutes, maxAvatarSize: maxAvatarSize}, nil
+}
+
+type legacyHandler struct {
+	mux           http.Handler
+	routes        []legacyRoute
+	maxAvatarSize int64
+}
+
+// legacyCall is the v1 route a request was matched to, stored on the
+// request context for the response rewriter.
+type legacyCall struct {
+	route   *legacyRoute
+	encoder *legacyEncoder
+}
+
+type legacyCallKey struct{}
+
+func (h *legacyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), legacyPrefix)
+	route, target := h.match(r.Method, path)
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if route.avatar {
+		if custErr := h.readAvatar(w, r); custErr != nil {
+			writeLegacyError(w, custErr)
+			return
+		}
+	}
+
+	call := &legacyCall{route: route, encoder: &legacyEncoder{}}
+	if value := r.URL.Query().Get("fields"); value != "" && route.selects != "" {
+		call.encoder.selects = route.selects
+		call.encoder.fields = map[protoreflect.Name]bool{}
+		for _, name := range strings.Split(value, ",") {
+			call.encoder.fields[protoreflect.Name(strings.TrimSpace(name))] = true
+		}
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), legacyCallKey{}, call))
+	r.URL.RawPath = targetPrefix + target
+	r.URL.Path, _ = url.PathUnescape(r.URL.RawPath)
+	h.mux.ServeHTTP(w, r)
+}
+
+// match returns the route of a request and its escaped v2 path.
+func (h *legacyHandler) match(method string, path string) (*legacyRoute, string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i := range h.routes {
+		route := &h.routes[i]
+		if route.method != method {
+			continue
+		}
+		params, ok := matchSegments(strings.Split(strings.Trim(route.path, "/"), "/"), segments)
+		if !ok {
+			continue
+		}
+
+		target := strings.Split(strings.Trim(route.target, "/"), "/")
+		for j, segment := range target {
+			if strings.HasPrefix(segment, ":") {
+				target[j] = params[segment]
+			}
+		}
+		return route, "/" + strings.Join(target, "/")
+	}
+	return nil, ""
+}
+
+func matchSegments(pattern []string, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range pattern {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[segment] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// readAvatar replaces the multipart body of an upload with the JSON body
+// of the v2 call. The body is cut off past the size limit, so an oversized
+// upload is rejected without being read in full.
+func (h *legacyHandler) readAvatar(w http.ResponseWriter, r *http.Request) *response.CustomError {
+	tooLarge := response.PayloadTooLargeError(fmt.Sprintf("Avatar must not be larger than %d bytes", h.maxAvatarSize))
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxAvatarSize+multipartOverhead)
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return tooLarge
+		}
+		return response.BadRequestError("Field 'avatar' must be a file: " + err.Error())
+	}
+	defer file.Close()
+
+	if header.Size > h.maxAvatarSize {
+		return tooLarge
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return response.BadRequestError("Failed to read avatar: " + err.Error())
+	}
+
+	body, err := json.Marshal(map[string][]byte{"content": content})
+	if err != nil {
+		return response.GeneralError("Failed to encode avatar: " + err.Error())
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+func legacyCallFrom(ctx context.Context) (*legacyCall, bool) {
+	call, ok := ctx.Value(legacyCallKey{}).(*legacyCall)
+	return call, ok
+}
+
+// legacyStatus answers with the status code of the route, such as 201 for
+// creations.
+func legacyStatus(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	if call, ok := legacyCallFrom(ctx); ok && call.route.statusCode != 0 {
+		w.WriteHeader(call.route.statusCode)
+	}
+	return nil
+}
+
+// legacyEnvelope wraps a response in the v1 envelope of its route.
+func legacyEnvelope(ctx context.Context, resp proto.Message) (any, error) {
+	call, ok := legacyCallFrom(ctx)
+	if !ok {
+		return resp, nil
+	}
+	if call.route.raw {
+		if _, ok := resp.(*httpbody.HttpBody); ok {
+			return resp, nil
+		}
+		return call.encoder.message(resp.ProtoReflect()), nil
+	}
+
+	envelope := response.GeneralSuccess(call.route.message)
+	if call.route.statusCode != 0 {
+		envelope.StatusCode = call.route.statusCode
+	}
+	if call.route.data != nil {
+		envelope.Payload = call.route.data(call.encoder, resp.ProtoReflect())
+	}
+	return envelope, nil
+}
+
+// legacyErrorHandler answers errors with the v1 error body. Service errors
+// are sent as the service returned them; errors raised by the interceptors
+// or the gateway get the error of their HTTP status.
+func legacyErrorHandler(_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
+	writeLegacyError(w, legacyError(err))
+}
+
+func legacyError(err error) *response.CustomError {
+	st := status.Convert(err)
+	if custErr, ok := handlers.FromStatus(st); ok {
+		return custErr
+	}
+
+	statusCode := runtime.HTTPStatusFromCode(st.Code())
+	switch statusCode {
+	case http.StatusBadRequest:
+		return response.BadRequestError(st.Message())
+	case http.StatusUnauthorized:
+		return response.UnauthorizedError(st.Message())
+	case http.StatusForbidden:
+		return response.ForbiddenError(st.Message())
+	case http.StatusConflict:
+		return response.ConflictError(st.Message())
+	case http.StatusServiceUnavailable:
+		return response.ServiceUnavailableErrorWithAdditionalInfo(nil, st.Message())
+	}
+	custErr := response.GeneralError(st.Message())
+	custErr.StatusCode = statusCode
+	return custErr
+}
+
+func writeLegacyError(w http.ResponseWriter, custErr *response.CustomError) {
+	body, err := json.Marshal(custErr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(custErr.StatusCode)
+	_, _ = w.Write(body)
+}
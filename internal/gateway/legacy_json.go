@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// extractor picks the data of the v1 envelope out of a response.
+type extractor func(e *legacyEncoder, m protoreflect.Message) any
+
+// whole sends the entire response as data.
+func whole(e *legacyEncoder, m protoreflect.Message) any {
+	return e.message(m)
+}
+
+// field sends one field of the response as data, for responses v1 sent
+// as a bare list.
+func field(name protoreflect.Name) extractor {
+	return func(e *legacyEncoder, m protoreflect.Message) any {
+		fd := m.Descriptor().Fields().ByName(name)
+		return e.field(m, fd)
+	}
+}
+
+// fields sends some fields of the response as data, leaving out the ones
+// v2 only keeps for older clients.
+func fields(names ...protoreflect.Name) extractor {
+	return func(e *legacyEncoder, m protoreflect.Message) any {
+		object := orderedObject{}
+		for _, name := range names {
+			fd := m.Descriptor().Fields().ByName(name)
+			object.add(string(name), e.field(m, fd))
+		}
+		return object
+	}
+}
+
+// legacyEncoder renders messages the way the v1 controllers rendered their
+// views with encoding/json: 64-bit integers as numbers, timestamps as
+// time.Time, wrappers as the value they wrap and unset messages as null.
+// Unset optional fields are left out, like omitempty pointers.
+type legacyEncoder struct {
+	// selects is the message the fields query parameter applies to; fields
+	// of it that are not in fields are left out.
+	selects protoreflect.FullName
+	fields  map[protoreflect.Name]bool
+}
+
+var wrapperTypes = map[protoreflect.FullName]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+var jsonTypes = map[protoreflect.FullName]bool{
+	"google.protobuf.Value":     true,
+	"google.protobuf.Struct":    true,
+	"google.protobuf.ListValue": true,
+}
+
+func (e *legacyEncoder) message(m protoreflect.Message) any {
+	name := m.Descriptor().FullName()
+	switch {
+	case name == "google.protobuf.Timestamp":
+		fields := m.Descriptor().Fields()
+		seconds := m.Get(fields.ByName("seconds")).Int()
+		nanos := m.Get(fields.ByName("nanos")).Int()
+		return time.Unix(seconds, nanos).UTC()
+	case wrapperTypes[name]:
+		fd := m.Descriptor().Fields().ByName("value")
+		return e.scalar(fd, m.Get(fd))
+	case jsonTypes[name]:
+		body, err := protojson.Marshal(m.Interface())
+		if err != nil {
+			return nil
+		}
+		return json.RawMessage(body)
+	}
+
+	object := orderedObject{}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if name == e.selects && e.fields != nil && !e.fields[fd.Name()] {
+			continue
+		}
+		if fd.HasOptionalKeyword() && !m.Has(fd) {
+			continue
+		}
+		object.add(string(fd.Name()), e.field(m, fd))
+	}
+	return object
+}
+
+func (e *legacyEncoder) field(m protoreflect.Message, fd protoreflect.FieldDescriptor) any {
+	if fd == nil {
+		return nil
+	}
+
+	switch {
+	case fd.IsList():
+		list := m.Get(fd).List()
+		items := make([]any, list.Len())
+		for i := range items {
+			items[i] = e.scalar(fd, list.Get(i))
+		}
+		return items
+	case fd.IsMap():
+		entries := map[string]any{}
+		m.Get(fd).Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			entries[key.String()] = e.scalar(fd.MapValue(), value)
+			return true
+		})
+		return entries
+	case fd.Message() != nil && !m.Has(fd):
+		return nil
+	}
+	return e.scalar(fd, m.Get(fd))
+}
+
+func (e *legacyEncoder) scalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return e.message(v.Message())
+	case protoreflect.EnumKind:
+		if value := fd.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return string(value.Name())
+		}
+		return int32(v.Enum())
+	}
+	return v.Interface()
+}
+
+// orderedObject is a JSON object that keeps its keys in field order.
+type orderedObject struct {
+	keys   []string
+	values []any
+}
+
+func (o *orderedObject) add(key string, value any) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
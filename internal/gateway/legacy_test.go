@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/grpc/handlers"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"library-api-user/proto/webhook"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var createdAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// webhooks is a WebhookService with one subscription, 7.
+type webhooks struct {
+	deliveriesOf []uint64
+}
+
+func (w *webhooks) Create(ctx context.Context, req *params.WebhookRequest) (*params.WebhookResponse, *response.CustomError) {
+	return &params.WebhookResponse{ID: 7, URL: req.URL, EventTypes: req.EventTypes, Active: true, Secret: "s3cret", CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+}
+
+func (w *webhooks) Detail(ctx context.Context, id uint64) (*params.WebhookResponse, *response.CustomError) {
+	if id != 7 {
+		return nil, response.NotFoundError("Webhook not found")
+	}
+	return &params.WebhookResponse{ID: 7, URL: "https://example.com/hook", Active: true, CreatedAt: createdAt, UpdatedAt: createdAt}, nil
+}
+
+func (w *webhooks) GetAll(ctx context.Context) ([]*params.WebhookResponse, *response.CustomError) {
+	webhook, custErr := w.Detail(ctx, 7)
+	return []*params.WebhookResponse{webhook}, custErr
+}
+
+func (w *webhooks) Update(ctx context.Context, req *params.WebhookRequest, id uint64) *response.CustomError {
+	return nil
+}
+
+func (w *webhooks) Delete(ctx context.Context, id uint64) *response.CustomError {
+	return nil
+}
+
+func (w *webhooks) Deliveries(ctx context.Context, subscriptionID uint64, status string, pagination *models.Pagination) ([]*params.WebhookDeliveryResponse, *response.CustomError) {
+	w.deliveriesOf = append(w.deliveriesOf, subscriptionID)
+	return []*params.WebhookDeliveryResponse{{
+		ID:             3,
+		SubscriptionID: 7,
+		EventType:      "user.created",
+		Payload:        json.RawMessage(`{"user_id":1}`),
+		Status:         "pending",
+		CreatedAt:      createdAt,
+	}}, nil
+}
+
+func (w *webhooks) Redeliver(ctx context.Context, deliveryID uint64) *response.CustomError {
+	return nil
+}
+
+// serveLegacy serves the webhook RPCs on a local port and returns the v1
+// handler in front of them.
+func serveLegacy(t *testing.T, service *webhooks) http.Handler {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	webhook.RegisterWebhookServiceServer(server, handlers.NewWebhookService(service))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	handler, err := NewLegacyHandler(context.Background(), listener.Addr().String(), 4<<20, 1<<20)
+	if err != nil {
+		t.Fatalf("NewLegacyHandler() error = %v", err)
+	}
+	return handler
+}
+
+func newRequest(method string, path string, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+func record(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// serve answers a request and decodes the JSON body of the response.
+func serve(handler http.Handler, method string, path string, body string) (*httptest.ResponseRecorder, map[string]any) {
+	rec := record(handler, newRequest(method, path, body))
+
+	var decoded map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &decoded)
+	return rec, decoded
+}
+
+func TestLegacyHandlerWrapsResponses(t *testing.T) {
+	handler := serveLegacy(t, &webhooks{})
+
+	rec, body := serve(handler, http.MethodPost, "/api/v1/webhooks", `{"url":"https://example.com/hook","event_types":["user.created"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if body["status"] != true || body["status_code"] != float64(http.StatusCreated) || body["message"] != "CREATED SUCCESS" {
+		t.Errorf("envelope = %v", body)
+	}
+	data, _ := body["data"].(map[string]any)
+	if data["id"] != float64(7) {
+		t.Errorf("id = %#v, want the number 7", data["id"])
+	}
+	if data["secret"] != "s3cret" {
+		t.Errorf("secret = %#v, want s3cret", data["secret"])
+	}
+	if data["created_at"] != createdAt.Format(time.RFC3339Nano) {
+		t.Errorf("created_at = %#v, want %s", data["created_at"], createdAt.Format(time.RFC3339Nano))
+	}
+
+	rec, body = serve(handler, http.MethodGet, "/api/v1/webhooks", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	list, _ := body["data"].([]any)
+	if len(list) != 1 {
+		t.Fatalf("data = %#v, want the list of webhooks", body["data"])
+	}
+	if _, ok := list[0].(map[string]any)["secret"]; ok {
+		t.Errorf("secret is sent when it is not set: %v", list[0])
+	}
+
+	rec, body = serve(handler, http.MethodDelete, "/api/v1/webhooks/7", "")
+	if rec.Code != http.StatusOK || body["message"] != "SUCCESS" {
+		t.Errorf("delete = %d %v", rec.Code, body)
+	}
+	if _, ok := body["data"]; ok {
+		t.Errorf("delete has data: %v", body["data"])
+	}
+}
+
+func TestLegacyHandlerMatchesStaticSegmentsFirst(t *testing.T) {
+	service := &webhooks{}
+	handler := serveLegacy(t, service)
+
+	rec, body := serve(handler, http.MethodGet, "/api/v1/webhooks/deliveries", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if len(service.deliveriesOf) != 1 || service.deliveriesOf[0] != 0 {
+		t.Errorf("deliveries listed for %v, want every subscription", service.deliveriesOf)
+	}
+
+	data, _ := body["data"].(map[string]any)
+	deliveries, _ := data["deliveries"].([]any)
+	if len(deliveries) != 1 {
+		t.Fatalf("data = %v, want one delivery", data)
+	}
+	delivery := deliveries[0].(map[string]any)
+	if payload, _ := delivery["payload"].(map[string]any); payload["user_id"] != float64(1) {
+		t.Errorf("payload = %#v, want the event payload", delivery["payload"])
+	}
+	if value, ok := delivery["delivered_at"]; !ok || value != nil {
+		t.Errorf("delivered_at = %#v, want null", value)
+	}
+}
+
+func TestLegacyHandlerErrors(t *testing.T) {
+	handler := serveLegacy(t, &webhooks{})
+
+	rec, body := serve(handler, http.MethodGet, "/api/v1/webhooks/9", "")
+	want := response.NotFoundError("Webhook not found")
+	if rec.Code != want.StatusCode {
+		t.Errorf("status = %d, want %d", rec.Code, want.StatusCode)
+	}
+	if body["code"] != want.Code || body["message"] != want.Message || body["status"] != false {
+		t.Errorf("body = %v, want the service error", body)
+	}
+
+	rec, _ = serve(handler, http.MethodGet, "/api/v1/nothing", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown route status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestLegacyErrorFromCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *response.CustomError
+	}{
+		{status.Error(codes.Unauthenticated, "missing authorization token"), response.UnauthorizedError("missing authorization token")},
+		{status.Error(codes.PermissionDenied, "denied"), response.ForbiddenError("denied")},
+		{status.Error(codes.InvalidArgument, "bad"), response.BadRequestError("bad")},
+	}
+	for _, test := range tests {
+		got := legacyError(test.err)
+		if got.Code != test.want.Code || got.StatusCode != test.want.StatusCode || got.Message != test.want.Message {
+			t.Errorf("legacyError(%v) = %+v, want %+v", test.err, got, test.want)
+		}
+	}
+
+	got := legacyError(status.Error(codes.NotFound, "no route"))
+	if got.StatusCode != http.StatusNotFound {
+		t.Errorf("status code = %d, want %d", got.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestLegacyHandlerMatch(t *testing.T) {
+	handler := &legacyHandler{routes: legacyRoutes}
+
+	tests := []struct {
+		method string
+		path   string
+		target string
+	}{
+		{http.MethodPost, "/users/4/borrow", "/books/4/borrow"},
+		{http.MethodGet, "/webhooks/deliveries", "/webhooks/deliveries"},
+		{http.MethodGet, "/webhooks/5", "/webhooks/5"},
+		{http.MethodDelete, "/roles/librarian/permissions/users:read", "/roles/librarian/permissions/users:read"},
+		{http.MethodGet, "/me/", "/me"},
+		{http.MethodGet, "/users/4/borrow", ""},
+		{http.MethodGet, "/users//profile", ""},
+	}
+	for _, test := range tests {
+		route, target := handler.match(test.method, test.path)
+		if test.target == "" {
+			if route != nil {
+				t.Errorf("%s %s matched %s", test.method, test.path, route.path)
+			}
+			continue
+		}
+		if route == nil || target != test.target {
+			t.Errorf("%s %s = %q, want %q", test.method, test.path, target, test.target)
+		}
+	}
+}
+
+func TestLegacyHandlerRejectsLargeAvatars(t *testing.T) {
+	handler := &legacyHandler{routes: legacyRoutes, maxAvatarSize: 16}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("avatar", "avatar.png")
+	part.Write(bytes.Repeat([]byte{0x89}, 64))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/me/avatar", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusRequestEntityTooLarge, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/me/avatar", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status without file = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+}
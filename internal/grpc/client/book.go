@@ -2,22 +2,28 @@ package client
 
 import (
 	"context"
+	"fmt"
 	pb "library-api-user/proto/book"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 type BookClient struct {
+	conn   *grpc.ClientConn
 	client pb.BookServiceClient
 }
 
-func NewBookClient(addr string) (*BookClient, error) {
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewBookClient connects to the book service at addr. opts are added to
+// the default dial options, e.g. to install interceptors.
+func NewBookClient(addr string, opts ...grpc.DialOption) (*BookClient, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return &BookClient{client: pb.NewBookServiceClient(conn)}, nil
+	return &BookClient{conn: conn, client: pb.NewBookServiceClient(conn)}, nil
 }
 
 func (c *BookClient) DecreaseStock(ctx context.Context, bookID uint64) error {
@@ -29,3 +35,23 @@ func (c *BookClient) IncreaseStock(ctx context.Context, bookID uint64) error {
 	_, err := c.client.IncreaseStock(ctx, &pb.IncreaseStockRequest{BookId: bookID})
 	return err
 }
+
+// CheckConnection reports whether the underlying connection to the book
+// service is usable. An idle connection is asked to connect so the next
+// check reflects the real state of the remote end.
+func (c *BookClient) CheckConnection(ctx context.Context) error {
+	state := c.conn.GetState()
+	if state == connectivity.Idle {
+		c.conn.Connect()
+	}
+
+	switch state {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return fmt.Errorf("book service connection is %s", state)
+	}
+	return nil
+}
+
+func (c *BookClient) Close() error {
+	return c.conn.Close()
+}
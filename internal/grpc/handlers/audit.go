@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	pb "library-api-user/proto/audit"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type AuditService struct {
+	pb.UnimplementedAuditServiceServer
+	AuditService services.AuditService
+}
+
+func NewAuditService(auditService services.AuditService) *AuditService {
+	return &AuditService{
+		AuditService: auditService,
+	}
+}
+
+func (s *AuditService) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	pagination, err := toPagination(req.Page, req.Limit, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := params.AuditEventQuery{
+		ActorID:    req.ActorId,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetId,
+		Action:     req.Action,
+	}
+	if query.From, err = parseTimestamp(req.From); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "from: "+err.Error())
+	}
+	if query.To, err = parseTimestamp(req.To); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "to: "+err.Error())
+	}
+
+	result, custErr := s.AuditService.Events(ctx, &query, &pagination)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	events := make([]*pb.AuditEvent, len(result))
+	for i, event := range result {
+		before, err := toValue(event.Before)
+		if err != nil {
+			return nil, err
+		}
+		after, err := toValue(event.After)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = &pb.AuditEvent{
+			Id:         event.ID,
+			OccurredAt: timestamppb.New(event.OccurredAt),
+			ActorId:    optionalID(event.ActorID),
+			ActorRole:  event.ActorRole,
+			Action:     event.Action,
+			TargetType: event.TargetType,
+			TargetId:   optionalID(event.TargetID),
+			Before:     before,
+			After:      after,
+			Ip:         event.IP,
+			UserAgent:  event.UserAgent,
+			PrevHash:   event.PrevHash,
+			Hash:       event.Hash,
+		}
+	}
+
+	return &pb.ListAuditEventsResponse{
+		Events:     events,
+		Pagination: toPaginationMessage(&pagination),
+	}, nil
+}
+
+func (s *AuditService) VerifyAuditChain(ctx context.Context, req *pb.VerifyAuditChainRequest) (*pb.AuditVerification, error) {
+	result, custErr := s.AuditService.Verify(ctx)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	verification := &pb.AuditVerification{
+		Valid:    result.Valid,
+		Checked:  int64(result.Checked),
+		HeadId:   result.HeadID,
+		HeadHash: result.HeadHash,
+		BrokenAt: result.BrokenAt,
+	}
+	if result.Reason != "" {
+		verification.Reason = &result.Reason
+	}
+	return verification, nil
+}
+
+func optionalID(id *uint64) *wrapperspb.UInt64Value {
+	if id == nil {
+		return nil
+	}
+	return wrapperspb.UInt64(*id)
+}
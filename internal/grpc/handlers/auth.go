@@ -2,20 +2,37 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"library-api-user/internal/grpc/interceptors"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
 	"library-api-user/pkg/token"
 	pb "library-api-user/proto/auth"
 )
 
 type AuthService struct {
 	pb.UnimplementedAuthServiceServer
+	AuthService services.AuthService
+	Accounts    interceptors.AccountChecker
 }
 
-func NewAuthService() *AuthService {
-	return &AuthService{}
+func NewAuthService(authService services.AuthService, accounts interceptors.AccountChecker) *AuthService {
+	return &AuthService{
+		AuthService: authService,
+		Accounts:    accounts,
+	}
 }
 
 func (s *AuthService) ValidateToken(ctx context.Context, req *pb.ValidateRequest) (*pb.ValidateResponse, error) {
 	payload, err := token.ValidateToken(req.Token)
+	var access *models.Access
+	if err == nil {
+		access, err = s.Accounts.Access(ctx, uint64(payload.AuthId))
+		if err == nil && access == nil {
+			err = errors.New("account is suspended or deleted")
+		}
+	}
 	if err != nil {
 		return &pb.ValidateResponse{
 			Success: false,
@@ -25,8 +42,39 @@ func (s *AuthService) ValidateToken(ctx context.Context, req *pb.ValidateRequest
 	}
 
 	return &pb.ValidateResponse{
+		Success:     true,
+		AuthId:      uint64(payload.AuthId),
+		Role:        access.Role,
+		Permissions: access.Permissions,
+	}, nil
+}
+
+func (s *AuthService) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	custErr := s.AuthService.Register(ctx, &params.RegisterRequest{
+		Email:    req.Email,
+		Password: req.Password,
+		Name:     req.Name,
+	})
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.RegisterResponse{
 		Success: true,
-		AuthId:  uint64(payload.AuthId),
-		Role:    payload.Role,
+		Message: "Success register user",
+	}, nil
+}
+
+func (s *AuthService) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	result, custErr := s.AuthService.Login(ctx, &params.LoginRequest{
+		Email:    req.Email,
+		Password: req.Password,
+	})
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.LoginResponse{
+		Token: result.Token,
 	}, nil
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"library-api-user/internal/commons/response"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo detail that carries a service
+// error in a gRPC status.
+const ErrorDomain = "library-api-user"
+
+// toStatusError converts a service error into a gRPC status. The code is
+// the closest gRPC code; the error itself is kept in an ErrorInfo detail,
+// so that the gateway can answer with the exact HTTP status and the v1
+// error body.
+func toStatusError(custErr *response.CustomError) error {
+	code := codes.Internal
+	switch custErr.StatusCode {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		code = codes.FailedPrecondition
+	case http.StatusRequestEntityTooLarge:
+		code = codes.ResourceExhausted
+	case http.StatusUnsupportedMediaType:
+		code = codes.InvalidArgument
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason: custErr.Code,
+		Domain: ErrorDomain,
+		Metadata: map[string]string{
+			"status_code": strconv.Itoa(custErr.StatusCode),
+		},
+	}
+	if custErr.AdditionalInfo != nil {
+		if additional, err := json.Marshal(custErr.AdditionalInfo); err == nil {
+			info.Metadata["additional_info"] = string(additional)
+		}
+	}
+
+	st, err := status.New(code, custErr.Message).WithDetails(info)
+	if err != nil {
+		return status.Error(code, custErr.Message)
+	}
+	return st.Err()
+}
+
+// FromStatus returns the service error carried by a status built by
+// toStatusError, or false for statuses raised elsewhere, such as by the
+// interceptors or the gateway.
+func FromStatus(st *status.Status) (*response.CustomError, bool) {
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != ErrorDomain {
+			continue
+		}
+		statusCode, err := strconv.Atoi(info.Metadata["status_code"])
+		if err != nil {
+			continue
+		}
+
+		custErr := &response.CustomError{
+			Code:       info.Reason,
+			StatusCode: statusCode,
+			Status:     false,
+			Message:    st.Message(),
+		}
+		if additional, ok := info.Metadata["additional_info"]; ok {
+			custErr.AdditionalInfo = json.RawMessage(additional)
+		}
+		return custErr, true
+	}
+	return nil, false
+}
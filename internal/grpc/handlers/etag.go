@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+)
+
+// etagLayout renders updated_at in UTC, to the microsecond the database
+// stores. ETags issued before updated_at carried a time zone were the UTC
+// wall clock as well, so they stay valid.
+const etagLayout = "20060102T150405.000000"
+
+func formatETag(updatedAt time.Time) string {
+	return `"` + updatedAt.UTC().Format(etagLayout) + `"`
+}
+
+// parseIfMatch returns the updated_at a request expects to modify. A missing
+// header or "*" means no expectation. ok is false for malformed values.
+func parseIfMatch(header string) (expected *time.Time, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	updatedAt, err := time.ParseInLocation(etagLayout, value, time.UTC)
+	if err != nil {
+		return nil, false
+	}
+	return &updatedAt, true
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldSet is the set of fields a client asked for; nil means every field.
+type fieldSet map[protoreflect.Name]bool
+
+// parseFields reads the fields parameter, a comma separated list of field
+// names of message. Unknown names are an InvalidArgument error, raised
+// before the call does any work.
+func parseFields(value string, message proto.Message) (fieldSet, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	known := message.ProtoReflect().Descriptor().Fields()
+	fields := fieldSet{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if known.ByName(protoreflect.Name(name)) == nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("unknown field %q", name))
+		}
+		fields[protoreflect.Name(name)] = true
+	}
+	return fields, nil
+}
+
+// clear empties the fields of message that were not asked for.
+func (fields fieldSet) clear(message proto.Message) {
+	if fields == nil {
+		return
+	}
+	m := message.ProtoReflect()
+	known := m.Descriptor().Fields()
+	for i := 0; i < known.Len(); i++ {
+		if field := known.Get(i); !fields[field.Name()] {
+			m.Clear(field)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	pb "library-api-user/proto/loan"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type LoanService struct {
+	pb.UnimplementedLoanServiceServer
+	UserService services.UserService
+}
+
+func NewLoanService(userService services.UserService) *LoanService {
+	return &LoanService{
+		UserService: userService,
+	}
+}
+
+func (s *LoanService) ListLoans(ctx context.Context, req *pb.ListLoansRequest) (*pb.ListLoansResponse, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination, err := toPagination(req.Page, req.Limit, req.Cursor, req.IncludeTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	result, custErr := s.UserService.Loans(ctx, userID, &pagination)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.ListLoansResponse{
+		Loans:      toLoanMessages(result),
+		NextCursor: pagination.NextCursor,
+		PrevCursor: pagination.PrevCursor,
+		TotalCount: int32(pagination.TotalCount),
+		Pagination: toPaginationMessage(&pagination),
+	}, nil
+}
+
+func (s *LoanService) BorrowBook(ctx context.Context, req *pb.BorrowBookRequest) (*pb.BorrowBookResponse, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	custErr := s.UserService.BorrowBook(ctx, userID, req.BookId)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.BorrowBookResponse{
+		Success: true,
+		Message: "Success users borrow book",
+	}, nil
+}
+
+func (s *LoanService) ReturnBook(ctx context.Context, req *pb.ReturnBookRequest) (*pb.ReturnBookResponse, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	custErr := s.UserService.ReturnBook(ctx, userID, req.BookId)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.ReturnBookResponse{
+		Success: true,
+		Message: "Success users return book",
+	}, nil
+}
+
+func (s *LoanService) ListActivities(ctx context.Context, req *pb.ListActivitiesRequest) (*pb.ListActivitiesResponse, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination, err := toPagination(req.Page, req.Limit, req.Cursor, req.IncludeTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	result, custErr := s.UserService.Activities(ctx, userID, &pagination)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.ListActivitiesResponse{
+		Activities: toActivityMessages(result),
+		Pagination: toPaginationMessage(&pagination),
+	}, nil
+}
+
+func toLoanMessages(result []*params.LoanResponse) []*pb.Loan {
+	loans := make([]*pb.Loan, len(result))
+	for i, loan := range result {
+		loans[i] = &pb.Loan{
+			Id:         loan.ID,
+			BookId:     loan.BookID,
+			BorrowedAt: timestamppb.New(loan.BorrowedAt),
+			ReturnedAt: optionalTime(loan.ReturnedAt),
+		}
+		if loan.Book != nil {
+			loans[i].Book = &pb.BookSnapshot{
+				Title:     loan.Book.Title,
+				Author:    loan.Book.Author,
+				Available: loan.Book.Available,
+				Deleted:   loan.Book.Deleted,
+			}
+		}
+	}
+	return loans
+}
+
+func toActivityMessages(result []*params.ActivityResponse) []*pb.Activity {
+	activities := make([]*pb.Activity, len(result))
+	for i, activity := range result {
+		activities[i] = &pb.Activity{
+			Id:           activity.ID,
+			BookId:       activity.BookID,
+			ActivityType: activity.ActivityType,
+			OccurredAt:   timestamppb.New(activity.OccurredAt),
+		}
+	}
+	return activities
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// incomingHeader returns the first value of an HTTP header sent by a gRPC
+// client, or forwarded by the gateway under the grpcgateway- prefix.
+func incomingHeader(ctx context.Context, name string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range []string{name, "grpcgateway-" + name} {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// setHeader sends response header metadata. The gateway passes the headers
+// it knows, such as etag, on as HTTP headers of the same name. Failing to
+// send them does not fail the call.
+func setHeader(ctx context.Context, kv ...string) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(kv...))
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"library-api-user/internal/commons/cursor"
+	"library-api-user/internal/models"
+	"library-api-user/proto/common"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toPagination reads the page and limit of a listing request: they fall
+// back to the first page of five items, limit is capped at
+// models.MaxPageSize, and a cursor replaces the page. Only a cursor that
+// cannot be decoded is an error.
+func toPagination(page int32, limit int32, value string, includeTotal bool) (models.Pagination, error) {
+	pageNum := int(page)
+	if pageNum <= 0 {
+		pageNum = 1
+	}
+	limitSize := int(limit)
+	if limitSize <= 0 {
+		limitSize = models.DefaultPageSize
+	}
+	if limitSize > models.MaxPageSize {
+		limitSize = models.MaxPageSize
+	}
+
+	pagination := models.Pagination{
+		Page:     pageNum,
+		Offset:   (pageNum - 1) * limitSize,
+		PageSize: limitSize,
+	}
+	if value != "" {
+		c, err := cursor.Decode(value)
+		if err != nil {
+			return pagination, status.Error(codes.InvalidArgument, err.Error())
+		}
+		pagination.Cursor = c
+		pagination.Page = 0
+		pagination.Offset = 0
+		pagination.IncludeTotal = includeTotal
+	}
+	return pagination, nil
+}
+
+func toPaginationMessage(pagination *models.Pagination) *common.Pagination {
+	message := &common.Pagination{
+		Page:       int32(pagination.Page),
+		PerPage:    int32(pagination.PageSize),
+		Offset:     int32(pagination.Offset),
+		PageCount:  int32(pagination.PageCount),
+		TotalCount: int32(pagination.TotalCount),
+	}
+	if pagination.NextCursor != "" {
+		message.NextCursor = &pagination.NextCursor
+	}
+	if pagination.PrevCursor != "" {
+		message.PrevCursor = &pagination.PrevCursor
+	}
+	return message
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	pb "library-api-user/proto/user"
+
+	"google.golang.org/genproto/googleapis/api/httpbody"
+)
+
+type ProfileService struct {
+	pb.UnimplementedProfileServiceServer
+	ProfileService services.ProfileService
+}
+
+func NewProfileService(profileService services.ProfileService) *ProfileService {
+	return &ProfileService{
+		ProfileService: profileService,
+	}
+}
+
+func (s *ProfileService) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.Profile, error) {
+	return s.get(ctx, req.UserId)
+}
+
+func (s *ProfileService) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.Profile, error) {
+	return s.update(ctx, req.UserId, req.Phone, req.Address, req.Preferences)
+}
+
+func (s *ProfileService) GetAvatar(ctx context.Context, req *pb.GetAvatarRequest) (*httpbody.HttpBody, error) {
+	return s.avatar(ctx, req.UserId)
+}
+
+func (s *ProfileService) GetMyProfile(ctx context.Context, req *pb.GetMyProfileRequest) (*pb.Profile, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.get(ctx, id)
+}
+
+func (s *ProfileService) UpdateMyProfile(ctx context.Context, req *pb.UpdateMyProfileRequest) (*pb.Profile, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, id, req.Phone, req.Address, req.Preferences)
+}
+
+func (s *ProfileService) GetMyAvatar(ctx context.Context, req *pb.GetMyAvatarRequest) (*httpbody.HttpBody, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.avatar(ctx, id)
+}
+
+func (s *ProfileService) UploadMyAvatar(ctx context.Context, req *pb.UploadMyAvatarRequest) (*pb.Profile, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, custErr := s.ProfileService.PutAvatar(ctx, id, bytes.NewReader(req.Content))
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toProfileMessage(result), nil
+}
+
+func (s *ProfileService) DeleteMyAvatar(ctx context.Context, req *pb.DeleteMyAvatarRequest) (*pb.DeleteMyAvatarResponse, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	custErr := s.ProfileService.DeleteAvatar(ctx, id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.DeleteMyAvatarResponse{
+		Success: true,
+		Message: "Success delete avatar",
+	}, nil
+}
+
+func (s *ProfileService) get(ctx context.Context, id uint64) (*pb.Profile, error) {
+	result, custErr := s.ProfileService.Get(ctx, id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toProfileMessage(result), nil
+}
+
+func (s *ProfileService) update(ctx context.Context, id uint64, phone *string, address *pb.Address, preferences *pb.Preferences) (*pb.Profile, error) {
+	req := &params.ProfilePatchRequest{Phone: phone}
+	if address != nil {
+		req.Address = &params.Address{
+			Line1:      address.Line1,
+			Line2:      address.Line2,
+			City:       address.City,
+			Region:     address.Region,
+			PostalCode: address.PostalCode,
+			Country:    address.Country,
+		}
+	}
+	if preferences != nil {
+		req.Preferences = &params.PreferencesRequest{
+			NotificationChannel: preferences.NotificationChannel,
+			Language:            preferences.Language,
+		}
+	}
+
+	result, custErr := s.ProfileService.Update(ctx, id, req)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toProfileMessage(result), nil
+}
+
+// avatar sends the image itself. nosniff keeps browsers to the type that
+// was checked on upload.
+func (s *ProfileService) avatar(ctx context.Context, id uint64) (*httpbody.HttpBody, error) {
+	content, avatar, custErr := s.ProfileService.Avatar(ctx, id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, toStatusError(response.GeneralError("Failed to read avatar: " + err.Error()))
+	}
+
+	setHeader(ctx, "cache-control", "private, no-cache", "x-content-type-options", "nosniff")
+	return &httpbody.HttpBody{
+		ContentType: avatar.ContentType,
+		Data:        data,
+	}, nil
+}
+
+func toProfileMessage(profile *params.ProfileResponse) *pb.Profile {
+	message := &pb.Profile{
+		UserId: profile.UserID,
+		Phone:  profile.Phone,
+		Address: &pb.Address{
+			Line1:      profile.Address.Line1,
+			Line2:      profile.Address.Line2,
+			City:       profile.Address.City,
+			Region:     profile.Address.Region,
+			PostalCode: profile.Address.PostalCode,
+			Country:    profile.Address.Country,
+		},
+		Preferences: &pb.Preferences{},
+		UpdatedAt:   optionalTime(profile.UpdatedAt),
+	}
+	if profile.Preferences.NotificationChannel != "" {
+		message.Preferences.NotificationChannel = &profile.Preferences.NotificationChannel
+	}
+	if profile.Preferences.Language != "" {
+		message.Preferences.Language = &profile.Preferences.Language
+	}
+	if profile.Avatar != nil {
+		message.Avatar = &pb.Avatar{
+			ContentType: profile.Avatar.ContentType,
+			Size:        profile.Avatar.Size,
+		}
+	}
+	return message
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	pb "library-api-user/proto/role"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type RoleService struct {
+	pb.UnimplementedRoleServiceServer
+	RoleService services.RoleService
+}
+
+func NewRoleService(roleService services.RoleService) *RoleService {
+	return &RoleService{
+		RoleService: roleService,
+	}
+}
+
+func (s *RoleService) ListRoles(ctx context.Context, req *pb.ListRolesRequest) (*pb.ListRolesResponse, error) {
+	result, custErr := s.RoleService.GetAll(ctx)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	roles := make([]*pb.Role, len(result))
+	for i, role := range result {
+		roles[i] = toRoleMessage(role)
+	}
+
+	return &pb.ListRolesResponse{
+		Roles: roles,
+	}, nil
+}
+
+func (s *RoleService) CreateRole(ctx context.Context, req *pb.CreateRoleRequest) (*pb.Role, error) {
+	result, custErr := s.RoleService.Create(ctx, &params.RoleRequest{
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toRoleMessage(result), nil
+}
+
+func (s *RoleService) GrantPermission(ctx context.Context, req *pb.GrantPermissionRequest) (*pb.GrantPermissionResponse, error) {
+	custErr := s.RoleService.Grant(ctx, req.Role, &params.RolePermissionRequest{
+		Permission: req.Permission,
+	})
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.GrantPermissionResponse{
+		Success: true,
+		Message: "Success grant permission",
+	}, nil
+}
+
+func (s *RoleService) RevokePermission(ctx context.Context, req *pb.RevokePermissionRequest) (*pb.RevokePermissionResponse, error) {
+	custErr := s.RoleService.Revoke(ctx, req.Role, req.Permission)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.RevokePermissionResponse{
+		Success: true,
+		Message: "Success revoke permission",
+	}, nil
+}
+
+func (s *RoleService) ListPermissions(ctx context.Context, req *pb.ListPermissionsRequest) (*pb.ListPermissionsResponse, error) {
+	result, custErr := s.RoleService.Permissions(ctx)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	permissions := make([]*pb.Permission, len(result))
+	for i, permission := range result {
+		permissions[i] = &pb.Permission{
+			Id:          permission.ID,
+			Name:        permission.Name,
+			Description: permission.Description,
+		}
+	}
+
+	return &pb.ListPermissionsResponse{
+		Permissions: permissions,
+	}, nil
+}
+
+func toRoleMessage(role *params.RoleResponse) *pb.Role {
+	return &pb.Role{
+		Id:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		CreatedAt:   timestamppb.New(role.CreatedAt),
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/grpc/interceptors"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	pb "library-api-user/proto/user"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type UserService struct {
+	pb.UnimplementedUserServiceServer
+	UserService services.UserService
+}
+
+func NewUserService(userService services.UserService) *UserService {
+	return &UserService{
+		UserService: userService,
+	}
+}
+
+func (s *UserService) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	pagination, err := toPagination(req.Page, req.Limit, req.Cursor, req.IncludeTotal)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseFields(req.Fields, &pb.User{})
+	if err != nil {
+		return nil, err
+	}
+
+	query := params.UserListQuery{
+		Q:      req.Q,
+		Role:   req.Role,
+		Status: req.Status,
+		Sort:   req.Sort,
+	}
+	if query.CreatedFrom, err = parseTimestamp(req.CreatedFrom); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "created_from: "+err.Error())
+	}
+	if query.CreatedTo, err = parseTimestamp(req.CreatedTo); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "created_to: "+err.Error())
+	}
+
+	result, custErr := s.UserService.GetAll(ctx, &query, &pagination)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	users := make([]*pb.User, len(result))
+	for i, user := range result {
+		users[i] = toUserMessage(user)
+		fields.clear(users[i])
+	}
+
+	return &pb.ListUsersResponse{
+		Users:      users,
+		Pagination: toPaginationMessage(&pagination),
+	}, nil
+}
+
+func (s *UserService) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	actorID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, custErr := s.UserService.Create(ctx, &params.CreateUserRequest{
+		Email:    req.Email,
+		Password: req.Password,
+		Name:     req.Name,
+		Role:     req.Role,
+	}, actorID)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toUserMessage(result), nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	fields, err := parseFields(req.Fields, &pb.User{})
+	if err != nil {
+		return nil, err
+	}
+
+	// A client sending an ETag back is about to rely on it being current.
+	current := incomingHeader(ctx, "if-none-match") != "" || incomingHeader(ctx, "if-match") != ""
+
+	result, custErr := s.UserService.Detail(ctx, req.Id, current)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	setHeader(ctx, "etag", formatETag(result.UpdatedAt))
+	user := toUserMessage(result)
+	fields.clear(user)
+	return user, nil
+}
+
+// UpdateUser applies a partial update. An If-Match header carrying the
+// ETag from GetUser makes the update conditional.
+func (s *UserService) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	expectedUpdatedAt, ok := parseIfMatch(incomingHeader(ctx, "if-match"))
+	if !ok {
+		return nil, toStatusError(response.PreconditionFailedError("If-Match must be an ETag returned by this API"))
+	}
+
+	result, custErr := s.UserService.Update(ctx, req.Id, &params.UserPatchRequest{
+		Email:    req.Email,
+		Password: req.Password,
+		Name:     req.Name,
+		Role:     req.Role,
+	}, expectedUpdatedAt)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	setHeader(ctx, "etag", formatETag(result.UpdatedAt))
+	return &pb.UpdateUserResponse{
+		Success: true,
+		Message: "Success update user",
+		User:    toUserMessage(result),
+	}, nil
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	actorID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	custErr := s.UserService.Delete(ctx, req.Id, actorID, req.Reason, req.Erase)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.DeleteUserResponse{
+		Success: true,
+		Message: "Success delete user",
+	}, nil
+}
+
+func (s *UserService) SuspendUser(ctx context.Context, req *pb.ChangeUserStatusRequest) (*pb.ChangeUserStatusResponse, error) {
+	return s.changeStatus(ctx, req, s.UserService.Suspend, "Success suspend user")
+}
+
+func (s *UserService) ReactivateUser(ctx context.Context, req *pb.ChangeUserStatusRequest) (*pb.ChangeUserStatusResponse, error) {
+	return s.changeStatus(ctx, req, s.UserService.Reactivate, "Success reactivate user")
+}
+
+func (s *UserService) changeStatus(ctx context.Context, req *pb.ChangeUserStatusRequest, change func(ctx context.Context, id uint64, actorID uint64, reason string) *response.CustomError, message string) (*pb.ChangeUserStatusResponse, error) {
+	actorID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	custErr := change(ctx, req.Id, actorID, req.Reason)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.ChangeUserStatusResponse{
+		Success: true,
+		Message: message,
+	}, nil
+}
+
+func (s *UserService) ExportUser(ctx context.Context, req *pb.ExportUserRequest) (*pb.UserExport, error) {
+	return s.export(ctx, req.Id)
+}
+
+// GetMe returns the account of the caller in the self view.
+func (s *UserService) GetMe(ctx context.Context, req *pb.GetMeRequest) (*pb.UserSelf, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseFields(req.Fields, &pb.UserSelf{})
+	if err != nil {
+		return nil, err
+	}
+
+	result, custErr := s.UserService.Me(ctx, id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	user := &pb.UserSelf{
+		Id:        result.ID,
+		Email:     result.Email,
+		Name:      result.Name,
+		Role:      result.Role,
+		CreatedAt: timestamppb.New(result.CreatedAt),
+	}
+	fields.clear(user)
+	return user, nil
+}
+
+func (s *UserService) ExportMe(ctx context.Context, req *pb.ExportMeRequest) (*pb.UserExport, error) {
+	id, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.export(ctx, id)
+}
+
+// export sends the archive with a Content-Disposition header, so that the
+// gateway serves it as a JSON file download.
+func (s *UserService) export(ctx context.Context, id uint64) (*pb.UserExport, error) {
+	result, custErr := s.UserService.Export(ctx, id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	export := &pb.UserExport{
+		ExportedAt: timestamppb.New(result.ExportedAt),
+		Profile: &pb.User{
+			Id:        result.Profile.ID,
+			Email:     result.Profile.Email,
+			Name:      result.Profile.Name,
+			Role:      result.Profile.Role,
+			Status:    result.Profile.Status,
+			CreatedAt: timestamppb.New(result.Profile.CreatedAt),
+			UpdatedAt: timestamppb.New(result.Profile.UpdatedAt),
+		},
+		Loans:      toLoanMessages(result.Loans),
+		Activities: toActivityMessages(result.Activities),
+	}
+	if result.Contact != nil {
+		export.Contact = toProfileMessage(result.Contact)
+	}
+
+	setHeader(ctx, "content-disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, id))
+	return export, nil
+}
+
+// toUserMessage is the view of a user served to gRPC clients. Fields are
+// copied one by one, like the REST views.
+func toUserMessage(user *params.UserResponse) *pb.User {
+	return &pb.User{
+		Id:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		Status:    user.Status,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
+
+// callerID returns the account the authenticated call was made by.
+func callerID(ctx context.Context) (uint64, error) {
+	payload, ok := interceptors.AuthFromContext(ctx)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "missing authorization token")
+	}
+	return uint64(payload.AuthId), nil
+}
+
+// optionalTime converts a nullable time; nil stays unset.
+func optionalTime(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// parseTimestamp reads an optional RFC 3339 timestamp; empty means unset.
+func parseTimestamp(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"library-api-user/internal/commons/response"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// toValue converts JSON stored by the service, such as a webhook payload or
+// an audit diff, to a google.protobuf.Value. Missing JSON stays unset.
+func toValue(raw json.RawMessage) (*structpb.Value, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	value := &structpb.Value{}
+	if err := protojson.Unmarshal(raw, value); err != nil {
+		return nil, toStatusError(response.GeneralError("Failed to convert stored JSON: " + err.Error()))
+	}
+	return value, nil
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"library-api-user/internal/params"
+	"library-api-user/internal/services"
+	pb "library-api-user/proto/webhook"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type WebhookService struct {
+	pb.UnimplementedWebhookServiceServer
+	WebhookService services.WebhookService
+}
+
+func NewWebhookService(webhookService services.WebhookService) *WebhookService {
+	return &WebhookService{
+		WebhookService: webhookService,
+	}
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *pb.WebhookRequest) (*pb.Webhook, error) {
+	result, custErr := s.WebhookService.Create(ctx, &params.WebhookRequest{
+		URL:        req.Url,
+		EventTypes: req.EventTypes,
+		Active:     req.Active,
+	})
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toWebhookMessage(result), nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, req *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	result, custErr := s.WebhookService.GetAll(ctx)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	webhooks := make([]*pb.Webhook, len(result))
+	for i, webhook := range result {
+		webhooks[i] = toWebhookMessage(webhook)
+	}
+
+	return &pb.ListWebhooksResponse{
+		Webhooks: webhooks,
+	}, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, req *pb.GetWebhookRequest) (*pb.Webhook, error) {
+	result, custErr := s.WebhookService.Detail(ctx, req.Id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return toWebhookMessage(result), nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, req *pb.UpdateWebhookRequest) (*pb.UpdateWebhookResponse, error) {
+	custErr := s.WebhookService.Update(ctx, &params.WebhookRequest{
+		URL:        req.Url,
+		EventTypes: req.EventTypes,
+		Active:     req.Active,
+	}, req.Id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.UpdateWebhookResponse{
+		Success: true,
+		Message: "Success update webhook",
+	}, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, req *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	custErr := s.WebhookService.Delete(ctx, req.Id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.DeleteWebhookResponse{
+		Success: true,
+		Message: "Success delete webhook",
+	}, nil
+}
+
+// ListDeliveries lists deliveries of one subscription, or across
+// subscriptions when subscription_id is 0.
+func (s *WebhookService) ListDeliveries(ctx context.Context, req *pb.ListDeliveriesRequest) (*pb.ListDeliveriesResponse, error) {
+	pagination, err := toPagination(req.Page, req.Limit, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	result, custErr := s.WebhookService.Deliveries(ctx, req.SubscriptionId, req.Status, &pagination)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	deliveries := make([]*pb.WebhookDelivery, len(result))
+	for i, delivery := range result {
+		payload, err := toValue(delivery.Payload)
+		if err != nil {
+			return nil, err
+		}
+		deliveries[i] = &pb.WebhookDelivery{
+			Id:             delivery.ID,
+			SubscriptionId: delivery.SubscriptionID,
+			EventId:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        payload,
+			Status:         delivery.Status,
+			Attempts:       int32(delivery.Attempts),
+			NextAttemptAt:  timestamppb.New(delivery.NextAttemptAt),
+			LastStatusCode: int32(delivery.LastStatusCode),
+			LastError:      delivery.LastError,
+			CreatedAt:      timestamppb.New(delivery.CreatedAt),
+			DeliveredAt:    optionalTime(delivery.DeliveredAt),
+		}
+	}
+
+	return &pb.ListDeliveriesResponse{
+		Deliveries: deliveries,
+		Pagination: toPaginationMessage(&pagination),
+	}, nil
+}
+
+func (s *WebhookService) RedeliverWebhook(ctx context.Context, req *pb.RedeliverWebhookRequest) (*pb.RedeliverWebhookResponse, error) {
+	custErr := s.WebhookService.Redeliver(ctx, req.Id)
+	if custErr != nil {
+		return nil, toStatusError(custErr)
+	}
+
+	return &pb.RedeliverWebhookResponse{
+		Success: true,
+		Message: "Success requeue webhook delivery",
+	}, nil
+}
+
+// toWebhookMessage copies the secret only when the service returned it,
+// which it does on creation.
+func toWebhookMessage(webhook *params.WebhookResponse) *pb.Webhook {
+	message := &pb.Webhook{
+		Id:         webhook.ID,
+		Url:        webhook.URL,
+		EventTypes: webhook.EventTypes,
+		Active:     webhook.Active,
+		CreatedAt:  timestamppb.New(webhook.CreatedAt),
+		UpdatedAt:  timestamppb.New(webhook.UpdatedAt),
+	}
+	if webhook.Secret != "" {
+		message.Secret = &webhook.Secret
+	}
+	return message
+}
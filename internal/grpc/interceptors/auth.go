@@ -0,0 +1,150 @@
+package interceptors
+
+import (
+	"context"
+	"library-api-user/internal/audit"
+	"library-api-user/internal/models"
+	"library-api-user/internal/rbac"
+	"library-api-user/pkg/token"
+	auditpb "library-api-user/proto/audit"
+	"library-api-user/proto/auth"
+	"library-api-user/proto/loan"
+	"library-api-user/proto/role"
+	"library-api-user/proto/user"
+	"library-api-user/proto/webhook"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type authPayloadKey struct{}
+
+// publicMethods can be called without a token. Every other method requires
+// a valid token: authenticatedMethods need nothing more, while
+// methodPermissions additionally require the listed permission to be
+// granted to the caller's role. A method in none of them is refused, so a
+// new method cannot be served before its access is declared.
+var (
+	publicMethods = map[string]bool{
+		auth.AuthService_ValidateToken_FullMethodName: true,
+		auth.AuthService_Register_FullMethodName:      true,
+		auth.AuthService_Login_FullMethodName:         true,
+	}
+	publicPrefixes = []string{
+		"/grpc.health.v1.Health/",
+		"/grpc.reflection.",
+	}
+	authenticatedMethods = map[string]bool{
+		user.UserService_GetMe_FullMethodName:              true,
+		user.UserService_ExportMe_FullMethodName:           true,
+		user.ProfileService_GetMyProfile_FullMethodName:    true,
+		user.ProfileService_UpdateMyProfile_FullMethodName: true,
+		user.ProfileService_GetMyAvatar_FullMethodName:     true,
+		user.ProfileService_UploadMyAvatar_FullMethodName:  true,
+		user.ProfileService_DeleteMyAvatar_FullMethodName:  true,
+	}
+	methodPermissions = map[string]string{
+		user.UserService_ListUsers_FullMethodName:              rbac.UsersRead,
+		user.UserService_CreateUser_FullMethodName:             rbac.UsersWrite,
+		user.UserService_GetUser_FullMethodName:                rbac.UsersRead,
+		user.UserService_UpdateUser_FullMethodName:             rbac.UsersWrite,
+		user.UserService_DeleteUser_FullMethodName:             rbac.UsersWrite,
+		user.UserService_SuspendUser_FullMethodName:            rbac.UsersWrite,
+		user.UserService_ReactivateUser_FullMethodName:         rbac.UsersWrite,
+		user.UserService_ExportUser_FullMethodName:             rbac.UsersRead,
+		user.ProfileService_GetProfile_FullMethodName:          rbac.UsersRead,
+		user.ProfileService_UpdateProfile_FullMethodName:       rbac.UsersWrite,
+		user.ProfileService_GetAvatar_FullMethodName:           rbac.UsersRead,
+		loan.LoanService_ListLoans_FullMethodName:              rbac.LoansRead,
+		loan.LoanService_BorrowBook_FullMethodName:             rbac.LoansWrite,
+		loan.LoanService_ReturnBook_FullMethodName:             rbac.LoansWrite,
+		loan.LoanService_ListActivities_FullMethodName:         rbac.LoansRead,
+		webhook.WebhookService_CreateWebhook_FullMethodName:    rbac.WebhooksManage,
+		webhook.WebhookService_ListWebhooks_FullMethodName:     rbac.WebhooksManage,
+		webhook.WebhookService_GetWebhook_FullMethodName:       rbac.WebhooksManage,
+		webhook.WebhookService_UpdateWebhook_FullMethodName:    rbac.WebhooksManage,
+		webhook.WebhookService_DeleteWebhook_FullMethodName:    rbac.WebhooksManage,
+		webhook.WebhookService_ListDeliveries_FullMethodName:   rbac.WebhooksManage,
+		webhook.WebhookService_RedeliverWebhook_FullMethodName: rbac.WebhooksManage,
+		role.RoleService_ListRoles_FullMethodName:              rbac.RolesManage,
+		role.RoleService_CreateRole_FullMethodName:             rbac.RolesManage,
+		role.RoleService_GrantPermission_FullMethodName:        rbac.RolesManage,
+		role.RoleService_RevokePermission_FullMethodName:       rbac.RolesManage,
+		role.RoleService_ListPermissions_FullMethodName:        rbac.RolesManage,
+		auditpb.AuditService_ListAuditEvents_FullMethodName:    rbac.AuditRead,
+		auditpb.AuditService_VerifyAuditChain_FullMethodName:   rbac.AuditRead,
+	}
+)
+
+// AccountChecker returns the current role and permissions of the account a
+// token was issued for, or nil when it may no longer be used.
+type AccountChecker interface {
+	Access(ctx context.Context, userID uint64) (*models.Access, error)
+}
+
+func AuthUnaryInterceptor(accounts AccountChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isPublic(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing authorization token")
+		}
+
+		bearerToken := strings.Split(values[0], "Bearer ")
+		if len(bearerToken) != 2 {
+			return nil, status.Error(codes.Unauthenticated, "len token must be 2")
+		}
+
+		payload, err := token.ValidateToken(bearerToken[1])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		access, err := accounts.Access(ctx, uint64(payload.AuthId))
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to check account status: "+err.Error())
+		}
+		if access == nil {
+			return nil, status.Error(codes.Unauthenticated, "account is suspended or deleted")
+		}
+		// Check against the current grants, not those at login.
+		payload.Role = access.Role
+		payload.Permissions = access.Permissions
+
+		if permission, ok := methodPermissions[info.FullMethod]; ok {
+			if !rbac.HasPermission(payload.Permissions, permission) {
+				return nil, status.Error(codes.PermissionDenied, "user doesn't have permission to access")
+			}
+		} else if !authenticatedMethods[info.FullMethod] {
+			return nil, status.Error(codes.PermissionDenied, "method has no declared access requirement")
+		}
+
+		ctx = audit.WithActor(ctx, audit.Actor{ID: uint64(payload.AuthId), Role: payload.Role})
+		return handler(context.WithValue(ctx, authPayloadKey{}, payload), req)
+	}
+}
+
+// AuthFromContext returns the token payload stored by AuthUnaryInterceptor.
+func AuthFromContext(ctx context.Context) (*token.Token, bool) {
+	payload, ok := ctx.Value(authPayloadKey{}).(*token.Token)
+	return payload, ok
+}
+
+func isPublic(method string) bool {
+	if publicMethods[method] {
+		return true
+	}
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package interceptors
+
+import (
+	"context"
+	"library-api-user/internal/models"
+	"library-api-user/internal/rbac"
+	"library-api-user/pkg/token"
+	auditpb "library-api-user/proto/audit"
+	"library-api-user/proto/auth"
+	"library-api-user/proto/loan"
+	"library-api-user/proto/role"
+	"library-api-user/proto/user"
+	"library-api-user/proto/webhook"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Who may call a method, independently of what the interceptor declares.
+const (
+	anyone  = "anyone"
+	signed  = "signed in"
+	loaners = "loans"
+	admins  = "admin"
+)
+
+// expectedAccess is the access matrix of the API; both REST versions reach
+// these methods through the gateway. Every method of every served service
+// must be listed, so a new method cannot be added without stating who may
+// call it.
+var expectedAccess = map[string]string{
+	auth.AuthService_Register_FullMethodName:      anyone,
+	auth.AuthService_Login_FullMethodName:         anyone,
+	auth.AuthService_ValidateToken_FullMethodName: anyone,
+
+	user.UserService_ListUsers_FullMethodName:      admins,
+	user.UserService_CreateUser_FullMethodName:     admins,
+	user.UserService_GetUser_FullMethodName:        admins,
+	user.UserService_UpdateUser_FullMethodName:     admins,
+	user.UserService_DeleteUser_FullMethodName:     admins,
+	user.UserService_SuspendUser_FullMethodName:    admins,
+	user.UserService_ReactivateUser_FullMethodName: admins,
+	user.UserService_ExportUser_FullMethodName:     admins,
+	user.UserService_GetMe_FullMethodName:          signed,
+	user.UserService_ExportMe_FullMethodName:       signed,
+
+	user.ProfileService_GetProfile_FullMethodName:      admins,
+	user.ProfileService_UpdateProfile_FullMethodName:   admins,
+	user.ProfileService_GetAvatar_FullMethodName:       admins,
+	user.ProfileService_GetMyProfile_FullMethodName:    signed,
+	user.ProfileService_UpdateMyProfile_FullMethodName: signed,
+	user.ProfileService_GetMyAvatar_FullMethodName:     signed,
+	user.ProfileService_UploadMyAvatar_FullMethodName:  signed,
+	user.ProfileService_DeleteMyAvatar_FullMethodName:  signed,
+
+	loan.LoanService_ListLoans_FullMethodName:      loaners,
+	loan.LoanService_BorrowBook_FullMethodName:     loaners,
+	loan.LoanService_ReturnBook_FullMethodName:     loaners,
+	loan.LoanService_ListActivities_FullMethodName: loaners,
+
+	webhook.WebhookService_CreateWebhook_FullMethodName:    admins,
+	webhook.WebhookService_ListWebhooks_FullMethodName:     admins,
+	webhook.WebhookService_GetWebhook_FullMethodName:       admins,
+	webhook.WebhookService_UpdateWebhook_FullMethodName:    admins,
+	webhook.WebhookService_DeleteWebhook_FullMethodName:    admins,
+	webhook.WebhookService_ListDeliveries_FullMethodName:   admins,
+	webhook.WebhookService_RedeliverWebhook_FullMethodName: admins,
+
+	role.RoleService_ListRoles_FullMethodName:        admins,
+	role.RoleService_CreateRole_FullMethodName:       admins,
+	role.RoleService_GrantPermission_FullMethodName:  admins,
+	role.RoleService_RevokePermission_FullMethodName: admins,
+	role.RoleService_ListPermissions_FullMethodName:  admins,
+
+	auditpb.AuditService_ListAuditEvents_FullMethodName:  admins,
+	auditpb.AuditService_VerifyAuditChain_FullMethodName: admins,
+}
+
+// servedServices are the services registered on the gRPC server.
+var servedServices = []grpc.ServiceDesc{
+	auth.AuthService_ServiceDesc,
+	user.UserService_ServiceDesc,
+	user.ProfileService_ServiceDesc,
+	loan.LoanService_ServiceDesc,
+	webhook.WebhookService_ServiceDesc,
+	role.RoleService_ServiceDesc,
+	auditpb.AuditService_ServiceDesc,
+}
+
+// accounts holds the accounts of the test, with the grants seeded by the
+// RBAC migration.
+type accounts map[uint64]*models.Access
+
+func (a accounts) Access(ctx context.Context, userID uint64) (*models.Access, error) {
+	return a[userID], nil
+}
+
+const (
+	userID uint64 = iota + 1
+	authorID
+	adminID
+	ungrantedID
+	suspendedID
+)
+
+var testAccounts = accounts{
+	userID:   {Role: "user", Permissions: []string{rbac.LoansRead, rbac.LoansWrite}},
+	authorID: {Role: "author", Permissions: []string{rbac.LoansRead, rbac.LoansWrite}},
+	adminID:  {Role: "admin", Permissions: rbac.All},
+	// A role created by an administrator and not granted anything yet.
+	ungrantedID: {Role: "guest", Permissions: []string{}},
+}
+
+func bearer(t *testing.T, id uint64, role string, permissions []string) string {
+	t.Helper()
+	token.SetKey("interceptor-test-signing-key-of-32-chars")
+	signedToken, err := token.GenerateToken(int(id), role, permissions)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	return "Bearer " + signedToken
+}
+
+// call runs method through the interceptor with a handler that always
+// succeeds, so only access is checked.
+func call(method string, authorization string) codes.Code {
+	ctx := context.Background()
+	if authorization != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+	}
+
+	interceptor := AuthUnaryInterceptor(testAccounts)
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	return status.Code(err)
+}
+
+func TestAuthUnaryInterceptorAccess(t *testing.T) {
+	callers := []struct {
+		name          string
+		authorization string
+		role          string
+	}{
+		{"no token", "", ""},
+		{"user", bearer(t, userID, "user", nil), "user"},
+		{"author", bearer(t, authorID, "author", nil), "author"},
+		{"admin", bearer(t, adminID, "admin", nil), "admin"},
+		{"guest", bearer(t, ungrantedID, "guest", nil), "guest"},
+	}
+
+	served := map[string]bool{}
+	for _, service := range servedServices {
+		for _, method := range service.Methods {
+			name := "/" + service.ServiceName + "/" + method.MethodName
+			served[name] = true
+
+			who, ok := expectedAccess[name]
+			if !ok {
+				t.Errorf("%s: missing from expectedAccess", name)
+				continue
+			}
+
+			for _, caller := range callers {
+				t.Run(name+"/"+caller.name, func(t *testing.T) {
+					want := expectedCode(who, caller.role)
+					if got := call(name, caller.authorization); got != want {
+						t.Errorf("code = %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	}
+
+	for name := range expectedAccess {
+		if !served[name] {
+			t.Errorf("%s: listed in expectedAccess but not served", name)
+		}
+	}
+}
+
+func expectedCode(who string, role string) codes.Code {
+	switch {
+	case who == anyone:
+		return codes.OK
+	case role == "":
+		return codes.Unauthenticated
+	case who == signed:
+		return codes.OK
+	case who == loaners && role != "guest":
+		return codes.OK
+	case who == admins && role == "admin":
+		return codes.OK
+	}
+	return codes.PermissionDenied
+}
+
+// TestAuthUnaryInterceptorUsesCurrentGrants checks that the role and
+// permissions of the account are checked, not those embedded in the token
+// at login.
+func TestAuthUnaryInterceptorUsesCurrentGrants(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          codes.Code
+	}{
+		{"demoted since login", bearer(t, userID, "admin", rbac.All), codes.PermissionDenied},
+		{"promoted since login", bearer(t, adminID, "user", nil), codes.OK},
+		{"suspended since login", bearer(t, suspendedID, "admin", rbac.All), codes.Unauthenticated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := call(user.UserService_ListUsers_FullMethodName, test.authorization); got != test.want {
+				t.Errorf("code = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthUnaryInterceptorRefusesUndeclaredMethods(t *testing.T) {
+	got := call("/user.UserService/Undeclared", bearer(t, adminID, "admin", rbac.All))
+	if got != codes.PermissionDenied {
+		t.Errorf("code = %v, want %v", got, codes.PermissionDenied)
+	}
+}
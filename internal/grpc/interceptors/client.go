@@ -0,0 +1,45 @@
+package interceptors
+
+import (
+	"context"
+	"library-api-user/internal/audit"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ClientInfoUnaryInterceptor stores the client IP and user agent on the
+// context for the audit recorder. Calls relayed by the REST gateway come
+// from loopback; for those the address the gateway appended to
+// x-forwarded-for and the user agent it forwarded are used instead.
+func ClientInfoUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		var client audit.Client
+		if p, ok := peer.FromContext(ctx); ok {
+			client.IP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(client.IP); err == nil {
+				client.IP = host
+			}
+		}
+		if values := md.Get("user-agent"); len(values) > 0 {
+			client.UserAgent = values[0]
+		}
+
+		if ip := net.ParseIP(client.IP); ip != nil && ip.IsLoopback() {
+			if values := md.Get("x-forwarded-for"); len(values) > 0 {
+				forwarded := strings.Split(values[len(values)-1], ",")
+				client.IP = strings.TrimSpace(forwarded[len(forwarded)-1])
+			}
+			if values := md.Get("grpcgateway-user-agent"); len(values) > 0 {
+				client.UserAgent = values[0]
+			}
+		}
+
+		return handler(audit.WithClient(ctx, client), req)
+	}
+}
@@ -0,0 +1,43 @@
+package interceptors
+
+import (
+	"context"
+	"library-api-user/internal/metrics"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsUnaryInterceptor records the count and latency of every call by
+// method and status code. operations maps full method names to the
+// business operation the method performs; a call returning OK counts as a
+// success.
+func MetricsUnaryInterceptor(operations map[string]*metrics.Operation) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		metrics.GRPCRequests.WithLabelValues(info.FullMethod, code.String()).Inc()
+		metrics.GRPCDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+
+		if operation, ok := operations[info.FullMethod]; ok {
+			operation.Observe(err == nil)
+		}
+		return resp, err
+	}
+}
+
+// MetricsUnaryClientInterceptor records the count and latency of calls made
+// to another service, such as the book service, by method and status code.
+func MetricsUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		metrics.GRPCClientRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+		metrics.GRPCClientDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,42 @@
+package interceptors
+
+import (
+	"context"
+	"library-api-user/internal/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDMetadata = "x-request-id"
+
+// RequestIDUnaryInterceptor keeps the x-request-id of the caller, which the
+// gateway forwards from the HTTP request, or assigns a new one. The ID is
+// stored on the context for the logger and sent back as a header.
+func RequestIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		var id string
+		if values := md.Get(requestIDMetadata); len(values) > 0 && len(values[0]) <= 128 {
+			id = values[0]
+		}
+		if id == "" {
+			id = logger.NewRequestID()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+
+		return handler(logger.WithRequestID(ctx, id), req)
+	}
+}
+
+// RequestIDUnaryClientInterceptor passes the request ID on the context to
+// the called service.
+func RequestIDUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := logger.RequestIDFromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadata, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,186 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/grpc/client"
+	auditpb "library-api-user/proto/audit"
+	"library-api-user/proto/auth"
+	"library-api-user/proto/loan"
+	"library-api-user/proto/role"
+	"library-api-user/proto/user"
+	"library-api-user/proto/webhook"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Dependencies probed by the monitor, as named on /healthz and /readyz.
+const (
+	DependencyPostgres = "postgres"
+	DependencyBook     = "book.BookService"
+)
+
+// critical lists the dependencies without which no request can be served.
+// When another dependency is down the service is degraded: only the calls
+// needing it fail, so it stays ready.
+var critical = map[string]bool{
+	DependencyPostgres: true,
+}
+
+const (
+	checkInterval = 10 * time.Second
+	checkTimeout  = 3 * time.Second
+)
+
+// services lists the gRPC services served with the dependencies each one
+// needs to answer requests.
+var services = map[string][]string{
+	auth.AuthService_ServiceDesc.ServiceName:       {DependencyPostgres},
+	user.UserService_ServiceDesc.ServiceName:       {DependencyPostgres},
+	user.ProfileService_ServiceDesc.ServiceName:    {DependencyPostgres},
+	loan.LoanService_ServiceDesc.ServiceName:       {DependencyPostgres, DependencyBook},
+	webhook.WebhookService_ServiceDesc.ServiceName: {DependencyPostgres},
+	role.RoleService_ServiceDesc.ServiceName:       {DependencyPostgres},
+	auditpb.AuditService_ServiceDesc.ServiceName:   {DependencyPostgres},
+}
+
+type Checker func(ctx context.Context) error
+
+type DependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Monitor periodically probes the dependencies of the service and mirrors
+// their state into the gRPC health server and the HTTP probe endpoints.
+type Monitor struct {
+	server   *health.Server
+	checkers map[string]Checker
+
+	mu           sync.RWMutex
+	statuses     map[string]error
+	shuttingDown bool
+}
+
+func NewMonitor(db *sql.DB, bookClient *client.BookClient) *Monitor {
+	monitor := &Monitor{
+		server: health.NewServer(),
+		checkers: map[string]Checker{
+			DependencyPostgres: db.PingContext,
+			DependencyBook:     bookClient.CheckConnection,
+		},
+		statuses: make(map[string]error),
+	}
+
+	monitor.CheckNow(context.Background())
+
+	return monitor
+}
+
+// Server returns the gRPC health service to be registered on the gRPC server.
+func (m *Monitor) Server() *health.Server {
+	return m.server
+}
+
+// Run re-checks every dependency on a fixed interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.CheckNow(ctx)
+		}
+	}
+}
+
+// CheckNow probes every dependency and reports each served gRPC service as
+// serving when all the dependencies it needs are up. The whole server ("")
+// is serving when every critical dependency is up.
+func (m *Monitor) CheckNow(ctx context.Context) {
+	results := make(map[string]error, len(m.checkers))
+	for name, check := range m.checkers {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		results[name] = check(checkCtx)
+		cancel()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.statuses = results
+
+	for service, dependencies := range services {
+		m.server.SetServingStatus(service, servingStatus(results, dependencies))
+	}
+	required := make([]string, 0, len(critical))
+	for name := range critical {
+		required = append(required, name)
+	}
+	m.server.SetServingStatus("", servingStatus(results, required))
+}
+
+func servingStatus(results map[string]error, dependencies []string) healthpb.HealthCheckResponse_ServingStatus {
+	for _, dependency := range dependencies {
+		if results[dependency] != nil {
+			return healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+// Shutdown reports the service as not serving from now on, over gRPC and
+// on /readyz, so that load balancers stop sending it requests while the
+// servers drain. Later checks do not change that.
+func (m *Monitor) Shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.shuttingDown = true
+	m.server.Shutdown()
+}
+
+// Ready reports whether every critical dependency is reachable and the
+// service is not shutting down.
+func (m *Monitor) Ready() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.shuttingDown {
+		return false
+	}
+
+	for name, err := range m.statuses {
+		if err != nil && critical[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// Statuses reports each dependency as up, down when it is critical, or
+// degraded otherwise.
+func (m *Monitor) Statuses() map[string]DependencyStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make(map[string]DependencyStatus, len(m.statuses))
+	for name, err := range m.statuses {
+		if err != nil {
+			status := "degraded"
+			if critical[name] {
+				status = "down"
+			}
+			statuses[name] = DependencyStatus{Status: status, Error: err.Error()}
+			continue
+		}
+		statuses[name] = DependencyStatus{Status: "up"}
+	}
+	return statuses
+}
@@ -0,0 +1,170 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Manager runs the servers and background workers of the process and
+// stops everything in order when SIGINT or SIGTERM arrives, or when a
+// server fails:
+//
+//  1. the OnShutdown hooks run, e.g. to report the service as not ready,
+//  2. after the drain delay, servers stop taking requests and finish the
+//     ones in flight, one after another in the order they were added,
+//  3. background workers are cancelled and awaited,
+//  4. resources are closed in the order they were added.
+//
+// Steps 2 and 3 share one deadline. Resources are closed even if it
+// passes, so connections are not leaked on a slow shutdown.
+type Manager struct {
+	Timeout    time.Duration
+	DrainDelay time.Duration
+
+	hooks   []func()
+	servers []server
+	closers []closer
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+type server struct {
+	name     string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+func New(timeout, drainDelay time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		Timeout:    timeout,
+		DrainDelay: drainDelay,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Context is cancelled once the servers have stopped. Background work that
+// is not started through Go can use it to stop too.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnShutdown adds fn to run as soon as shutdown starts.
+func (m *Manager) OnShutdown(fn func()) {
+	m.hooks = append(m.hooks, fn)
+}
+
+// Serve adds a server. serve blocks until the server stops and should
+// return nil when it was stopped by shutdown. shutdown must stop taking
+// new requests and return once the ones in flight are done, or when ctx
+// is done.
+func (m *Manager) Serve(name string, serve func() error, shutdown func(ctx context.Context) error) {
+	m.servers = append(m.servers, server{name: name, serve: serve, shutdown: shutdown})
+}
+
+// Go runs a background worker with a context that is cancelled after the
+// servers have stopped, so work queued by the last requests is still
+// picked up.
+func (m *Manager) Go(run func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		run(m.ctx)
+	}()
+}
+
+// Close adds a resource to close after the servers and workers stopped.
+func (m *Manager) Close(name string, fn func() error) {
+	m.closers = append(m.closers, closer{name: name, close: fn})
+}
+
+// Run starts the servers and blocks until the process has shut down. It
+// returns the error of a failed server, or of a shutdown that did not
+// finish within the timeout.
+func (m *Manager) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	failed := make(chan error, len(m.servers))
+	for _, s := range m.servers {
+		go func(s server) {
+			if err := s.serve(); err != nil {
+				failed <- fmt.Errorf("%s: %w", s.name, err)
+			}
+		}(s)
+	}
+
+	var runErr error
+	select {
+	case sig := <-signals:
+		log.Printf("[Lifecycle] Received %s, shutting down\n", sig)
+	case runErr = <-failed:
+		log.Printf("[Lifecycle] %v, shutting down\n", runErr)
+	}
+
+	for _, hook := range m.hooks {
+		hook()
+	}
+	// Give load balancers time to see the service is not ready before
+	// new connections are refused. A failed server skips the wait.
+	if runErr == nil && m.DrainDelay > 0 {
+		select {
+		case <-time.After(m.DrainDelay):
+		case sig := <-signals:
+			log.Printf("[Lifecycle] Received %s again, skipping the drain delay\n", sig)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	shutdownErr := m.stop(ctx)
+
+	for _, c := range m.closers {
+		if err := c.close(); err != nil {
+			log.Printf("[Lifecycle] Failed to close %s: %v\n", c.name, err)
+		}
+	}
+
+	log.Println("[Lifecycle] Shutdown complete")
+	return errors.Join(runErr, shutdownErr)
+}
+
+// stop shuts the servers down and then the workers, within ctx.
+func (m *Manager) stop(ctx context.Context) error {
+	var errs []error
+	for _, s := range m.servers {
+		if err := s.shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down %s: %w", s.name, err))
+		}
+	}
+
+	m.cancel()
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background workers did not stop: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"library-api-user/internal/audit"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 128-bit ID in hex.
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// contextFields are added to every line logged with the context. The caller
+// is logged as actor_id, so that it does not clash with the user_id of the
+// user a service call is about.
+func contextFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if actor, ok := audit.ActorFromContext(ctx); ok {
+		fields["actor_id"] = actor.ID
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields["trace_id"] = span.TraceID().String()
+		fields["span_id"] = span.SpanID().String()
+	}
+	return fields
+}
@@ -1,13 +1,15 @@
 package logger
 
 import (
-	"fmt"
+	"context"
 	"io"
 	"library-api-user/internal/config"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type Logger interface {
@@ -15,41 +17,102 @@ type Logger interface {
 	Error(message string, fields map[string]interface{})
 	Warn(message string, fields map[string]interface{})
 	Debug(message string, fields map[string]interface{})
+	// WithContext returns a logger adding the request ID, the ID of the
+	// authenticated user and the trace and span IDs found on ctx to every
+	// entry, so the lines of one request can be found together.
+	WithContext(ctx context.Context) Logger
 }
 
 type LoggerImpl struct {
 	*logrus.Logger
 	LogFile string
+
+	file      *lumberjack.Logger
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
+// NewLogger logs to stdout in development. Otherwise entries also go to
+// logFile, which is rotated once it reaches LOG_MAX_SIZE_MB and every
+// LOG_ROTATE_INTERVAL; rotated files are kept for LOG_MAX_AGE_DAYS, at
+// most LOG_MAX_BACKUPS of them. Fields with sensitive names are redacted
+// in every entry.
 func NewLogger(logFile string) (Logger, error) {
 	log := logrus.New()
 	log.SetFormatter(&logrus.JSONFormatter{
 		TimestampFormat: time.RFC3339,
 	})
+	log.AddHook(redactHook{})
+
+	l := &LoggerImpl{
+		Logger:  log,
+		LogFile: logFile,
+	}
 
-	env := config.ENV.Environtment
+	env := config.ENV.Environment
 
 	if env == "development" {
 		// Logs to console in development mode
 		log.SetOutput(os.Stdout)
 		log.SetLevel(logrus.DebugLevel)
 	} else {
-		// Write logs to a file in production mode
-		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
+		// Write logs to a file in production mode. lumberjack creates it
+		// readable by the owner only.
+		l.file = &lumberjack.Logger{
+			Filename:   logFile,
+			MaxSize:    config.ENV.LogMaxSizeMB,
+			MaxAge:     config.ENV.LogMaxAgeDays,
+			MaxBackups: config.ENV.LogMaxBackups,
+			Compress:   config.ENV.LogCompress,
 		}
 
-		multiWriter := io.MultiWriter(file, os.Stdout)
+		multiWriter := io.MultiWriter(l.file, os.Stdout)
 		log.SetOutput(multiWriter)
 		log.SetLevel(logrus.InfoLevel)
+
+		if interval, _ := time.ParseDuration(config.ENV.LogRotateEvery); interval > 0 {
+			l.stop = make(chan struct{})
+			go l.rotateEvery(interval, l.stop)
+		}
 	}
 
-	return &LoggerImpl{
-		Logger:  log,
-		LogFile: logFile,
-	}, nil
+	return l, nil
+}
+
+// rotateEvery is given the stop channel rather than reading l.stop, so
+// that it never races with Close.
+func (l *LoggerImpl) rotateEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// An empty file has nothing to rotate out.
+			if info, err := os.Stat(l.LogFile); err != nil || info.Size() == 0 {
+				continue
+			}
+			if err := l.file.Rotate(); err != nil {
+				l.WithError(err).Error("[Logger] Failed to rotate log file")
+			}
+		}
+	}
+}
+
+// Close stops the time based rotation and closes the log file. Later
+// calls do nothing.
+func (l *LoggerImpl) Close() (err error) {
+	l.closeOnce.Do(func() {
+		if l.stop != nil {
+			close(l.stop)
+		}
+		if l.file != nil {
+			err = l.file.Close()
+		}
+	})
+	return err
 }
 
 func (l *LoggerImpl) Info(message string, fields map[string]interface{}) {
@@ -67,3 +130,32 @@ func (l *LoggerImpl) Warn(message string, fields map[string]interface{}) {
 func (l *LoggerImpl) Debug(message string, fields map[string]interface{}) {
 	l.WithFields(logrus.Fields(fields)).Debug(message)
 }
+
+func (l *LoggerImpl) WithContext(ctx context.Context) Logger {
+	return &entryLogger{entry: l.WithFields(contextFields(ctx))}
+}
+
+// entryLogger is a Logger with fields taken from a context.
+type entryLogger struct {
+	entry *logrus.Entry
+}
+
+func (l *entryLogger) Info(message string, fields map[string]interface{}) {
+	l.entry.WithFields(logrus.Fields(fields)).Info(message)
+}
+
+func (l *entryLogger) Error(message string, fields map[string]interface{}) {
+	l.entry.WithFields(logrus.Fields(fields)).Error(message)
+}
+
+func (l *entryLogger) Warn(message string, fields map[string]interface{}) {
+	l.entry.WithFields(logrus.Fields(fields)).Warn(message)
+}
+
+func (l *entryLogger) Debug(message string, fields map[string]interface{}) {
+	l.entry.WithFields(logrus.Fields(fields)).Debug(message)
+}
+
+func (l *entryLogger) WithContext(ctx context.Context) Logger {
+	return &entryLogger{entry: l.entry.WithFields(contextFields(ctx))}
+}
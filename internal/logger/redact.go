@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const redactedValue = "[redacted]"
+
+// sensitiveKeys are parts of field names whose values are never written,
+// e.g. password, new_password, access_token or Authorization.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+
+// redactHook masks the values of sensitive fields, also in maps nested in
+// a field, before an entry is written.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	// The entry holds a copy of the fields of the logger it came from, so
+	// they can be changed in place.
+	for key, value := range entry.Data {
+		entry.Data[key] = redact(key, value)
+	}
+	return nil
+}
+
+func redact(key string, value interface{}) interface{} {
+	if sensitive(key) {
+		return redactedValue
+	}
+	switch nested := value.(type) {
+	case map[string]interface{}:
+		return redactMap(nested)
+	case logrus.Fields:
+		return redactMap(nested)
+	}
+	return value
+}
+
+func redactMap(fields map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		redacted[key] = redact(key, value)
+	}
+	return redacted
+}
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "library_user"
+
+// Registry holds every metric of the service, together with the Go runtime
+// and process metrics. It is served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC calls handled, by method and status code.",
+	}, []string{"method", "code"})
+	GRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Time taken to handle gRPC calls, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	GRPCClientRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_client_requests_total",
+		Help:      "gRPC calls made to other services, such as the book service, by method and status code.",
+	}, []string{"method", "code"})
+	GRPCClientDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_client_request_duration_seconds",
+		Help:      "Time taken by gRPC calls made to other services, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// Business operations counted by outcome. The HTTP middleware and the gRPC
+// interceptor observe them for the routes and methods mapped to them, so
+// the services stay free of metrics code.
+var (
+	Registrations = newOperation("registrations_total", "Account registrations, by result.")
+	Logins        = newOperation("logins_total", "Logins, by result.")
+	Borrows       = newOperation("borrows_total", "Books borrowed, by result.")
+	Returns       = newOperation("returns_total", "Books returned, by result.")
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration,
+		GRPCRequests, GRPCDuration,
+		GRPCClientRequests, GRPCClientDuration,
+		Registrations.counter, Logins.counter, Borrows.counter, Returns.counter,
+	)
+}
+
+// Operation counts the successful and failed attempts of a business
+// operation.
+type Operation struct {
+	counter *prometheus.CounterVec
+}
+
+func newOperation(name, help string) *Operation {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, []string{"result"})
+	// Export both series from the start, so rates over them are defined
+	// before the first failure.
+	counter.WithLabelValues("success")
+	counter.WithLabelValues("failure")
+	return &Operation{counter: counter}
+}
+
+func (o *Operation) Observe(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	o.counter.WithLabelValues(result).Inc()
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with
+// name.
+func RegisterDB(name string, db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics of Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"library-api-user/internal/logger"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quietPaths are polled by probes; their requests are only
+// logged at debug level.
+var quietPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// AccessLog writes one structured entry per request through log, carrying
+// the request ID, user ID and trace ID of the request. Server errors are
+// logged as errors.
+func AccessLog(log logger.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		size := ctx.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		fields := map[string]interface{}{
+			"method":     ctx.Request.Method,
+			"path":       ctx.Request.URL.Path,
+			"route":      ctx.FullPath(),
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"bytes":      size,
+			"client_ip":  ctx.ClientIP(),
+			"user_agent": ctx.Request.UserAgent(),
+		}
+		if len(ctx.Errors) > 0 {
+			fields["error"] = ctx.Errors.String()
+		}
+
+		requestLog := log.WithContext(ctx.Request.Context())
+		switch {
+		case status >= http.StatusInternalServerError:
+			requestLog.Error("[HTTP] Request failed", fields)
+		case quietPaths[ctx.Request.URL.Path]:
+			requestLog.Debug("[HTTP] Request handled", fields)
+		default:
+			requestLog.Info("[HTTP] Request handled", fields)
+		}
+	}
+}
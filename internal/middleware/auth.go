@@ -1,93 +1,126 @@
 package middleware
 
 import (
+	"context"
+	"library-api-user/internal/audit"
 	"library-api-user/internal/commons/response"
+	"library-api-user/internal/models"
+	"library-api-user/internal/rbac"
 	"library-api-user/pkg/token"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CheckAuth() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		header := ctx.GetHeader("Authorization")
+// AccountChecker returns the current role and permissions of the account a
+// token was issued for, or nil when it may no longer be used, so that
+// suspended or deleted accounts are locked out and grant changes apply
+// before the token expires.
+type AccountChecker interface {
+	Access(ctx context.Context, userID uint64) (*models.Access, error)
+}
 
-		bearerToken := strings.Split(header, "Bearer ")
+type Auth struct {
+	Accounts AccountChecker
+}
 
-		if len(bearerToken) != 2 {
-			resp := response.UnauthorizedErrorWithAdditionalInfo("len token must be 2")
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
-			return
-		}
+func NewAuth(accounts AccountChecker) *Auth {
+	return &Auth{
+		Accounts: accounts,
+	}
+}
 
-		payload, err := token.ValidateToken(bearerToken[1])
-		if err != nil {
-			resp := response.UnauthorizedErrorWithAdditionalInfo(err.Error())
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+func (auth *Auth) CheckAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := auth.authenticate(ctx); !ok {
 			return
 		}
-		ctx.Set("authId", payload.AuthId)
-		ctx.Set("role", payload.Role)
 		ctx.Next()
 	}
 }
 
-func CheckAuthIsAdmin() gin.HandlerFunc {
+// RequireRole authenticates the request and rejects it unless the account
+// currently has one of the given roles.
+func (auth *Auth) RequireRole(roles ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		header := ctx.GetHeader("Authorization")
-
-		bearerToken := strings.Split(header, "Bearer ")
-
-		if len(bearerToken) != 2 {
-			resp := response.UnauthorizedErrorWithAdditionalInfo("len token must be 2")
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+		payload, ok := auth.authenticate(ctx)
+		if !ok {
 			return
 		}
 
-		payload, err := token.ValidateToken(bearerToken[1])
-		if err != nil {
-			resp := response.UnauthorizedErrorWithAdditionalInfo(err.Error())
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+		for _, role := range roles {
+			if payload.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		resp := response.ForbiddenErrorWithAdditionalInfo(roles, "user doesn't have permission to access")
+		ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+	}
+}
+
+// RequirePermission authenticates the request and rejects it unless the
+// role of the account is currently granted every given permission.
+func (auth *Auth) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := auth.authenticate(ctx)
+		if !ok {
 			return
 		}
 
-		if payload.Role != "admin" {
-			resp := response.UnauthorizedErrorWithAdditionalInfo("user doesn't have permission to access")
+		if !rbac.HasPermission(payload.Permissions, permissions...) {
+			resp := response.ForbiddenErrorWithAdditionalInfo(permissions, "user doesn't have permission to access")
 			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
 			return
 		}
-		ctx.Set("authId", payload.AuthId)
-		ctx.Set("role", payload.Role)
 		ctx.Next()
 	}
 }
 
-func CheckAuthIsAdminOrAuthor() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		header := ctx.GetHeader("Authorization")
+// authenticate validates the bearer token and the account behind it and
+// stores the claims on the context. The role and permissions of the token
+// are those at login; they are replaced by the current ones of the account.
+// It aborts the request and returns false when the token is missing or
+// invalid or the account is not active.
+func (auth *Auth) authenticate(ctx *gin.Context) (*token.Token, bool) {
+	header := ctx.GetHeader("Authorization")
 
-		bearerToken := strings.Split(header, "Bearer ")
+	bearerToken := strings.Split(header, "Bearer ")
 
-		if len(bearerToken) != 2 {
-			resp := response.UnauthorizedErrorWithAdditionalInfo("len token must be 2")
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
-			return
-		}
+	if len(bearerToken) != 2 {
+		resp := response.UnauthorizedErrorWithAdditionalInfo("len token must be 2")
+		ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+		return nil, false
+	}
 
-		payload, err := token.ValidateToken(bearerToken[1])
-		if err != nil {
-			resp := response.UnauthorizedErrorWithAdditionalInfo(err.Error())
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
-			return
-		}
+	payload, err := token.ValidateToken(bearerToken[1])
+	if err != nil {
+		resp := response.UnauthorizedErrorWithAdditionalInfo(err.Error())
+		ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+		return nil, false
+	}
 
-		if payload.Role == "user" {
-			resp := response.UnauthorizedErrorWithAdditionalInfo("user doesn't have permission to access")
-			ctx.AbortWithStatusJSON(resp.StatusCode, resp)
-			return
-		}
-		ctx.Set("authId", payload.AuthId)
-		ctx.Set("role", payload.Role)
-		ctx.Next()
+	access, err := auth.Accounts.Access(ctx, uint64(payload.AuthId))
+	if err != nil {
+		resp := response.GeneralError("Failed to check account status: " + err.Error())
+		ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+		return nil, false
+	}
+	if access == nil {
+		resp := response.UnauthorizedErrorWithAdditionalInfo("account is suspended or deleted")
+		ctx.AbortWithStatusJSON(resp.StatusCode, resp)
+		return nil, false
 	}
+	payload.Role = access.Role
+	payload.Permissions = access.Permissions
+
+	ctx.Set("authId", payload.AuthId)
+	ctx.Set("role", payload.Role)
+	ctx.Set("permissions", payload.Permissions)
+	ctx.Request = ctx.Request.WithContext(audit.WithActor(ctx.Request.Context(), audit.Actor{
+		ID:   uint64(payload.AuthId),
+		Role: payload.Role,
+	}))
+	return payload, true
 }
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"library-api-user/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInfo stores the client IP and user agent on the request context,
+// where the audit recorder picks them up.
+func ClientInfo() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(audit.WithClient(ctx.Request.Context(), audit.Client{
+			IP:        ctx.ClientIP(),
+			UserAgent: ctx.Request.UserAgent(),
+		}))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"library-api-user/internal/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the count and latency of every request under its route
+// pattern, so /users/1 and /users/2 share a series. Requests matching no
+// route are recorded as "unmatched". Business operations are counted by
+// the gRPC interceptor, which every API request reaches through the
+// gateway.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := ctx.Request.Method
+		status := ctx.Writer.Status()
+
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
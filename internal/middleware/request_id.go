@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"library-api-user/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs accepted from callers, which end up in
+// every log line of the request.
+const maxRequestIDLength = 128
+
+// RequestID keeps the X-Request-ID of the caller, or assigns a new one, and
+// stores it on the request context for the logger. It is echoed in the
+// response and left on the request headers, so the gateway passes it on
+// to the gRPC server.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logger.NewRequestID()
+			ctx.Request.Header.Set(RequestIDHeader, id)
+		}
+
+		ctx.Writer.Header().Set(RequestIDHeader, id)
+		ctx.Request = ctx.Request.WithContext(logger.WithRequestID(ctx.Request.Context(), id))
+		ctx.Next()
+	}
+}
+
+// validRequestID accepts printable ASCII without spaces, up to
+// maxRequestIDLength characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
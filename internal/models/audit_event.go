@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditRegister       = "auth.register"
+	AuditUserRead       = "user.read"
+	AuditUserList       = "user.list"
+	AuditUserExport     = "user.export"
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserRoleChange = "user.role_change"
+	AuditUserSuspend    = "user.suspend"
+	AuditUserReactivate = "user.reactivate"
+	AuditUserDelete     = "user.delete"
+	AuditProfileRead    = "user.profile_read"
+	AuditProfileUpdate  = "user.profile_update"
+	AuditAvatarUpdate   = "user.avatar_update"
+	AuditRoleCreate     = "role.create"
+	AuditRoleGrant      = "role.grant"
+	AuditRoleRevoke     = "role.revoke"
+	AuditWebhookCreate  = "webhook.create"
+	AuditWebhookUpdate  = "webhook.update"
+	AuditWebhookDelete  = "webhook.delete"
+	AuditTargetUser     = "user"
+	AuditTargetRole     = "role"
+	AuditTargetWebhook  = "webhook"
+	AuditGenesisHash    = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// AuditEvent is one entry of the append-only audit log. Hash covers every
+// other field and PrevHash, which is the hash of the entry before it, so
+// changing or removing an entry breaks the chain from there on.
+type AuditEvent struct {
+	ID         uint64
+	OccurredAt time.Time
+	ActorID    *uint64
+	ActorRole  string
+	Action     string
+	TargetType string
+	TargetID   *uint64
+	Before     []byte
+	After      []byte
+	IP         string
+	UserAgent  string
+	PrevHash   string
+	Hash       string
+}
+
+// AuditFilter narrows the audit log query. Zero values do not filter.
+type AuditFilter struct {
+	ActorID    *uint64
+	TargetType string
+	TargetID   *uint64
+	Action     string
+	From       *time.Time
+	To         *time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type BookProjection struct {
+	BookID      uint64
+	Title       string
+	Author      string
+	Stock       int32
+	Deleted     bool
+	LastEventAt time.Time
+	UpdatedAt   time.Time
+}
@@ -7,5 +7,5 @@ type BorrowRecord struct {
 	UserID     uint64
 	BookID     uint64
 	BorrowedAt time.Time
-	ReturnedAt time.Time
+	ReturnedAt *time.Time
 }
@@ -0,0 +1,11 @@
+package models
+
+// Loan is a borrow record enriched with the locally projected book data.
+type Loan struct {
+	BorrowRecord
+	BookTitle   string
+	BookAuthor  string
+	BookStock   int32
+	BookDeleted bool
+	BookKnown   bool
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type OutboxEvent struct {
+	ID            uint64
+	EventID       string
+	EventType     string
+	SchemaVersion int
+	AggregateID   uint64
+	Payload       []byte
+	Attempts      int
+	LastError     string
+	CreatedAt     time.Time
+	PublishedAt   *time.Time
+}
@@ -1,9 +1,31 @@
 package models
 
+import "library-api-user/internal/commons/cursor"
+
+const (
+	DefaultPageSize = 5
+	MaxPageSize     = 100
+)
+
+// Pagination selects a page either by number (Page and Offset) or, when
+// Cursor is set, by keyset position. TotalCount is counted for numbered
+// pages and for cursor pages with IncludeTotal. NextCursor and PrevCursor
+// are filled in by listings that support cursors, whichever way the page
+// was selected.
 type Pagination struct {
-	Page       int `json:"page"`
-	PageSize   int `json:"per_page"`
-	Offset     int `json:"offset"`
-	PageCount  int `json:"page_count"`
-	TotalCount int `json:"total_count"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"per_page"`
+	Offset     int    `json:"offset"`
+	PageCount  int    `json:"page_count"`
+	TotalCount int    `json:"total_count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+
+	Cursor       *cursor.Cursor `json:"-"`
+	IncludeTotal bool           `json:"-"`
+}
+
+// CountTotal reports whether the listing should count every matching row.
+func (pagination *Pagination) CountTotal() bool {
+	return pagination.Cursor == nil || pagination.IncludeTotal
 }
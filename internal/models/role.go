@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// DefaultRole is given to every account that registers itself. Other roles
+// are only assigned by an administrator.
+const DefaultRole = "user"
+
+type Role struct {
+	ID          uint64
+	Name        string
+	Description string
+	Permissions []string
+	CreatedAt   time.Time
+}
+
+type Permission struct {
+	ID          uint64
+	Name        string
+	Description string
+}
@@ -2,12 +2,26 @@ package models
 
 import "time"
 
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
+
+// User is an account. The password hash must never be part of a response.
 type User struct {
 	ID        uint64
 	Email     string
 	Password  string
 	Name      string
 	Role      string
+	Status    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// Access is what an active account may do right now: its role and the
+// permissions currently granted to that role.
+type Access struct {
+	Role        string
+	Permissions []string
+}
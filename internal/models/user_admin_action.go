@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	UserActionCreated     = "created"
+	UserActionSuspended   = "suspended"
+	UserActionReactivated = "reactivated"
+	UserActionDeleted     = "deleted"
+	UserActionErased      = "erased"
+)
+
+// UserAdminAction records an account change made by an administrator. It
+// has no foreign key to users so that deletions stay on record.
+type UserAdminAction struct {
+	ID          uint64
+	UserID      uint64
+	Action      string
+	PerformedBy uint64
+	Reason      string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// UserSortColumns whitelists the fields the user listing can be sorted by,
+// mapped to their column.
+var UserSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"email":      "email",
+	"role":       "role",
+	"status":     "status",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// UserFilter narrows the user listing. Zero values do not filter; an empty
+// Sort keeps the newest users first.
+type UserFilter struct {
+	Search      string
+	Role        string
+	Status      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        []SortField
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserProfile holds the contact details and preferences of a user. A user
+// without a row has an empty profile.
+type UserProfile struct {
+	UserID       uint64
+	Phone        string
+	AddressLine1 string
+	AddressLine2 string
+	City         string
+	Region       string
+	PostalCode   string
+	Country      string
+	// Preferences is a JSON object; see params.Preferences for the keys.
+	Preferences []byte
+	// AvatarKey locates the avatar in the blob store; empty when there is
+	// none. Every upload gets a new key, so a failed upload never
+	// overwrites the avatar in use.
+	AvatarKey         string
+	AvatarContentType string
+	AvatarSize        int64
+	UpdatedAt         time.Time
+}
+
+// HasAvatar reports whether an avatar was uploaded for the user.
+func (profile *UserProfile) HasAvatar() bool {
+	return profile.AvatarKey != ""
+}
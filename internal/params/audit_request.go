@@ -0,0 +1,14 @@
+package params
+
+import "time"
+
+// AuditEventQuery filters the audit log. Times are RFC 3339; from is
+// inclusive and to is exclusive.
+type AuditEventQuery struct {
+	ActorID    *uint64    `form:"actor_id"`
+	TargetType string     `form:"target_type"`
+	TargetID   *uint64    `form:"target_id"`
+	Action     string     `form:"action"`
+	From       *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
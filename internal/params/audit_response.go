@@ -0,0 +1,22 @@
+package params
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEventResponse struct {
+	ID         uint64          `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    *uint64         `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   *uint64         `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
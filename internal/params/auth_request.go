@@ -4,7 +4,6 @@ type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 	Name     string `json:"name" validate:"required"`
-	Role     string `json:"role" validate:"required"`
 }
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
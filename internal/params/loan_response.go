@@ -0,0 +1,20 @@
+package params
+
+import "time"
+
+type LoanResponse struct {
+	ID         uint64        `json:"id"`
+	BookID     uint64        `json:"book_id"`
+	BorrowedAt time.Time     `json:"borrowed_at"`
+	ReturnedAt *time.Time    `json:"returned_at"`
+	Book       *BookSnapshot `json:"book"`
+}
+
+// BookSnapshot is the book as last seen in the local projection; it is nil
+// when the book service has not published the book yet.
+type BookSnapshot struct {
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Available bool   `json:"available"`
+	Deleted   bool   `json:"deleted"`
+}
@@ -0,0 +1,28 @@
+package params
+
+// ProfilePatchRequest holds a partial update of a profile; nil fields are
+// left unchanged. An address replaces the stored one as a whole, while
+// preferences are merged key by key. An empty phone removes it.
+type ProfilePatchRequest struct {
+	Phone       *string             `json:"phone" validate:"omitnil,e164|len=0"`
+	Address     *Address            `json:"address"`
+	Preferences *PreferencesRequest `json:"preferences"`
+}
+
+// Address is a postal address. An empty address is allowed and removes
+// the stored one; otherwise line1, city and country are required.
+type Address struct {
+	Line1      string `json:"line1" validate:"required_with=Line2 City Region PostalCode Country,max=200"`
+	Line2      string `json:"line2" validate:"max=200"`
+	City       string `json:"city" validate:"required_with=Line1 Line2 Region PostalCode Country,max=100"`
+	Region     string `json:"region" validate:"max=100"`
+	PostalCode string `json:"postal_code" validate:"max=20"`
+	Country    string `json:"country" validate:"required_with=Line1 Line2 City Region PostalCode,omitempty,iso3166_1_alpha2"`
+}
+
+// PreferencesRequest changes single preferences. An empty string resets a
+// preference to the default.
+type PreferencesRequest struct {
+	NotificationChannel *string `json:"notification_channel" validate:"omitnil,oneof=email sms none|len=0"`
+	Language            *string `json:"language" validate:"omitnil,bcp47_language_tag|len=0"`
+}
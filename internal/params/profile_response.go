@@ -0,0 +1,24 @@
+package params
+
+import "time"
+
+type ProfileResponse struct {
+	UserID      uint64          `json:"user_id"`
+	Phone       string          `json:"phone"`
+	Address     Address         `json:"address"`
+	Preferences Preferences     `json:"preferences"`
+	Avatar      *AvatarResponse `json:"avatar"`
+	UpdatedAt   *time.Time      `json:"updated_at"`
+}
+
+// Preferences is stored as a JSON object on the profile. Unset keys fall
+// back to the defaults of the service.
+type Preferences struct {
+	NotificationChannel string `json:"notification_channel,omitempty"`
+	Language            string `json:"language,omitempty"`
+}
+
+type AvatarResponse struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
@@ -0,0 +1,10 @@
+package params
+
+type RoleRequest struct {
+	Name        string `json:"name" validate:"required,max=20,lowercase,excludesall= "`
+	Description string `json:"description"`
+}
+
+type RolePermissionRequest struct {
+	Permission string `json:"permission" validate:"required"`
+}
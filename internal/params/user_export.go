@@ -0,0 +1,29 @@
+package params
+
+import "time"
+
+// UserExport is the personal data archive of a user.
+type UserExport struct {
+	ExportedAt time.Time           `json:"exported_at"`
+	Profile    ExportProfile       `json:"profile"`
+	Contact    *ProfileResponse    `json:"contact"`
+	Loans      []*LoanResponse     `json:"loans"`
+	Activities []*ActivityResponse `json:"activities"`
+}
+
+type ExportProfile struct {
+	ID        uint64    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ActivityResponse struct {
+	ID           uint64    `json:"id"`
+	BookID       uint64    `json:"book_id"`
+	ActivityType string    `json:"activity_type"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
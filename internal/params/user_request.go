@@ -1,9 +1,30 @@
 package params
 
-type UserRequest struct {
-	ID       uint64
+import "time"
+
+// UserPatchRequest holds a partial update; nil fields are left unchanged.
+type UserPatchRequest struct {
+	Email    *string `json:"email" validate:"omitempty,email"`
+	Password *string `json:"password" validate:"omitempty,min=1"`
+	Name     *string `json:"name" validate:"omitempty,min=1"`
+	Role     *string `json:"role" validate:"omitempty,min=1"`
+}
+
+type CreateUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 	Name     string `json:"name" validate:"required"`
 	Role     string `json:"role" validate:"required"`
 }
+
+// UserListQuery narrows and orders the user listing. Q matches a part of
+// the name or email, case-insensitively. Sort is a comma separated list of
+// fields, each optionally prefixed with - for descending order.
+type UserListQuery struct {
+	Q           string     `form:"q" json:"q,omitempty" validate:"max=100"`
+	Role        string     `form:"role" json:"role,omitempty"`
+	Status      string     `form:"status" json:"status,omitempty" validate:"omitempty,oneof=active suspended"`
+	CreatedFrom *time.Time `form:"created_from" json:"created_from,omitempty" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time `form:"created_to" json:"created_to,omitempty" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort        string     `form:"sort" json:"sort,omitempty"`
+}
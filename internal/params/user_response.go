@@ -2,12 +2,23 @@ package params
 
 import "time"
 
+// UserResponse is the administrator view of a user. Credentials are never
+// part of a response.
 type UserResponse struct {
 	ID        uint64    `json:"id"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
 	Name      string    `json:"name"`
 	Role      string    `json:"role"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// UserSelfResponse is what users see of their own account.
+type UserSelfResponse struct {
+	ID        uint64    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
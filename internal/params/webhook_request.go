@@ -0,0 +1,7 @@
+package params
+
+type WebhookRequest struct {
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=loan.created loan.returned loan.overdue account.created account.updated"`
+	Active     *bool    `json:"active"`
+}
@@ -0,0 +1,124 @@
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/repositories"
+	"library-api-user/internal/storage"
+	"time"
+)
+
+const (
+	eraseInterval  = time.Hour
+	eraseBatchSize = 100
+	// DefaultGracePeriod is used when ERASURE_GRACE_DAYS is not configured.
+	DefaultGracePeriod = 30 * 24 * time.Hour
+)
+
+// Eraser anonymizes soft-deleted users once their grace period has passed.
+// Loans and activities keep pointing at the anonymized row, so aggregate
+// history survives the erasure.
+type Eraser struct {
+	DB                    *sql.DB
+	UserRepository        repositories.UserRepository
+	AdminActionRepository repositories.UserAdminActionRepository
+	ProfileRepository     repositories.ProfileRepository
+	Blobs                 storage.BlobStore
+	GracePeriod           time.Duration
+	Logger                logger.Logger
+}
+
+func NewEraser(db *sql.DB, userRepository repositories.UserRepository, adminActionRepository repositories.UserAdminActionRepository, profileRepository repositories.ProfileRepository, blobs storage.BlobStore, gracePeriod time.Duration, log logger.Logger) *Eraser {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+	return &Eraser{
+		DB:                    db,
+		UserRepository:        userRepository,
+		AdminActionRepository: adminActionRepository,
+		ProfileRepository:     profileRepository,
+		Blobs:                 blobs,
+		GracePeriod:           gracePeriod,
+		Logger:                log,
+	}
+}
+
+func (eraser *Eraser) Run(ctx context.Context) {
+	ticker := time.NewTicker(eraseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := eraser.EraseDue(ctx); err != nil {
+				eraser.Logger.Error("[Eraser] Failed to erase deleted users", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+func (eraser *Eraser) EraseDue(ctx context.Context) error {
+	tx, err := eraser.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	ids, err := eraser.UserRepository.FindErasableUsers(ctx, tx, now.Add(-eraser.GracePeriod), eraseBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var avatarKeys []string
+	for _, id := range ids {
+		if err := eraser.UserRepository.AnonymizeUser(ctx, tx, id, now); err != nil {
+			return err
+		}
+		avatarKey, err := eraser.ProfileRepository.DeleteProfile(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if avatarKey != "" {
+			avatarKeys = append(avatarKeys, avatarKey)
+		}
+		// PerformedBy 0 marks an action taken by the service itself.
+		err = eraser.AdminActionRepository.CreateAction(ctx, tx, &models.UserAdminAction{
+			UserID:    id,
+			Action:    models.UserActionErased,
+			Reason:    "grace period after deletion expired",
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Avatars are only removed once nothing refers to them any more. A
+	// failure leaves an orphaned file that no profile points at.
+	for _, key := range avatarKeys {
+		if err := eraser.Blobs.Delete(ctx, key); err != nil {
+			eraser.Logger.Error("[Eraser] Failed to delete avatar", map[string]interface{}{
+				"key":   key,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	if len(ids) > 0 {
+		eraser.Logger.Info("[Eraser] Erased deleted users", map[string]interface{}{
+			"count": len(ids),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package rbac
+
+// Permissions checked by the HTTP and gRPC layers. They are seeded by the
+// RBAC migration and granted to roles through role_permissions.
+const (
+	UsersRead      = "users:read"
+	UsersWrite     = "users:write"
+	LoansRead      = "loans:read"
+	LoansWrite     = "loans:write"
+	WebhooksManage = "webhooks:manage"
+	RolesManage    = "roles:manage"
+	AuditRead      = "audit:read"
+)
+
+// All lists every permission known to the service.
+var All = []string{
+	UsersRead,
+	UsersWrite,
+	LoansRead,
+	LoansWrite,
+	WebhooksManage,
+	RolesManage,
+	AuditRead,
+}
+
+// HasPermission reports whether granted contains every required permission.
+func HasPermission(granted []string, required ...string) bool {
+	for _, permission := range required {
+		found := false
+		for _, g := range granted {
+			if g == permission {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
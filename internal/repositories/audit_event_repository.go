@@ -0,0 +1,228 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library-api-user/internal/models"
+	"strings"
+)
+
+// auditChainLock is the advisory lock key that serializes appends to the
+// audit hash chain.
+const auditChainLock = 0x61756469
+
+type AuditEventRepository interface {
+	CreatePending(ctx context.Context, tx *sql.Tx, event *models.AuditEvent) error
+	FindPending(ctx context.Context, tx *sql.Tx, limit int) ([]*models.AuditEvent, error)
+	DeletePending(ctx context.Context, tx *sql.Tx, ids []uint64) error
+	LockChain(ctx context.Context, tx *sql.Tx) error
+	FindLastHash(ctx context.Context, tx *sql.Tx) (string, error)
+	CreateEvent(ctx context.Context, tx *sql.Tx, event *models.AuditEvent) error
+	FindEvents(ctx context.Context, tx *sql.Tx, filter *models.AuditFilter, pagination *models.Pagination) ([]*models.AuditEvent, error)
+	FindEventsAfter(ctx context.Context, tx *sql.Tx, afterID uint64, limit int) ([]*models.AuditEvent, error)
+}
+
+type AuditEventRepositoryImpl struct {
+}
+
+func NewAuditEventRepository() AuditEventRepository {
+	return &AuditEventRepositoryImpl{}
+}
+
+const auditEventColumns = `id, occurred_at, actor_id, actor_role, action, target_type, target_id, before, after, ip, user_agent, prev_hash, hash`
+
+const auditPendingColumns = `id, occurred_at, actor_id, actor_role, action, target_type, target_id, before, after, ip, user_agent`
+
+// CreatePending queues an event to be appended to the chain. It takes no
+// lock, so it can run in the transaction of the audited action.
+func (repository *AuditEventRepositoryImpl) CreatePending(ctx context.Context, tx *sql.Tx, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_pending (occurred_at, actor_id, actor_role, action, target_type, target_id, before, after, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	return tx.QueryRowContext(ctx, query,
+		event.OccurredAt,
+		event.ActorID,
+		event.ActorRole,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		nullJSON(event.Before),
+		nullJSON(event.After),
+		event.IP,
+		event.UserAgent,
+	).Scan(&event.ID)
+}
+
+// FindPending returns up to limit queued events, oldest first. Their ID is
+// the ID in the queue; PrevHash and Hash are empty.
+func (repository *AuditEventRepositoryImpl) FindPending(ctx context.Context, tx *sql.Tx, limit int) ([]*models.AuditEvent, error) {
+	query := `SELECT ` + auditPendingColumns + `, '', '' FROM audit_pending ORDER BY id LIMIT $1`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// DeletePending removes the queued events that were appended, by ID: rows
+// committed since they were read stay queued.
+func (repository *AuditEventRepositoryImpl) DeletePending(ctx context.Context, tx *sql.Tx, ids []uint64) error {
+	keys := make([]int64, len(ids))
+	for i, id := range ids {
+		keys[i] = int64(id)
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM audit_pending WHERE id = ANY($1)`, keys)
+	if err != nil {
+		return fmt.Errorf("Failed to delete pending audit events. Reason: %w", err)
+	}
+	return nil
+}
+
+// LockChain holds a transaction scoped lock so that only one transaction at
+// a time reads the last hash and appends after it.
+func (repository *AuditEventRepositoryImpl) LockChain(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock)
+	return err
+}
+
+// FindLastHash returns the hash of the newest event, or the genesis hash
+// when the log is empty.
+func (repository *AuditEventRepositoryImpl) FindLastHash(ctx context.Context, tx *sql.Tx) (string, error) {
+	var hash string
+	err := tx.QueryRowContext(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return models.AuditGenesisHash, nil
+	}
+	return hash, err
+}
+
+func (repository *AuditEventRepositoryImpl) CreateEvent(ctx context.Context, tx *sql.Tx, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (occurred_at, actor_id, actor_role, action, target_type, target_id, before, after, ip, user_agent, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+	return tx.QueryRowContext(ctx, query,
+		event.OccurredAt,
+		event.ActorID,
+		event.ActorRole,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		nullJSON(event.Before),
+		nullJSON(event.After),
+		event.IP,
+		event.UserAgent,
+		event.PrevHash,
+		event.Hash,
+	).Scan(&event.ID)
+}
+
+func (repository *AuditEventRepositoryImpl) FindEvents(ctx context.Context, tx *sql.Tx, filter *models.AuditFilter, pagination *models.Pagination) ([]*models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		add("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != nil {
+		add("target_id = $%d", *filter.TargetID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.From != nil {
+		add("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("occurred_at < $%d", *filter.To)
+	}
+
+	query := `SELECT ` + auditEventColumns + `, count(*) over() FROM audit_events`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, pagination.PageSize, pagination.Offset)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows, &pagination.TotalCount)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// FindEventsAfter returns up to limit events with an id above afterID, in
+// chain order.
+func (repository *AuditEventRepositoryImpl) FindEventsAfter(ctx context.Context, tx *sql.Tx, afterID uint64, limit int) ([]*models.AuditEvent, error) {
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events WHERE id > $1 ORDER BY id LIMIT $2`
+	rows, err := tx.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func scanAuditEvent(rows *sql.Rows, extra ...interface{}) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	var actorID, targetID sql.NullInt64
+	dest := []interface{}{
+		&event.ID, &event.OccurredAt, &actorID, &event.ActorRole, &event.Action, &event.TargetType, &targetID,
+		&event.Before, &event.After, &event.IP, &event.UserAgent, &event.PrevHash, &event.Hash,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := uint64(actorID.Int64)
+		event.ActorID = &id
+	}
+	if targetID.Valid {
+		id := uint64(targetID.Int64)
+		event.TargetID = &id
+	}
+	return &event, nil
+}
+
+func nullJSON(value []byte) interface{} {
+	if value == nil {
+		return nil
+	}
+	return string(value)
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/models"
+	"time"
+)
+
+type BookProjectionRepository interface {
+	MarkEventProcessed(ctx context.Context, tx *sql.Tx, eventID string, eventType string) (bool, error)
+	UpsertBook(ctx context.Context, tx *sql.Tx, book *models.BookProjection) error
+	UpdateStock(ctx context.Context, tx *sql.Tx, bookID uint64, stock int32, eventAt time.Time) error
+	MarkDeleted(ctx context.Context, tx *sql.Tx, bookID uint64, eventAt time.Time) error
+	FindBookByID(ctx context.Context, tx *sql.Tx, bookID uint64) (*models.BookProjection, error)
+}
+
+type BookProjectionRepositoryImpl struct {
+}
+
+func NewBookProjectionRepository() BookProjectionRepository {
+	return &BookProjectionRepositoryImpl{}
+}
+
+// MarkEventProcessed records the event id and reports false when the event
+// was already applied, which makes replays a no-op.
+func (repository *BookProjectionRepositoryImpl) MarkEventProcessed(ctx context.Context, tx *sql.Tx, eventID string, eventType string) (bool, error) {
+	query := `INSERT INTO processed_events (event_id, event_type) VALUES ($1, $2) ON CONFLICT (event_id) DO NOTHING`
+	result, err := tx.ExecContext(ctx, query, eventID, eventType)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// The writes below ignore events older than the last one applied to the
+// row, so out-of-order delivery cannot roll a book back to a stale state.
+func (repository *BookProjectionRepositoryImpl) UpsertBook(ctx context.Context, tx *sql.Tx, book *models.BookProjection) error {
+	query := `
+		INSERT INTO book_projections (book_id, title, author, stock, deleted, last_event_at, updated_at)
+		VALUES ($1, $2, $3, $4, FALSE, $5, $6)
+		ON CONFLICT (book_id) DO UPDATE SET
+			title = EXCLUDED.title,
+			author = EXCLUDED.author,
+			stock = EXCLUDED.stock,
+			deleted = FALSE,
+			last_event_at = EXCLUDED.last_event_at,
+			updated_at = EXCLUDED.updated_at
+		WHERE book_projections.last_event_at <= EXCLUDED.last_event_at`
+	_, err := tx.ExecContext(ctx, query,
+		book.BookID,
+		book.Title,
+		book.Author,
+		book.Stock,
+		book.LastEventAt,
+		book.UpdatedAt,
+	)
+	return err
+}
+
+func (repository *BookProjectionRepositoryImpl) UpdateStock(ctx context.Context, tx *sql.Tx, bookID uint64, stock int32, eventAt time.Time) error {
+	query := `
+		INSERT INTO book_projections (book_id, stock, last_event_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (book_id) DO UPDATE SET
+			stock = EXCLUDED.stock,
+			last_event_at = EXCLUDED.last_event_at,
+			updated_at = EXCLUDED.updated_at
+		WHERE book_projections.last_event_at <= EXCLUDED.last_event_at`
+	_, err := tx.ExecContext(ctx, query, bookID, stock, eventAt, time.Now())
+	return err
+}
+
+func (repository *BookProjectionRepositoryImpl) MarkDeleted(ctx context.Context, tx *sql.Tx, bookID uint64, eventAt time.Time) error {
+	query := `
+		INSERT INTO book_projections (book_id, deleted, last_event_at, updated_at)
+		VALUES ($1, TRUE, $2, $3)
+		ON CONFLICT (book_id) DO UPDATE SET
+			deleted = TRUE,
+			last_event_at = EXCLUDED.last_event_at,
+			updated_at = EXCLUDED.updated_at
+		WHERE book_projections.last_event_at <= EXCLUDED.last_event_at`
+	_, err := tx.ExecContext(ctx, query, bookID, eventAt, time.Now())
+	return err
+}
+
+func (repository *BookProjectionRepositoryImpl) FindBookByID(ctx context.Context, tx *sql.Tx, bookID uint64) (*models.BookProjection, error) {
+	query := `SELECT book_id, title, author, stock, deleted, last_event_at, updated_at FROM book_projections WHERE book_id = $1`
+	var book models.BookProjection
+	err := tx.QueryRowContext(ctx, query, bookID).Scan(
+		&book.BookID,
+		&book.Title,
+		&book.Author,
+		&book.Stock,
+		&book.Deleted,
+		&book.LastEventAt,
+		&book.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
@@ -3,14 +3,24 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"library-api-user/internal/models"
+	"library-api-user/pkg/database"
+	"strconv"
+	"time"
 )
 
+var ErrLoanOpen = errors.New("user already holds an open loan of the book")
+
 type BorrowRepository interface {
 	CreateBorrow(ctx context.Context, tx *sql.Tx, borrow *models.BorrowRecord) error
 	FindBorrow(ctx context.Context, tx *sql.Tx, userID uint64, bookID uint64) (*models.BorrowRecord, error)
 	UpdateBorrow(ctx context.Context, tx *sql.Tx, borrow *models.BorrowRecord) error
+	FindLoansByUser(ctx context.Context, tx *sql.Tx, userID uint64, pagination *models.Pagination) ([]*models.Loan, error)
+	CountOpenBorrows(ctx context.Context, tx *sql.Tx, userID uint64) (int, error)
+	FindOverdueBorrows(ctx context.Context, tx *sql.Tx, borrowedBefore time.Time, limit int) ([]*models.BorrowRecord, error)
+	MarkOverdueNotified(ctx context.Context, tx *sql.Tx, id uint64, notifiedAt time.Time) error
 }
 
 type BorrowRepositoryImpl struct {
@@ -20,9 +30,9 @@ func NewBorrowRepository() BorrowRepository {
 	return &BorrowRepositoryImpl{}
 }
 
+// CreateBorrow opens a loan. ErrLoanOpen is returned when the user already
+// holds an open loan of the book.
 func (repository *BorrowRepositoryImpl) CreateBorrow(ctx context.Context, tx *sql.Tx, borrow *models.BorrowRecord) error {
-
-	fmt.Println(borrow)
 	query := `
 		INSERT INTO borrows (user_id, book_id, borrowed_at) VALUES ($1, $2, $3)`
 	_, err := tx.ExecContext(ctx, query,
@@ -30,14 +40,21 @@ func (repository *BorrowRepositoryImpl) CreateBorrow(ctx context.Context, tx *sq
 		borrow.BookID,
 		borrow.BorrowedAt,
 	)
+	if database.IsUniqueViolation(err, "borrows_open_loan_key") {
+		return ErrLoanOpen
+	}
 	return err
 }
 
+// FindBorrow returns the open loan of a book by a user, or sql.ErrNoRows.
+// The loan is locked until the transaction ends, so concurrent returns of
+// it are handled one after the other and only the first finds it open.
 func (repository *BorrowRepositoryImpl) FindBorrow(ctx context.Context, tx *sql.Tx, userID uint64, bookID uint64) (*models.BorrowRecord, error) {
 	query := `
 		SELECT id, user_id, book_id, borrowed_at, returned_at 
 		FROM borrows 
-		WHERE user_id = $1 AND book_id = $2 AND returned_at IS NULL`
+		WHERE user_id = $1 AND book_id = $2 AND returned_at IS NULL
+		FOR UPDATE`
 	row := tx.QueryRowContext(ctx, query, userID, bookID)
 	var borrow models.BorrowRecord
 	err := row.Scan(
@@ -62,3 +79,120 @@ func (repository *BorrowRepositoryImpl) UpdateBorrow(ctx context.Context, tx *sq
 	)
 	return err
 }
+
+var loanKeyset = keyset{listing: "loans", keys: []sortKey{{"b.borrowed_at", true}, {"b.id", true}}}
+
+// FindLoansByUser returns the loans of a user, newest first. A nil
+// pagination returns all of them.
+func (repository *BorrowRepositoryImpl) FindLoansByUser(ctx context.Context, tx *sql.Tx, userID uint64, pagination *models.Pagination) ([]*models.Loan, error) {
+	conditions := "b.user_id = $1"
+	args := []interface{}{userID}
+	limit := ""
+	if pagination != nil {
+		if pagination.CountTotal() {
+			err := tx.QueryRowContext(ctx, `SELECT count(*) FROM borrows b WHERE `+conditions, args...).Scan(&pagination.TotalCount)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		position, positionArgs, err := loanKeyset.where(pagination.Cursor, len(args))
+		if err != nil {
+			return nil, err
+		}
+		if position != "" {
+			conditions += " AND " + position
+			args = append(args, positionArgs...)
+		}
+		args = append(args, pagination.PageSize+1, pagination.Offset)
+		limit = fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	query := `
+		SELECT b.id, b.user_id, b.book_id, b.borrowed_at, b.returned_at,
+			COALESCE(bp.title, ''), COALESCE(bp.author, ''), COALESCE(bp.stock, 0), COALESCE(bp.deleted, FALSE), bp.book_id IS NOT NULL
+		FROM borrows b
+		LEFT JOIN book_projections bp ON bp.book_id = b.book_id
+		WHERE ` + conditions + `
+		ORDER BY ` + loanKeyset.orderBy(cursorOf(pagination)) + limit
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []*models.Loan
+	for rows.Next() {
+		var loan models.Loan
+		err := rows.Scan(
+			&loan.ID,
+			&loan.UserID,
+			&loan.BookID,
+			&loan.BorrowedAt,
+			&loan.ReturnedAt,
+			&loan.BookTitle,
+			&loan.BookAuthor,
+			&loan.BookStock,
+			&loan.BookDeleted,
+			&loan.BookKnown,
+		)
+		if err != nil {
+			return nil, err
+		}
+		loans = append(loans, &loan)
+	}
+	if err := rows.Err(); err != nil || pagination == nil {
+		return loans, err
+	}
+
+	return keysetPage(loanKeyset, loans, pagination, func(loan *models.Loan) []string {
+		return []string{keyTime(loan.BorrowedAt), strconv.FormatUint(loan.ID, 10)}
+	}), nil
+}
+
+func (repository *BorrowRepositoryImpl) CountOpenBorrows(ctx context.Context, tx *sql.Tx, userID uint64) (int, error) {
+	query := `SELECT count(*) FROM borrows WHERE user_id = $1 AND returned_at IS NULL`
+	var count int
+	err := tx.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// FindOverdueBorrows locks open borrows older than borrowedBefore that have
+// not been reported as overdue yet.
+func (repository *BorrowRepositoryImpl) FindOverdueBorrows(ctx context.Context, tx *sql.Tx, borrowedBefore time.Time, limit int) ([]*models.BorrowRecord, error) {
+	query := `
+		SELECT id, user_id, book_id, borrowed_at, returned_at
+		FROM borrows
+		WHERE returned_at IS NULL AND overdue_notified_at IS NULL AND borrowed_at < $1
+		ORDER BY borrowed_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, borrowedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var borrows []*models.BorrowRecord
+	for rows.Next() {
+		var borrow models.BorrowRecord
+		err := rows.Scan(
+			&borrow.ID,
+			&borrow.UserID,
+			&borrow.BookID,
+			&borrow.BorrowedAt,
+			&borrow.ReturnedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		borrows = append(borrows, &borrow)
+	}
+	return borrows, rows.Err()
+}
+
+func (repository *BorrowRepositoryImpl) MarkOverdueNotified(ctx context.Context, tx *sql.Tx, id uint64, notifiedAt time.Time) error {
+	query := `UPDATE borrows SET overdue_notified_at = $1 WHERE id = $2`
+	_, err := tx.ExecContext(ctx, query, notifiedAt, id)
+	return err
+}
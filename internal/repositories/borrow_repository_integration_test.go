@@ -0,0 +1,108 @@
+//go:build integration
+
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/commons/cursor"
+	"library-api-user/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBorrowRepositoryOneOpenLoanPerBook(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		users := NewUserRepository()
+		repository := NewBorrowRepository()
+
+		user := newTestUser("erin@example.com")
+		if err := users.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bookID := insertBook(t, ctx, tx, "loans")
+
+		borrow := &models.BorrowRecord{UserID: user.ID, BookID: bookID, BorrowedAt: time.Now()}
+		if err := repository.CreateBorrow(ctx, tx, borrow); err != nil {
+			t.Fatalf("CreateBorrow: %v", err)
+		}
+		if err := repository.CreateBorrow(ctx, tx, borrow); err != ErrLoanOpen {
+			t.Fatalf("CreateBorrow of a book on loan = %v, want ErrLoanOpen", err)
+		}
+
+		open, err := repository.FindBorrow(ctx, tx, user.ID, bookID)
+		if err != nil {
+			t.Fatalf("FindBorrow: %v", err)
+		}
+		returnedAt := time.Now()
+		open.ReturnedAt = &returnedAt
+		if err := repository.UpdateBorrow(ctx, tx, open); err != nil {
+			t.Fatalf("UpdateBorrow: %v", err)
+		}
+
+		if _, err := repository.FindBorrow(ctx, tx, user.ID, bookID); err != sql.ErrNoRows {
+			t.Errorf("FindBorrow after the return = %v, want sql.ErrNoRows", err)
+		}
+		if err := repository.CreateBorrow(ctx, tx, borrow); err != nil {
+			t.Errorf("CreateBorrow after the return: %v", err)
+		}
+		count, err := repository.CountOpenBorrows(ctx, tx, user.ID)
+		if err != nil {
+			t.Fatalf("CountOpenBorrows: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("open loans = %d, want 1", count)
+		}
+	})
+}
+
+func TestBorrowRepositoryFindLoansByUserNewestFirst(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		users := NewUserRepository()
+		repository := NewBorrowRepository()
+
+		user := newTestUser("frank@example.com")
+		if err := users.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		start := time.Now().Add(-time.Hour)
+		var bookIDs []uint64
+		for i, title := range []string{"first", "second", "third"} {
+			bookID := insertBook(t, ctx, tx, title)
+			bookIDs = append(bookIDs, bookID)
+			borrow := &models.BorrowRecord{UserID: user.ID, BookID: bookID, BorrowedAt: start.Add(time.Duration(i) * time.Minute)}
+			if err := repository.CreateBorrow(ctx, tx, borrow); err != nil {
+				t.Fatalf("CreateBorrow: %v", err)
+			}
+		}
+
+		pagination := &models.Pagination{Page: 1, PageSize: 2}
+		loans, err := repository.FindLoansByUser(ctx, tx, user.ID, pagination)
+		if err != nil {
+			t.Fatalf("FindLoansByUser: %v", err)
+		}
+		if len(loans) != 2 || loans[0].BookID != bookIDs[2] || loans[1].BookID != bookIDs[1] {
+			t.Fatalf("first page = %+v, want the loans of the third and second book", loans)
+		}
+		if pagination.NextCursor == "" {
+			t.Fatalf("first page has no next cursor")
+		}
+
+		next, err := cursor.Decode(pagination.NextCursor)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		pagination = &models.Pagination{PageSize: 2, Cursor: next}
+		loans, err = repository.FindLoansByUser(ctx, tx, user.ID, pagination)
+		if err != nil {
+			t.Fatalf("FindLoansByUser after the cursor: %v", err)
+		}
+		if len(loans) != 1 || loans[0].BookID != bookIDs[0] {
+			t.Errorf("second page = %+v, want the loan of the first book", loans)
+		}
+		if pagination.NextCursor != "" {
+			t.Errorf("last page has a next cursor")
+		}
+	})
+}
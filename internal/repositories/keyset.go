@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"library-api-user/internal/commons/cursor"
+	"library-api-user/internal/models"
+	"strings"
+	"time"
+)
+
+var ErrCursorMismatch = errors.New("cursor does not belong to this listing or ordering")
+
+// keyTimeFormat keeps the microseconds the database stores, so that a time
+// read from a cursor compares equal to the row it came from.
+const keyTimeFormat = "2006-01-02T15:04:05.999999Z07:00"
+
+// sortKey is one column of a keyset ordering. The last key of an ordering
+// must be unique, so that every row has a distinct position.
+type sortKey struct {
+	Column string
+	Desc   bool
+}
+
+// keyset selects pages of a listing ordered by keys. listing names the
+// listing so that a cursor of one cannot be used for another.
+type keyset struct {
+	listing string
+	keys    []sortKey
+}
+
+func (k keyset) name() string {
+	parts := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		parts[i] = key.Column
+		if key.Desc {
+			parts[i] = "-" + key.Column
+		}
+	}
+	return k.listing + ":" + strings.Join(parts, ",")
+}
+
+// where returns the condition selecting the rows past the cursor, with its
+// placeholders numbered after the first argsBefore arguments. It is empty
+// without a cursor.
+func (k keyset) where(c *cursor.Cursor, argsBefore int) (string, []interface{}, error) {
+	if c == nil {
+		return "", nil, nil
+	}
+	if c.Key != k.name() || len(c.Values) != len(k.keys) {
+		return "", nil, ErrCursorMismatch
+	}
+
+	args := make([]interface{}, len(c.Values))
+	for i, value := range c.Values {
+		args[i] = value
+	}
+
+	// (a, b, c) after (x, y, z) is a > x OR (a = x AND b > y) OR
+	// (a = x AND b = y AND c > z), with > turned into < for descending keys
+	// and both flipped again when reading backwards.
+	alternatives := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = $%d", k.keys[j].Column, argsBefore+j+1))
+		}
+		operator := ">"
+		if key.Desc != c.Backward {
+			operator = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s %s $%d", key.Column, operator, argsBefore+i+1))
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args, nil
+}
+
+// orderBy returns the ORDER BY clause, reversed when reading backwards.
+func (k keyset) orderBy(c *cursor.Cursor) string {
+	backward := c != nil && c.Backward
+	parts := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		direction := "ASC"
+		if key.Desc != backward {
+			direction = "DESC"
+		}
+		parts[i] = key.Column + " " + direction
+	}
+	return strings.Join(parts, ", ")
+}
+
+// keysetPage trims the extra row a listing fetched to learn whether more
+// rows follow, restores the order of a page read backwards, and sets the
+// next and previous cursors of pagination. values returns the sort key
+// values of a row.
+func keysetPage[T any](k keyset, rows []T, pagination *models.Pagination, values func(T) []string) []T {
+	c := pagination.Cursor
+	backward := c != nil && c.Backward
+
+	more := len(rows) > pagination.PageSize
+	if more {
+		rows = rows[:pagination.PageSize]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	hasNext, hasPrev := more, c != nil || pagination.Offset > 0
+	if backward {
+		hasNext, hasPrev = true, more
+	}
+
+	pagination.NextCursor, pagination.PrevCursor = "", ""
+	if len(rows) == 0 {
+		// Past either end: the cursor itself, turned around, leads back.
+		if c != nil {
+			pagination.NextCursor = cursor.Encode(cursor.Cursor{Key: c.Key, Values: c.Values})
+			pagination.PrevCursor = cursor.Encode(cursor.Cursor{Key: c.Key, Values: c.Values, Backward: true})
+			if backward {
+				pagination.PrevCursor = ""
+			} else {
+				pagination.NextCursor = ""
+			}
+		}
+		return rows
+	}
+	if hasNext {
+		pagination.NextCursor = cursor.Encode(cursor.Cursor{Key: k.name(), Values: values(rows[len(rows)-1])})
+	}
+	if hasPrev {
+		pagination.PrevCursor = cursor.Encode(cursor.Cursor{Key: k.name(), Values: values(rows[0]), Backward: true})
+	}
+	return rows
+}
+
+func cursorOf(pagination *models.Pagination) *cursor.Cursor {
+	if pagination == nil {
+		return nil
+	}
+	return pagination.Cursor
+}
+
+func keyTime(t time.Time) string {
+	return t.Format(keyTimeFormat)
+}
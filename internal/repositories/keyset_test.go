@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"library-api-user/internal/commons/cursor"
+	"library-api-user/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testKeyset = keyset{listing: "users", keys: []sortKey{{"created_at", true}, {"id", false}}}
+
+func TestKeysetWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		cursor   *cursor.Cursor
+		want     string
+		wantArgs []interface{}
+		wantErr  error
+	}{
+		{
+			name: "no cursor",
+		},
+		{
+			name:     "forward",
+			cursor:   &cursor.Cursor{Key: "users:-created_at,id", Values: []string{"2024-01-01T00:00:00Z", "7"}},
+			want:     "((created_at < $3) OR (created_at = $3 AND id > $4))",
+			wantArgs: []interface{}{"2024-01-01T00:00:00Z", "7"},
+		},
+		{
+			name:     "backward",
+			cursor:   &cursor.Cursor{Key: "users:-created_at,id", Values: []string{"2024-01-01T00:00:00Z", "7"}, Backward: true},
+			want:     "((created_at > $3) OR (created_at = $3 AND id < $4))",
+			wantArgs: []interface{}{"2024-01-01T00:00:00Z", "7"},
+		},
+		{
+			name:    "other listing",
+			cursor:  &cursor.Cursor{Key: "loans:-created_at,id", Values: []string{"2024-01-01T00:00:00Z", "7"}},
+			wantErr: ErrCursorMismatch,
+		},
+		{
+			name:    "other ordering",
+			cursor:  &cursor.Cursor{Key: "users:created_at,id", Values: []string{"2024-01-01T00:00:00Z", "7"}},
+			wantErr: ErrCursorMismatch,
+		},
+		{
+			name:    "missing value",
+			cursor:  &cursor.Cursor{Key: "users:-created_at,id", Values: []string{"2024-01-01T00:00:00Z"}},
+			wantErr: ErrCursorMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, args, err := testKeyset.where(test.cursor, 2)
+			if err != test.wantErr {
+				t.Fatalf("where error = %v, want %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("where = %q, want %q", got, test.want)
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("args = %v, want %v", args, test.wantArgs)
+			}
+		})
+	}
+}
+
+func TestKeysetOrderBy(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor *cursor.Cursor
+		want   string
+	}{
+		{"no cursor", nil, "created_at DESC, id ASC"},
+		{"forward", &cursor.Cursor{Key: "users:-created_at,id", Values: []string{"", ""}}, "created_at DESC, id ASC"},
+		{"backward", &cursor.Cursor{Key: "users:-created_at,id", Values: []string{"", ""}, Backward: true}, "created_at ASC, id DESC"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := testKeyset.orderBy(test.cursor); got != test.want {
+				t.Errorf("orderBy = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestKeysetPageCursors checks the cursors set on a page, decoded back the
+// way a client sends them.
+func TestKeysetPageCursors(t *testing.T) {
+	values := func(id string) []string { return []string{"2024-01-01T00:00:00Z", id} }
+	at := func(id string, backward bool) *cursor.Cursor {
+		return &cursor.Cursor{Key: testKeyset.name(), Values: values(id), Backward: backward}
+	}
+
+	tests := []struct {
+		name     string
+		cursor   *cursor.Cursor
+		rows     []string
+		pageSize int
+		wantRows []string
+		wantNext *cursor.Cursor
+		wantPrev *cursor.Cursor
+	}{
+		{
+			name:     "first page with more rows",
+			rows:     []string{"1", "2", "3"},
+			pageSize: 2,
+			wantRows: []string{"1", "2"},
+			wantNext: at("2", false),
+		},
+		{
+			name:     "only page",
+			rows:     []string{"1", "2"},
+			pageSize: 2,
+			wantRows: []string{"1", "2"},
+		},
+		{
+			name:     "last page",
+			cursor:   at("2", false),
+			rows:     []string{"3"},
+			pageSize: 2,
+			wantRows: []string{"3"},
+			wantPrev: at("3", true),
+		},
+		{
+			name:     "backward with more rows",
+			cursor:   at("5", true),
+			rows:     []string{"4", "3", "2"},
+			pageSize: 2,
+			wantRows: []string{"3", "4"},
+			wantNext: at("4", false),
+			wantPrev: at("3", true),
+		},
+		{
+			name:     "backward to the first page",
+			cursor:   at("3", true),
+			rows:     []string{"2", "1"},
+			pageSize: 2,
+			wantRows: []string{"1", "2"},
+			wantNext: at("2", false),
+		},
+		{
+			name:     "past the end",
+			cursor:   at("9", false),
+			pageSize: 2,
+			wantRows: []string{},
+			wantPrev: at("9", true),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pagination := &models.Pagination{PageSize: test.pageSize, Cursor: test.cursor}
+			rows := keysetPage(testKeyset, append([]string{}, test.rows...), pagination, values)
+
+			if len(rows) != len(test.wantRows) || (len(rows) > 0 && !reflect.DeepEqual(rows, test.wantRows)) {
+				t.Errorf("rows = %v, want %v", rows, test.wantRows)
+			}
+			checkCursor(t, "next", pagination.NextCursor, test.wantNext)
+			checkCursor(t, "previous", pagination.PrevCursor, test.wantPrev)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name, encoded string, want *cursor.Cursor) {
+	t.Helper()
+	if want == nil {
+		if encoded != "" {
+			t.Errorf("%s cursor = %q, want none", name, encoded)
+		}
+		return
+	}
+	got, err := cursor.Decode(encoded)
+	if err != nil {
+		t.Fatalf("%s cursor %q does not decode: %v", name, encoded, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s cursor = %+v, want %+v", name, got, want)
+	}
+}
+
+// A time must survive the cursor with the precision the database keeps.
+func TestKeyTimeKeepsMicroseconds(t *testing.T) {
+	tests := []time.Time{
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 12, 30, 0, 123456000, time.UTC),
+		time.Date(2024, 1, 1, 12, 30, 0, 100000000, time.FixedZone("WIB", 7*60*60)),
+	}
+
+	for _, want := range tests {
+		got, err := time.Parse(keyTimeFormat, keyTime(want))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", keyTime(want), err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("keyTime round trip of %v = %v", want, got)
+		}
+	}
+}
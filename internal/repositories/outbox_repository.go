@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/models"
+	"time"
+)
+
+type OutboxRepository interface {
+	CreateEvent(ctx context.Context, tx *sql.Tx, event *models.OutboxEvent) error
+	FindUnpublished(ctx context.Context, tx *sql.Tx, limit int) ([]*models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, tx *sql.Tx, id uint64, publishedAt time.Time) error
+	MarkFailed(ctx context.Context, tx *sql.Tx, id uint64, reason string) error
+}
+
+type OutboxRepositoryImpl struct {
+}
+
+func NewOutboxRepository() OutboxRepository {
+	return &OutboxRepositoryImpl{}
+}
+
+func (repository *OutboxRepositoryImpl) CreateEvent(ctx context.Context, tx *sql.Tx, event *models.OutboxEvent) error {
+	query := `INSERT INTO outbox_events (event_id, event_type, schema_version, aggregate_id, payload, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := tx.ExecContext(ctx, query,
+		event.EventID,
+		event.EventType,
+		event.SchemaVersion,
+		event.AggregateID,
+		event.Payload,
+		event.CreatedAt,
+	)
+	return err
+}
+
+// FindUnpublished locks the oldest pending events so that concurrent relays
+// running in other replicas skip them instead of publishing them twice.
+func (repository *OutboxRepositoryImpl) FindUnpublished(ctx context.Context, tx *sql.Tx, limit int) ([]*models.OutboxEvent, error) {
+	query := `
+		SELECT id, event_id, event_type, schema_version, aggregate_id, payload, attempts, created_at
+		FROM outbox_events
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.EventID,
+			&event.EventType,
+			&event.SchemaVersion,
+			&event.AggregateID,
+			&event.Payload,
+			&event.Attempts,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (repository *OutboxRepositoryImpl) MarkPublished(ctx context.Context, tx *sql.Tx, id uint64, publishedAt time.Time) error {
+	query := `UPDATE outbox_events SET published_at = $1, last_error = NULL WHERE id = $2`
+	_, err := tx.ExecContext(ctx, query, publishedAt, id)
+	return err
+}
+
+func (repository *OutboxRepositoryImpl) MarkFailed(ctx context.Context, tx *sql.Tx, id uint64, reason string) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $1 WHERE id = $2`
+	_, err := tx.ExecContext(ctx, query, reason, id)
+	return err
+}
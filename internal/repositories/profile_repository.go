@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library-api-user/internal/models"
+)
+
+type ProfileRepository interface {
+	FindProfile(ctx context.Context, tx *sql.Tx, userID uint64) (*models.UserProfile, error)
+	FindProfileForUpdate(ctx context.Context, tx *sql.Tx, userID uint64) (*models.UserProfile, error)
+	UpdateProfile(ctx context.Context, tx *sql.Tx, profile *models.UserProfile) error
+	DeleteProfile(ctx context.Context, tx *sql.Tx, userID uint64) (avatarKey string, err error)
+}
+
+type ProfileRepositoryImpl struct {
+}
+
+func NewProfileRepository() ProfileRepository {
+	return &ProfileRepositoryImpl{}
+}
+
+const profileColumns = `user_id, phone, address_line1, address_line2, city, region, postal_code, country, preferences, avatar_key, avatar_content_type, avatar_size, updated_at`
+
+// FindProfile returns the profile of a user, or an empty one when the user
+// has never saved a profile.
+func (repository *ProfileRepositoryImpl) FindProfile(ctx context.Context, tx *sql.Tx, userID uint64) (*models.UserProfile, error) {
+	query := `SELECT ` + profileColumns + ` FROM user_profiles WHERE user_id = $1`
+	profile, err := scanProfile(tx.QueryRowContext(ctx, query, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return &models.UserProfile{UserID: userID, Preferences: []byte("{}")}, nil
+	}
+	return profile, err
+}
+
+// FindProfileForUpdate creates the profile row if it is missing and locks
+// it until the transaction ends, so that concurrent updates of different
+// fields do not overwrite each other.
+func (repository *ProfileRepositoryImpl) FindProfileForUpdate(ctx context.Context, tx *sql.Tx, userID uint64) (*models.UserProfile, error) {
+	_, err := tx.ExecContext(ctx, `INSERT INTO user_profiles (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create a user profile, transaction rolled back. Reason: %w", err)
+	}
+
+	query := `SELECT ` + profileColumns + ` FROM user_profiles WHERE user_id = $1 FOR UPDATE`
+	return scanProfile(tx.QueryRowContext(ctx, query, userID))
+}
+
+func (repository *ProfileRepositoryImpl) UpdateProfile(ctx context.Context, tx *sql.Tx, profile *models.UserProfile) error {
+	query := `
+		UPDATE user_profiles
+		SET phone = $1, address_line1 = $2, address_line2 = $3, city = $4, region = $5, postal_code = $6, country = $7,
+			preferences = $8, avatar_key = $9, avatar_content_type = $10, avatar_size = $11, updated_at = $12
+		WHERE user_id = $13`
+
+	_, err := tx.ExecContext(ctx, query,
+		profile.Phone,
+		profile.AddressLine1,
+		profile.AddressLine2,
+		profile.City,
+		profile.Region,
+		profile.PostalCode,
+		profile.Country,
+		string(profile.Preferences),
+		profile.AvatarKey,
+		profile.AvatarContentType,
+		profile.AvatarSize,
+		profile.UpdatedAt,
+		profile.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to update a user profile, transaction rolled back. Reason: %w", err)
+	}
+	return nil
+}
+
+// DeleteProfile removes the profile of a user and returns the key of its
+// avatar, which the caller deletes from the blob store once the
+// transaction has committed.
+func (repository *ProfileRepositoryImpl) DeleteProfile(ctx context.Context, tx *sql.Tx, userID uint64) (string, error) {
+	var avatarKey string
+	err := tx.QueryRowContext(ctx, `DELETE FROM user_profiles WHERE user_id = $1 RETURNING avatar_key`, userID).Scan(&avatarKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to delete a user profile, transaction rolled back. Reason: %w", err)
+	}
+	return avatarKey, nil
+}
+
+func scanProfile(row *sql.Row) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	err := row.Scan(
+		&profile.UserID,
+		&profile.Phone,
+		&profile.AddressLine1,
+		&profile.AddressLine2,
+		&profile.City,
+		&profile.Region,
+		&profile.PostalCode,
+		&profile.Country,
+		&profile.Preferences,
+		&profile.AvatarKey,
+		&profile.AvatarContentType,
+		&profile.AvatarSize,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
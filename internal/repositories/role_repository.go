@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"library-api-user/internal/models"
+	"library-api-user/pkg/database"
+)
+
+// ErrRoleExists is returned when a role with the same name exists.
+var ErrRoleExists = errors.New("role already exists")
+
+type RoleRepository interface {
+	CreateRole(ctx context.Context, tx *sql.Tx, role *models.Role) error
+	FindRoleByName(ctx context.Context, tx *sql.Tx, name string) (*models.Role, error)
+	FindRoles(ctx context.Context, tx *sql.Tx) ([]*models.Role, error)
+	FindPermissions(ctx context.Context, tx *sql.Tx) ([]*models.Permission, error)
+	FindPermissionByName(ctx context.Context, tx *sql.Tx, name string) (*models.Permission, error)
+	FindPermissionsByRole(ctx context.Context, tx *sql.Tx, role string) ([]string, error)
+	GrantPermission(ctx context.Context, tx *sql.Tx, roleID uint64, permissionID uint64) error
+	RevokePermission(ctx context.Context, tx *sql.Tx, roleID uint64, permissionID uint64) error
+}
+
+type RoleRepositoryImpl struct {
+}
+
+func NewRoleRepository() RoleRepository {
+	return &RoleRepositoryImpl{}
+}
+
+const roleQuery = `
+	SELECT r.id, r.name, r.description, r.created_at,
+		COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	LEFT JOIN permissions p ON p.id = rp.permission_id`
+
+func (repository *RoleRepositoryImpl) CreateRole(ctx context.Context, tx *sql.Tx, role *models.Role) error {
+	query := `INSERT INTO roles (name, description, created_at) VALUES ($1, $2, $3) RETURNING id`
+	err := tx.QueryRowContext(ctx, query, role.Name, role.Description, role.CreatedAt).Scan(&role.ID)
+	if database.IsUniqueViolation(err, "roles_name_key") {
+		return ErrRoleExists
+	}
+	return err
+}
+
+func (repository *RoleRepositoryImpl) FindRoleByName(ctx context.Context, tx *sql.Tx, name string) (*models.Role, error) {
+	query := roleQuery + ` WHERE r.name = $1 GROUP BY r.id`
+	var role models.Role
+	err := tx.QueryRowContext(ctx, query, name).Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, database.Array(&role.Permissions))
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (repository *RoleRepositoryImpl) FindRoles(ctx context.Context, tx *sql.Tx) ([]*models.Role, error) {
+	query := roleQuery + ` GROUP BY r.id ORDER BY r.id`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		var role models.Role
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, database.Array(&role.Permissions))
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	return roles, rows.Err()
+}
+
+func (repository *RoleRepositoryImpl) FindPermissions(ctx context.Context, tx *sql.Tx) ([]*models.Permission, error) {
+	query := `SELECT id, name, description FROM permissions ORDER BY name`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []*models.Permission
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.ID, &permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, &permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (repository *RoleRepositoryImpl) FindPermissionByName(ctx context.Context, tx *sql.Tx, name string) (*models.Permission, error) {
+	query := `SELECT id, name, description FROM permissions WHERE name = $1`
+	var permission models.Permission
+	err := tx.QueryRowContext(ctx, query, name).Scan(&permission.ID, &permission.Name, &permission.Description)
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (repository *RoleRepositoryImpl) FindPermissionsByRole(ctx context.Context, tx *sql.Tx, role string) ([]string, error) {
+	query := `
+		SELECT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = $1
+		ORDER BY p.name`
+	rows, err := tx.QueryContext(ctx, query, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (repository *RoleRepositoryImpl) GrantPermission(ctx context.Context, tx *sql.Tx, roleID uint64, permissionID uint64) error {
+	query := `INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := tx.ExecContext(ctx, query, roleID, permissionID)
+	return err
+}
+
+func (repository *RoleRepositoryImpl) RevokePermission(ctx context.Context, tx *sql.Tx, roleID uint64, permissionID uint64) error {
+	query := `DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2`
+	_, err := tx.ExecContext(ctx, query, roleID, permissionID)
+	return err
+}
@@ -3,11 +3,16 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"library-api-user/internal/models"
+	"library-api-user/pkg/database"
+	"strconv"
 )
 
 type UserActivityRepository interface {
 	CreateActivity(ctx context.Context, tx *sql.Tx, activity *models.UserActivity) error
+	CreateActivities(ctx context.Context, tx *sql.Tx, activities []*models.UserActivity) (int64, error)
+	FindActivitiesByUser(ctx context.Context, tx *sql.Tx, userID uint64, pagination *models.Pagination) ([]*models.UserActivity, error)
 }
 
 type UserActivityRepositoryImpl struct {
@@ -27,3 +32,84 @@ func (repository *UserActivityRepositoryImpl) CreateActivity(ctx context.Context
 	)
 	return err
 }
+
+// CreateActivities inserts activities in bulk with COPY and returns how
+// many were inserted. Their IDs are not read back.
+func (repository *UserActivityRepositoryImpl) CreateActivities(ctx context.Context, tx *sql.Tx, activities []*models.UserActivity) (int64, error) {
+	rows := make([][]any, len(activities))
+	for i, activity := range activities {
+		rows[i] = []any{activity.UserID, activity.BookID, activity.ActivityType, activity.ActivityTimestamp}
+	}
+	copied, err := database.CopyFrom(ctx, tx, "user_activities", []string{"user_id", "book_id", "activity_type", "activity_timestamp"}, rows)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to copy user activities. Reason: %w", err)
+	}
+	return copied, nil
+}
+
+// activityKeyset orders by id alone: activity_timestamp is nullable and
+// set on insert, so the id already follows it.
+var activityKeyset = keyset{listing: "activities", keys: []sortKey{{"id", true}}}
+
+// FindActivitiesByUser returns the activities of a user, newest first. A
+// nil pagination returns all of them.
+func (repository *UserActivityRepositoryImpl) FindActivitiesByUser(ctx context.Context, tx *sql.Tx, userID uint64, pagination *models.Pagination) ([]*models.UserActivity, error) {
+	conditions := "user_id = $1"
+	args := []interface{}{userID}
+	limit := ""
+	if pagination != nil {
+		if pagination.CountTotal() {
+			err := tx.QueryRowContext(ctx, `SELECT count(*) FROM user_activities WHERE `+conditions, args...).Scan(&pagination.TotalCount)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		position, positionArgs, err := activityKeyset.where(pagination.Cursor, len(args))
+		if err != nil {
+			return nil, err
+		}
+		if position != "" {
+			conditions += " AND " + position
+			args = append(args, positionArgs...)
+		}
+		args = append(args, pagination.PageSize+1, pagination.Offset)
+		limit = fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	query := `
+		SELECT id, user_id, book_id, activity_type, activity_timestamp
+		FROM user_activities
+		WHERE ` + conditions + `
+		ORDER BY ` + activityKeyset.orderBy(cursorOf(pagination)) + limit
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []*models.UserActivity
+	for rows.Next() {
+		var activity models.UserActivity
+		var timestamp sql.NullTime
+		err := rows.Scan(
+			&activity.ID,
+			&activity.UserID,
+			&activity.BookID,
+			&activity.ActivityType,
+			&timestamp,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activity.ActivityTimestamp = timestamp.Time
+		activities = append(activities, &activity)
+	}
+	if err := rows.Err(); err != nil || pagination == nil {
+		return activities, err
+	}
+
+	return keysetPage(activityKeyset, activities, pagination, func(activity *models.UserActivity) []string {
+		return []string{strconv.FormatUint(activity.ID, 10)}
+	}), nil
+}
@@ -0,0 +1,48 @@
+//go:build integration
+
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/models"
+	"testing"
+	"time"
+)
+
+func TestUserActivityRepositoryCreateActivitiesCopiesEveryRow(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		users := NewUserRepository()
+		repository := NewUserActivityRepository()
+
+		user := newTestUser("grace@example.com")
+		if err := users.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		bookID := insertBook(t, ctx, tx, "history")
+
+		borrowedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		activities := []*models.UserActivity{
+			{UserID: user.ID, BookID: bookID, ActivityType: "borrowed", ActivityTimestamp: borrowedAt},
+			{UserID: user.ID, BookID: bookID, ActivityType: "returned", ActivityTimestamp: borrowedAt.Add(48 * time.Hour)},
+		}
+		copied, err := repository.CreateActivities(ctx, tx, activities)
+		if err != nil {
+			t.Fatalf("CreateActivities: %v", err)
+		}
+		if copied != 2 {
+			t.Errorf("copied = %d, want 2", copied)
+		}
+
+		found, err := repository.FindActivitiesByUser(ctx, tx, user.ID, nil)
+		if err != nil {
+			t.Fatalf("FindActivitiesByUser: %v", err)
+		}
+		if len(found) != 2 || found[0].ActivityType != "returned" || found[1].ActivityType != "borrowed" {
+			t.Fatalf("activities = %+v, want the return then the borrow", found)
+		}
+		if !found[1].ActivityTimestamp.Equal(borrowedAt) {
+			t.Errorf("timestamp = %v, want %v", found[1].ActivityTimestamp, borrowedAt)
+		}
+	})
+}
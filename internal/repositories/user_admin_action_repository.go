@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/models"
+)
+
+type UserAdminActionRepository interface {
+	CreateAction(ctx context.Context, tx *sql.Tx, action *models.UserAdminAction) error
+}
+
+type UserAdminActionRepositoryImpl struct {
+}
+
+func NewUserAdminActionRepository() UserAdminActionRepository {
+	return &UserAdminActionRepositoryImpl{}
+}
+
+func (repository *UserAdminActionRepositoryImpl) CreateAction(ctx context.Context, tx *sql.Tx, action *models.UserAdminAction) error {
+	query := `INSERT INTO user_admin_actions (user_id, action, performed_by, reason, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	return tx.QueryRowContext(ctx, query,
+		action.UserID,
+		action.Action,
+		action.PerformedBy,
+		action.Reason,
+		action.CreatedAt,
+	).Scan(&action.ID)
+}
@@ -4,16 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"library-api-user/internal/models"
+	"library-api-user/pkg/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound = errors.New("user is not found")
+	ErrEmailTaken   = errors.New("email is already used by another user")
+	ErrUserModified = errors.New("user was modified since it was read")
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, tx *sql.Tx, user *models.User) error
 	FindUserByID(ctx context.Context, tx *sql.Tx, id uint64) (*models.User, error)
 	FindUserByEmail(ctx context.Context, tx *sql.Tx, email string) (*models.User, error)
-	UpdateUser(ctx context.Context, tx *sql.Tx, user *models.User) error
-	DeleteUser(ctx context.Context, tx *sql.Tx, id uint64) error
-	GetAllUsers(ctx context.Context, tx *sql.Tx, pagination *models.Pagination) ([]*models.User, error)
+	FindUserStatus(ctx context.Context, tx *sql.Tx, id uint64) (string, error)
+	FindActiveUserAccess(ctx context.Context, tx *sql.Tx, id uint64) (*models.Access, error)
+	UpdateUser(ctx context.Context, tx *sql.Tx, user *models.User, expectedUpdatedAt *time.Time) error
+	UpdateStatus(ctx context.Context, tx *sql.Tx, id uint64, status string, updatedAt time.Time) error
+	DeleteUser(ctx context.Context, tx *sql.Tx, id uint64, deletedAt time.Time) error
+	FindErasableUsers(ctx context.Context, tx *sql.Tx, deletedBefore time.Time, limit int) ([]uint64, error)
+	AnonymizeUser(ctx context.Context, tx *sql.Tx, id uint64, erasedAt time.Time) error
+	GetAllUsers(ctx context.Context, tx *sql.Tx, filter *models.UserFilter, pagination *models.Pagination) ([]*models.User, error)
 }
 
 type UserRepositoryImpl struct {
@@ -24,17 +40,23 @@ func NewUserRepository() UserRepository {
 }
 
 func (repository *UserRepositoryImpl) CreateUser(ctx context.Context, tx *sql.Tx, user *models.User) error {
-	query := `INSERT INTO users (email, password, name, role, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
-	response, err := tx.ExecContext(ctx, query, user.Email, user.Password, user.Name, user.Role, user.CreatedAt, user.UpdatedAt)
-	if err != nil || response == nil {
-		return errors.New("Failed to create a user, transaction rolled back. Reason: " + err.Error())
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
+	query := `INSERT INTO users (email, password, name, role, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := tx.QueryRowContext(ctx, query, user.Email, user.Password, user.Name, user.Role, user.Status, user.CreatedAt, user.UpdatedAt).Scan(&user.ID)
+	if err != nil {
+		if database.IsUniqueViolation(err, "users_email_active_key") {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("Failed to create a user, transaction rolled back. Reason: %w", err)
 	}
 
 	return nil
 }
 
 func (repository *UserRepositoryImpl) FindUserByID(ctx context.Context, tx *sql.Tx, id uint64) (*models.User, error) {
-	query := "SELECT id, email, password, name, role, created_at, updated_at FROM users WHERE id = $1 LIMIT 1"
+	query := "SELECT id, email, password, name, role, status, created_at, updated_at FROM users WHERE id = $1 AND deleted_at IS NULL LIMIT 1"
 	rows, err := tx.QueryContext(ctx, query, id)
 	if err != nil {
 		return nil, err
@@ -43,17 +65,20 @@ func (repository *UserRepositoryImpl) FindUserByID(ctx context.Context, tx *sql.
 
 	var user = models.User{}
 	if rows.Next() {
-		err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		return &user, nil
 	} else {
-		return nil, errors.New("user is not found")
+		return nil, ErrUserNotFound
 	}
 }
+
+// FindUserByEmail matches the email case-insensitively, like the unique
+// index on users.
 func (repository *UserRepositoryImpl) FindUserByEmail(ctx context.Context, tx *sql.Tx, email string) (*models.User, error) {
-	query := "SELECT id, email, password, name, role, created_at, updated_at FROM users WHERE email = $1 LIMIT 1"
+	query := "SELECT id, email, password, name, role, status, created_at, updated_at FROM users WHERE lower(email) = lower($1) AND deleted_at IS NULL LIMIT 1"
 	rows, err := tx.QueryContext(ctx, query, email)
 	if err != nil {
 		return nil, err
@@ -62,46 +87,190 @@ func (repository *UserRepositoryImpl) FindUserByEmail(ctx context.Context, tx *s
 
 	var user = models.User{}
 	if rows.Next() {
-		err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 		return &user, nil
 	} else {
-		return nil, errors.New("user is not found")
+		return nil, ErrUserNotFound
 	}
 }
 
-func (repository *UserRepositoryImpl) UpdateUser(ctx context.Context, tx *sql.Tx, user *models.User) error {
-	query := `UPDATE users SET email = $1, password = $2, name = $3, role = $4, updated_at = $5 WHERE id = $6`
+func (repository *UserRepositoryImpl) FindUserStatus(ctx context.Context, tx *sql.Tx, id uint64) (string, error) {
+	query := "SELECT status FROM users WHERE id = $1 AND deleted_at IS NULL"
+	var status string
+	err := tx.QueryRowContext(ctx, query, id).Scan(&status)
+	return status, err
+}
 
-	_, err := tx.ExecContext(ctx, query,
+// FindActiveUserAccess returns the role of an active user together with the
+// permissions granted to it, in one round trip. sql.ErrNoRows is returned
+// when the user does not exist, is suspended or was deleted.
+func (repository *UserRepositoryImpl) FindActiveUserAccess(ctx context.Context, tx *sql.Tx, id uint64) (*models.Access, error) {
+	query := `
+		SELECT u.role,
+			COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM users u
+		LEFT JOIN roles r ON r.name = u.role
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE u.id = $1 AND u.status = $2 AND u.deleted_at IS NULL
+		GROUP BY u.id`
+	var access models.Access
+	err := tx.QueryRowContext(ctx, query, id, models.UserStatusActive).Scan(&access.Role, database.Array(&access.Permissions))
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// UpdateUser writes every field of user. When expectedUpdatedAt is set the
+// row is only changed if it still carries that updated_at, and
+// ErrUserModified is returned otherwise.
+func (repository *UserRepositoryImpl) UpdateUser(ctx context.Context, tx *sql.Tx, user *models.User, expectedUpdatedAt *time.Time) error {
+	query := `
+		UPDATE users SET email = $1, password = $2, name = $3, role = $4, updated_at = $5
+		WHERE id = $6 AND deleted_at IS NULL AND ($7::timestamptz IS NULL OR updated_at = $7)`
+
+	result, err := tx.ExecContext(ctx, query,
 		user.Email,
 		user.Password,
 		user.Name,
 		user.Role,
 		user.UpdatedAt,
 		user.ID,
+		expectedUpdatedAt,
 	)
 	if err != nil {
-		return errors.New("Failed to update a user, transaction rolled back. Reason: " + err.Error())
+		if database.IsUniqueViolation(err, "users_email_active_key") {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("Failed to update a user, transaction rolled back. Reason: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrUserModified
 	}
 	return nil
 }
 
-func (repository *UserRepositoryImpl) DeleteUser(ctx context.Context, tx *sql.Tx, id uint64) error {
-	SQL := `DELETE FROM users WHERE id = $1`
+func (repository *UserRepositoryImpl) UpdateStatus(ctx context.Context, tx *sql.Tx, id uint64, status string, updatedAt time.Time) error {
+	query := `UPDATE users SET status = $1, updated_at = $2 WHERE id = $3 AND deleted_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, query, status, updatedAt, id)
+	if err != nil {
+		return fmt.Errorf("Failed to update a user status, transaction rolled back. Reason: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser soft deletes a user. The row, and with it the loan and
+// activity history, stays until it is anonymized by AnonymizeUser.
+func (repository *UserRepositoryImpl) DeleteUser(ctx context.Context, tx *sql.Tx, id uint64, deletedAt time.Time) error {
+	SQL := `UPDATE users SET deleted_at = $1, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+
+	_, err := tx.ExecContext(ctx, SQL, deletedAt, id)
+	if err != nil {
+		return fmt.Errorf("Failed to delete a user, transaction rolled back. Reason: %w", err)
+	}
+	return nil
+}
+
+// FindErasableUsers locks users deleted before deletedBefore that still
+// hold personal data.
+func (repository *UserRepositoryImpl) FindErasableUsers(ctx context.Context, tx *sql.Tx, deletedBefore time.Time, limit int) ([]uint64, error) {
+	query := `
+		SELECT id FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1 AND erased_at IS NULL
+		ORDER BY deleted_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AnonymizeUser replaces the personal data of a deleted user. The id is
+// kept so that borrows and activities still add up in aggregate reports.
+func (repository *UserRepositoryImpl) AnonymizeUser(ctx context.Context, tx *sql.Tx, id uint64, erasedAt time.Time) error {
+	query := `
+		UPDATE users
+		SET email = 'erased-' || id || '@erased.invalid', name = 'Erased user', password = '', erased_at = $1
+		WHERE id = $2 AND deleted_at IS NOT NULL`
 
-	_, err := tx.ExecContext(ctx, SQL, id)
+	_, err := tx.ExecContext(ctx, query, erasedAt, id)
 	if err != nil {
-		return errors.New("Failed to update a user, transaction rolled back. Reason: " + err.Error())
+		return fmt.Errorf("Failed to anonymize a user, transaction rolled back. Reason: %w", err)
 	}
 	return nil
 }
 
-func (repository *UserRepositoryImpl) GetAllUsers(ctx context.Context, tx *sql.Tx, pagination *models.Pagination) ([]*models.User, error) {
-	query := `SELECT id, email, password, name, role, created_at, updated_at, count(*) over() FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2`
-	rows, err := tx.QueryContext(ctx, query, pagination.PageSize, pagination.Offset)
+// GetAllUsers returns a page of users matching filter. Search terms are
+// matched as literal substrings, so % and _ in them have no special meaning.
+func (repository *UserRepositoryImpl) GetAllUsers(ctx context.Context, tx *sql.Tx, filter *models.UserFilter, pagination *models.Pagination) ([]*models.User, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.Search != "" {
+		add(`(name ILIKE $? OR email ILIKE $?)`, "%"+likeEscaper.Replace(filter.Search)+"%")
+	}
+	if filter.Role != "" {
+		add(`role = $?`, filter.Role)
+	}
+	if filter.Status != "" {
+		add(`status = $?`, filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		add(`created_at >= $?`, *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add(`created_at < $?`, *filter.CreatedTo)
+	}
+
+	if pagination.CountTotal() {
+		query := `SELECT count(*) FROM users WHERE ` + strings.Join(conditions, " AND ")
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&pagination.TotalCount); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := userKeyset(filter.Sort)
+	position, positionArgs, err := keys.where(pagination.Cursor, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if position != "" {
+		conditions = append(conditions, position)
+		args = append(args, positionArgs...)
+	}
+
+	// One row more than the page tells whether another page follows.
+	args = append(args, pagination.PageSize+1, pagination.Offset)
+	query := fmt.Sprintf(`SELECT id, email, password, name, role, status, created_at, updated_at FROM users WHERE %s ORDER BY %s LIMIT $%d OFFSET $%d`,
+		strings.Join(conditions, " AND "), keys.orderBy(pagination.Cursor), len(args)-1, len(args))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +279,7 @@ func (repository *UserRepositoryImpl) GetAllUsers(ctx context.Context, tx *sql.T
 	var users []*models.User
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt, &pagination.TotalCount)
+		err := rows.Scan(&user.ID, &user.Email, &user.Password, &user.Name, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -118,5 +287,59 @@ func (repository *UserRepositoryImpl) GetAllUsers(ctx context.Context, tx *sql.T
 		users = append(users, &user)
 
 	}
-	return users, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keysetPage(keys, users, pagination, func(user *models.User) []string {
+		return userKeyValues(user, keys)
+	}), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// userKeyset builds the ordering from whitelisted fields only. The id is
+// always the last key, so that every user has a distinct position.
+func userKeyset(sort []models.SortField) keyset {
+	if len(sort) == 0 {
+		return keyset{listing: "users", keys: []sortKey{{"created_at", true}, {"id", true}}}
+	}
+
+	keys := make([]sortKey, 0, len(sort)+1)
+	hasID := false
+	for _, field := range sort {
+		column, ok := models.UserSortColumns[field.Field]
+		if !ok {
+			continue
+		}
+		keys = append(keys, sortKey{column, field.Desc})
+		hasID = hasID || column == "id"
+	}
+	if !hasID {
+		keys = append(keys, sortKey{"id", false})
+	}
+	return keyset{listing: "users", keys: keys}
+}
+
+func userKeyValues(user *models.User, keys keyset) []string {
+	values := make([]string, len(keys.keys))
+	for i, key := range keys.keys {
+		switch key.Column {
+		case "id":
+			values[i] = strconv.FormatUint(user.ID, 10)
+		case "name":
+			values[i] = user.Name
+		case "email":
+			values[i] = user.Email
+		case "role":
+			values[i] = user.Role
+		case "status":
+			values[i] = user.Status
+		case "created_at":
+			values[i] = keyTime(user.CreatedAt)
+		case "updated_at":
+			values[i] = keyTime(user.UpdatedAt)
+		}
+	}
+	return values
 }
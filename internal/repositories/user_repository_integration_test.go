@@ -0,0 +1,120 @@
+//go:build integration
+
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/models"
+	"library-api-user/internal/rbac"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestUser(email string) *models.User {
+	now := time.Now()
+	return &models.User{
+		Email:     email,
+		Password:  "hash",
+		Name:      "Reader",
+		Role:      models.DefaultRole,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func TestUserRepositoryEmailIsCaseInsensitive(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		repository := NewUserRepository()
+
+		user := newTestUser("Alice@example.com")
+		if err := repository.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		found, err := repository.FindUserByEmail(ctx, tx, "ALICE@EXAMPLE.COM")
+		if err != nil {
+			t.Fatalf("FindUserByEmail: %v", err)
+		}
+		if found.ID != user.ID {
+			t.Errorf("found user %d, want %d", found.ID, user.ID)
+		}
+
+		if err := repository.CreateUser(ctx, tx, newTestUser("alice@example.com")); err != ErrEmailTaken {
+			t.Errorf("CreateUser with the email in other case = %v, want ErrEmailTaken", err)
+		}
+	})
+}
+
+func TestUserRepositoryEmailOfDeletedUserIsFree(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		repository := NewUserRepository()
+
+		user := newTestUser("bob@example.com")
+		if err := repository.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := repository.DeleteUser(ctx, tx, user.ID, time.Now()); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+
+		if err := repository.CreateUser(ctx, tx, newTestUser("bob@example.com")); err != nil {
+			t.Errorf("CreateUser with the email of a deleted user: %v", err)
+		}
+		if _, err := repository.FindUserByID(ctx, tx, user.ID); err != ErrUserNotFound {
+			t.Errorf("FindUserByID of a deleted user = %v, want ErrUserNotFound", err)
+		}
+	})
+}
+
+// Timestamps are timestamptz, so the instant is kept whatever the zone of
+// the time written.
+func TestUserRepositoryTimestampsKeepTheInstant(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		repository := NewUserRepository()
+
+		createdAt := time.Date(2024, 3, 1, 23, 30, 0, 0, time.FixedZone("WIB", 7*60*60))
+		user := newTestUser("carol@example.com")
+		user.CreatedAt = createdAt
+		user.UpdatedAt = createdAt
+		if err := repository.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		found, err := repository.FindUserByID(ctx, tx, user.ID)
+		if err != nil {
+			t.Fatalf("FindUserByID: %v", err)
+		}
+		if !found.CreatedAt.Equal(createdAt) {
+			t.Errorf("created_at = %s, want %s", found.CreatedAt, createdAt)
+		}
+	})
+}
+
+func TestUserRepositoryFindActiveUserAccess(t *testing.T) {
+	withTx(t, func(ctx context.Context, tx *sql.Tx) {
+		repository := NewUserRepository()
+
+		user := newTestUser("dave@example.com")
+		if err := repository.CreateUser(ctx, tx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		access, err := repository.FindActiveUserAccess(ctx, tx, user.ID)
+		if err != nil {
+			t.Fatalf("FindActiveUserAccess: %v", err)
+		}
+		want := &models.Access{Role: models.DefaultRole, Permissions: []string{rbac.LoansRead, rbac.LoansWrite}}
+		if !reflect.DeepEqual(access, want) {
+			t.Errorf("access = %+v, want %+v", access, want)
+		}
+
+		if err := repository.UpdateStatus(ctx, tx, user.ID, models.UserStatusSuspended, time.Now()); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		if _, err := repository.FindActiveUserAccess(ctx, tx, user.ID); err != sql.ErrNoRows {
+			t.Errorf("FindActiveUserAccess of a suspended user = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
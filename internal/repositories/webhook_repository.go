@@ -0,0 +1,263 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/models"
+	"library-api-user/pkg/database"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, tx *sql.Tx, subscription *models.WebhookSubscription) error
+	FindSubscriptionByID(ctx context.Context, tx *sql.Tx, id uint64) (*models.WebhookSubscription, error)
+	FindSubscriptions(ctx context.Context, tx *sql.Tx) ([]*models.WebhookSubscription, error)
+	FindActiveSubscriptionsByEvent(ctx context.Context, tx *sql.Tx, eventType string) ([]*models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, tx *sql.Tx, subscription *models.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, tx *sql.Tx, id uint64) error
+
+	CreateDeliveries(ctx context.Context, tx *sql.Tx, deliveries []*models.WebhookDelivery) error
+	FindDeliveryByID(ctx context.Context, tx *sql.Tx, id uint64) (*models.WebhookDelivery, error)
+	FindDeliveries(ctx context.Context, tx *sql.Tx, subscriptionID uint64, status string, pagination *models.Pagination) ([]*models.WebhookDelivery, error)
+	FindDueDeliveries(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	ClaimDeliveries(ctx context.Context, tx *sql.Tx, ids []uint64, until time.Time) error
+	UpdateDelivery(ctx context.Context, tx *sql.Tx, delivery *models.WebhookDelivery) error
+}
+
+type WebhookRepositoryImpl struct {
+}
+
+func NewWebhookRepository() WebhookRepository {
+	return &WebhookRepositoryImpl{}
+}
+
+const webhookSubscriptionColumns = `id, url, secret, event_types, active, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func (repository *WebhookRepositoryImpl) CreateSubscription(ctx context.Context, tx *sql.Tx, subscription *models.WebhookSubscription) error {
+	query := `INSERT INTO webhook_subscriptions (url, secret, event_types, active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	return tx.QueryRowContext(ctx, query,
+		subscription.URL,
+		subscription.Secret,
+		subscription.EventTypes,
+		subscription.Active,
+		subscription.CreatedAt,
+		subscription.UpdatedAt,
+	).Scan(&subscription.ID)
+}
+
+func (repository *WebhookRepositoryImpl) FindSubscriptionByID(ctx context.Context, tx *sql.Tx, id uint64) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	return scanSubscription(tx.QueryRowContext(ctx, query, id))
+}
+
+func (repository *WebhookRepositoryImpl) FindSubscriptions(ctx context.Context, tx *sql.Tx) ([]*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY id`
+	return querySubscriptions(ctx, tx, query)
+}
+
+func (repository *WebhookRepositoryImpl) FindActiveSubscriptionsByEvent(ctx context.Context, tx *sql.Tx, eventType string) ([]*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE active AND $1 = ANY(event_types) ORDER BY id`
+	return querySubscriptions(ctx, tx, query, eventType)
+}
+
+func (repository *WebhookRepositoryImpl) UpdateSubscription(ctx context.Context, tx *sql.Tx, subscription *models.WebhookSubscription) error {
+	query := `UPDATE webhook_subscriptions SET url = $1, event_types = $2, active = $3, updated_at = $4 WHERE id = $5`
+	_, err := tx.ExecContext(ctx, query,
+		subscription.URL,
+		subscription.EventTypes,
+		subscription.Active,
+		subscription.UpdatedAt,
+		subscription.ID,
+	)
+	return err
+}
+
+func (repository *WebhookRepositoryImpl) DeleteSubscription(ctx context.Context, tx *sql.Tx, id uint64) error {
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+// CreateDeliveries inserts deliveries in bulk with COPY. Their IDs are not
+// read back.
+func (repository *WebhookRepositoryImpl) CreateDeliveries(ctx context.Context, tx *sql.Tx, deliveries []*models.WebhookDelivery) error {
+	rows := make([][]any, len(deliveries))
+	for i, delivery := range deliveries {
+		rows[i] = []any{
+			delivery.SubscriptionID,
+			delivery.EventID,
+			delivery.EventType,
+			delivery.Payload,
+			delivery.Status,
+			delivery.NextAttemptAt,
+			delivery.CreatedAt,
+		}
+	}
+	_, err := database.CopyFrom(ctx, tx, "webhook_deliveries", []string{"subscription_id", "event_id", "event_type", "payload", "status", "next_attempt_at", "created_at"}, rows)
+	return err
+}
+
+func (repository *WebhookRepositoryImpl) FindDeliveryByID(ctx context.Context, tx *sql.Tx, id uint64) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+	return scanDelivery(tx.QueryRowContext(ctx, query, id))
+}
+
+// FindDeliveries lists deliveries newest first. A zero subscriptionID or an
+// empty status disables the corresponding filter.
+func (repository *WebhookRepositoryImpl) FindDeliveries(ctx context.Context, tx *sql.Tx, subscriptionID uint64, status string, pagination *models.Pagination) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `, count(*) over()
+		FROM webhook_deliveries
+		WHERE ($1 = 0 OR subscription_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`
+	rows, err := tx.QueryContext(ctx, query, subscriptionID, status, pagination.PageSize, pagination.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.SubscriptionID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt,
+			&pagination.TotalCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// FindDueDeliveries locks pending deliveries whose retry time has come so
+// that dispatchers in other replicas skip them.
+func (repository *WebhookRepositoryImpl) FindDueDeliveries(ctx context.Context, tx *sql.Tx, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ClaimDeliveries pushes the retry time of the given deliveries to until, so
+// that no dispatcher picks them up again while they are being sent. If the
+// sender dies they become due again at that time.
+func (repository *WebhookRepositoryImpl) ClaimDeliveries(ctx context.Context, tx *sql.Tx, ids []uint64, until time.Time) error {
+	keys := make([]int64, len(ids))
+	for i, id := range ids {
+		keys[i] = int64(id)
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id = ANY($2)`, until, keys)
+	return err
+}
+
+func (repository *WebhookRepositoryImpl) UpdateDelivery(ctx context.Context, tx *sql.Tx, delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5, delivered_at = $6
+		WHERE id = $7`
+	_, err := tx.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func querySubscriptions(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]*models.WebhookSubscription, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []*models.WebhookSubscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func scanSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.URL,
+		&subscription.Secret,
+		database.Array(&subscription.EventTypes),
+		&subscription.Active,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
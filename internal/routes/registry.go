@@ -0,0 +1,202 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"library-api-user/internal/middleware"
+	"library-api-user/internal/rbac"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MethodAny registers a route for every HTTP method, like gin's Any.
+const MethodAny = "ANY"
+
+type AccessKind int
+
+const (
+	accessUndeclared AccessKind = iota
+	AccessPublic
+	AccessAuthenticated
+	AccessRole
+	AccessPermission
+)
+
+// Access is the requirement a request must meet before it reaches the
+// handler of a route. The zero value is rejected by Validate, so every
+// route has to state its requirement explicitly.
+type Access struct {
+	Kind   AccessKind
+	Values []string
+}
+
+func Public() Access {
+	return Access{Kind: AccessPublic}
+}
+
+func Authenticated() Access {
+	return Access{Kind: AccessAuthenticated}
+}
+
+func Role(roles ...string) Access {
+	return Access{Kind: AccessRole, Values: roles}
+}
+
+func Permission(permissions ...string) Access {
+	return Access{Kind: AccessPermission, Values: permissions}
+}
+
+func (a Access) String() string {
+	switch a.Kind {
+	case AccessPublic:
+		return "public"
+	case AccessAuthenticated:
+		return "authenticated"
+	case AccessRole:
+		return "role " + strings.Join(a.Values, ",")
+	case AccessPermission:
+		return "permission " + strings.Join(a.Values, ",")
+	}
+	return "undeclared"
+}
+
+func (a Access) handlers(auth *middleware.Auth) []gin.HandlerFunc {
+	switch a.Kind {
+	case AccessAuthenticated:
+		return []gin.HandlerFunc{auth.CheckAuth()}
+	case AccessRole:
+		return []gin.HandlerFunc{auth.RequireRole(a.Values...)}
+	case AccessPermission:
+		return []gin.HandlerFunc{auth.RequirePermission(a.Values...)}
+	}
+	return nil
+}
+
+// Route is one entry of the route table. Path is the full path, including
+// the /api prefix.
+type Route struct {
+	Method  string
+	Path    string
+	Access  Access
+	Handler gin.HandlerFunc
+}
+
+// Register adds every route of the table to the router, each with the
+// middleware of its own access requirement. Routes never share middleware
+// through groups, so the order of the table does not change what a route
+// requires.
+func Register(router *gin.Engine, auth *middleware.Auth, table []Route) {
+	for _, route := range table {
+		handlers := append(route.Access.handlers(auth), route.Handler)
+		if route.Method == MethodAny {
+			router.Any(route.Path, handlers...)
+			continue
+		}
+		router.Handle(route.Method, route.Path, handlers...)
+	}
+}
+
+// Validate checks the route table before anything is registered. It rejects
+// routes without an access requirement, role and permission requirements
+// that name nothing or an unknown permission, duplicated routes, and
+// routes that can match the same request while requiring different access.
+func Validate(table []Route) error {
+	known := make(map[string]bool, len(rbac.All))
+	for _, permission := range rbac.All {
+		known[permission] = true
+	}
+
+	var errs []error
+	for i, route := range table {
+		name := route.Method + " " + route.Path
+
+		if route.Handler == nil {
+			errs = append(errs, fmt.Errorf("%s: no handler", name))
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			errs = append(errs, fmt.Errorf("%s: path must start with /", name))
+		}
+
+		switch route.Access.Kind {
+		case AccessPublic, AccessAuthenticated:
+		case AccessRole:
+			if len(route.Access.Values) == 0 {
+				errs = append(errs, fmt.Errorf("%s: role access without roles", name))
+			}
+		case AccessPermission:
+			if len(route.Access.Values) == 0 {
+				errs = append(errs, fmt.Errorf("%s: permission access without permissions", name))
+			}
+			for _, permission := range route.Access.Values {
+				if !known[permission] {
+					errs = append(errs, fmt.Errorf("%s: unknown permission %q", name, permission))
+				}
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: access requirement is not declared", name))
+		}
+
+		for _, other := range table[:i] {
+			if !sameMethod(route.Method, other.Method) {
+				continue
+			}
+			switch overlap(route.Path, other.Path) {
+			case overlapIdentical:
+				errs = append(errs, fmt.Errorf("%s: ambiguous with %s %s", name, other.Method, other.Path))
+			case overlapPartial:
+				if route.Access.String() != other.Access.String() {
+					errs = append(errs, fmt.Errorf("%s (%s) overlaps %s %s (%s) with different access", name, route.Access, other.Method, other.Path, other.Access))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type overlapKind int
+
+const (
+	overlapNone overlapKind = iota
+	overlapPartial
+	overlapIdentical
+)
+
+// overlap reports whether a request path could match both patterns.
+// Patterns that differ only in the names of their parameters are
+// identical; patterns where a static segment meets a parameter overlap
+// partially, and the router picks the static one.
+func overlap(a, b string) overlapKind {
+	as := strings.Split(strings.Trim(a, "/"), "/")
+	bs := strings.Split(strings.Trim(b, "/"), "/")
+
+	identical := true
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, y := as[i], bs[i]
+		if strings.HasPrefix(x, "*") || strings.HasPrefix(y, "*") {
+			if strings.HasPrefix(x, "*") && strings.HasPrefix(y, "*") && len(as) == len(bs) && identical {
+				return overlapIdentical
+			}
+			return overlapPartial
+		}
+		xParam, yParam := strings.HasPrefix(x, ":"), strings.HasPrefix(y, ":")
+		switch {
+		case xParam && yParam:
+		case xParam || yParam:
+			identical = false
+		case x != y:
+			return overlapNone
+		}
+	}
+	if len(as) != len(bs) {
+		return overlapNone
+	}
+	if identical {
+		return overlapIdentical
+	}
+	return overlapPartial
+}
+
+func sameMethod(a, b string) bool {
+	return a == b || a == MethodAny || b == MethodAny
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"library-api-user/internal/factory"
 	"library-api-user/internal/middleware"
+	"library-api-user/proto/openapi"
+	"log"
 	"net/http"
 	"time"
 
@@ -11,45 +13,62 @@ import (
 )
 
 func RegisterRoutes(provider *factory.Provider) *gin.Engine {
-	router := gin.New()
+	table := Routes(provider)
+	if err := Validate(table); err != nil {
+		log.Fatalf("[Routes] Invalid route table: %v", err)
+	}
 
-	router.Use(gin.Logger(), CORS())
+	router := gin.New()
+	// Let services read values stored on the request context, such as the
+	// audit client and actor, through the *gin.Context they are given.
+	router.ContextWithFallback = true
 
-	router.GET("/", func(ctx *gin.Context) {
-		currentYear := time.Now().Year()
-		message := fmt.Sprintf("Library API User %d", currentYear)
+	router.Use(
+		middleware.RequestID(),
+		middleware.Tracing(),
+		middleware.AccessLog(provider.Logger),
+		middleware.Metrics(),
+		CORS(),
+		middleware.ClientInfo(),
+	)
 
-		ctx.JSON(http.StatusOK, message)
-	})
+	Register(router, provider.Auth, table)
 
-	api := router.Group("/api")
-	{
-		v1 := api.Group("v1")
-		{
+	return router
+}
 
-			v1.POST("/register", provider.AuthProvider.Register)
-			v1.POST("/login", provider.AuthProvider.Login)
+// Routes is the route table of the service. Each route states its own
+// access requirement; see Register and Validate.
+func Routes(provider *factory.Provider) []Route {
+	return []Route{
+		{http.MethodGet, "/", Public(), func(ctx *gin.Context) {
+			currentYear := time.Now().Year()
+			message := fmt.Sprintf("Library API User %d", currentYear)
 
-			admin := v1.Use(middleware.CheckAuthIsAdmin())
-			admin.GET("/users", provider.UserProvider.GetAll)
-			admin.GET("/users/:id", provider.UserProvider.Detail)
-			admin.PUT("/users/manage", provider.UserProvider.Update)
+			ctx.JSON(http.StatusOK, message)
+		}},
+		{http.MethodGet, "/healthz", Public(), provider.HealthProvider.Liveness},
+		{http.MethodGet, "/readyz", Public(), provider.HealthProvider.Readiness},
+		{http.MethodGet, "/openapi.json", Public(), func(ctx *gin.Context) {
+			ctx.Data(http.StatusOK, "application/json", openapi.Spec)
+		}},
 
-			auth := v1.Use(middleware.CheckAuth())
-			auth.POST("/users/:id/borrow", provider.UserProvider.BorrowBook)
-			auth.POST("/users/:id/return", provider.UserProvider.ReturnBook)
-		}
+		// Both API versions are served by the gRPC gateway generated from
+		// the proto definitions; v1 rewrites its requests to v2 and answers
+		// in the v1 envelope. Access is checked by the gRPC auth interceptor
+		// behind the gateway.
+		{MethodAny, "/api/v1/*path", Public(), gin.WrapH(provider.LegacyGateway)},
+		{MethodAny, "/api/v2/*path", Public(), gin.WrapH(provider.Gateway)},
 	}
-
-	return router
 }
 
 func CORS() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS, POST, PUT, DELETE")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, accept, access-control-allow-origin, access-control-allow-headers")
+		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS, POST, PUT, PATCH, DELETE")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, X-Request-ID, accept, access-control-allow-origin, access-control-allow-headers")
+		ctx.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, X-Request-ID")
 		if ctx.Request.Method == "OPTIONS" {
 			ctx.AbortWithStatus(http.StatusNoContent)
 		}
@@ -0,0 +1,160 @@
+package routes
+
+import (
+	"context"
+	"library-api-user/internal/controllers"
+	"library-api-user/internal/factory"
+	"library-api-user/internal/middleware"
+	"library-api-user/internal/models"
+	"library-api-user/pkg/token"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expectedAccess is the access matrix of the HTTP routes. Every route of
+// the table must be listed, so a new route cannot be added without stating
+// who may call it. Both API versions are public here; the gRPC interceptor
+// behind the gateway checks access to each method.
+var expectedAccess = map[string]string{
+	"GET /":             anyone,
+	"GET /healthz":      anyone,
+	"GET /readyz":       anyone,
+	"GET /openapi.json": anyone,
+
+	"ANY /api/v1/*path": anyone,
+	"ANY /api/v2/*path": anyone,
+}
+
+// Who may call a route, independently of what the route table declares.
+const (
+	anyone = "anyone"
+	signed = "signed in"
+)
+
+// accounts holds the accounts of the test.
+type accounts map[uint64]*models.Access
+
+func (a accounts) Access(ctx context.Context, userID uint64) (*models.Access, error) {
+	return a[userID], nil
+}
+
+const userID uint64 = 1
+
+var testAccounts = accounts{
+	userID: {Role: "user", Permissions: []string{}},
+}
+
+// testProvider has controllers without services; their handlers are only
+// referenced, never called.
+func testProvider() *factory.Provider {
+	return &factory.Provider{
+		HealthProvider: &controllers.HealthControllerImpl{},
+	}
+}
+
+// testRouter registers the real route table with a handler that answers
+// 200 in place of every controller, so only access is checked.
+func testRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	table := Routes(testProvider())
+	if err := Validate(table); err != nil {
+		t.Fatalf("invalid route table: %v", err)
+	}
+	for i := range table {
+		table[i].Handler = func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		}
+	}
+
+	router := gin.New()
+	Register(router, middleware.NewAuth(testAccounts), table)
+	return router
+}
+
+func bearer(t *testing.T, id uint64, role string, permissions []string) string {
+	t.Helper()
+	token.SetKey("router-test-signing-key-of-32-chars")
+	signedToken, err := token.GenerateToken(int(id), role, permissions)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	return "Bearer " + signedToken
+}
+
+// requestPath fills the parameters of a route pattern.
+func requestPath(pattern string) string {
+	replacer := strings.NewReplacer(":id", "1", "*path", "ping")
+	return replacer.Replace(pattern)
+}
+
+func TestRoutesAccess(t *testing.T) {
+	router := testRouter(t)
+
+	callers := []struct {
+		name          string
+		authorization string
+		role          string
+	}{
+		{"no token", "", ""},
+		{"user", bearer(t, userID, "user", nil), "user"},
+	}
+
+	table := Routes(testProvider())
+	listed := make(map[string]bool, len(table))
+	for _, route := range table {
+		key := route.Method + " " + route.Path
+		listed[key] = true
+
+		who, ok := expectedAccess[key]
+		if !ok {
+			t.Errorf("%s: missing from expectedAccess", key)
+			continue
+		}
+
+		method := route.Method
+		if method == MethodAny {
+			method = http.MethodGet
+		}
+
+		for _, caller := range callers {
+			t.Run(key+"/"+caller.name, func(t *testing.T) {
+				want := expectedStatus(who, caller.role)
+
+				req := httptest.NewRequest(method, requestPath(route.Path), nil)
+				if caller.authorization != "" {
+					req.Header.Set("Authorization", caller.authorization)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != want {
+					t.Errorf("status = %d, want %d", rec.Code, want)
+				}
+			})
+		}
+	}
+
+	for key := range expectedAccess {
+		if !listed[key] {
+			t.Errorf("%s: listed in expectedAccess but not routed", key)
+		}
+	}
+}
+
+func expectedStatus(who string, role string) int {
+	switch {
+	case who == anyone:
+		return http.StatusOK
+	case role == "":
+		return http.StatusUnauthorized
+	case who == signed:
+		return http.StatusOK
+	}
+	return http.StatusForbidden
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"library-api-user/internal/audit"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"library-api-user/internal/repositories"
+	"library-api-user/pkg/database"
+)
+
+type AuditService interface {
+	Events(ctx context.Context, query *params.AuditEventQuery, pagination *models.Pagination) ([]*params.AuditEventResponse, *response.CustomError)
+	Verify(ctx context.Context) (*audit.Verification, *response.CustomError)
+}
+
+type AuditServiceImpl struct {
+	AuditEventRepository repositories.AuditEventRepository
+	AuditRecorder        *audit.Recorder
+	DB                   *sql.DB
+	Tx                   *database.TxManager
+	Logger               logger.Logger
+}
+
+func NewAuditService(db *sql.DB, auditEventRepository repositories.AuditEventRepository, auditRecorder *audit.Recorder, log logger.Logger) AuditService {
+	return &AuditServiceImpl{
+		AuditEventRepository: auditEventRepository,
+		AuditRecorder:        auditRecorder,
+		DB:                   db,
+		Tx:                   database.NewTxManager(db),
+		Logger:               log,
+	}
+}
+
+func (service *AuditServiceImpl) Events(ctx context.Context, query *params.AuditEventQuery, pagination *models.Pagination) ([]*params.AuditEventResponse, *response.CustomError) {
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, response.BadRequestError("from must be before to")
+	}
+
+	filter := models.AuditFilter{
+		ActorID:    query.ActorID,
+		TargetType: query.TargetType,
+		TargetID:   query.TargetID,
+		Action:     query.Action,
+		From:       query.From,
+		To:         query.To,
+	}
+	// occurred_at is stored in UTC without a time zone.
+	if filter.From != nil {
+		from := filter.From.UTC()
+		filter.From = &from
+	}
+	if filter.To != nil {
+		to := filter.To.UTC()
+		filter.To = &to
+	}
+
+	var events []*models.AuditEvent
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) (err error) {
+		events, err = service.AuditEventRepository.FindEvents(ctx, tx, &filter, pagination)
+		return err
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[AuditService] Failed to fetch audit events - Events", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, response.GeneralError("Failed to fetch audit events: " + err.Error()).WithCause(err)
+	}
+
+	eventResponses := make([]*params.AuditEventResponse, len(events))
+	for i, event := range events {
+		eventResponses[i] = &params.AuditEventResponse{
+			ID:         event.ID,
+			OccurredAt: event.OccurredAt,
+			ActorID:    event.ActorID,
+			ActorRole:  event.ActorRole,
+			Action:     event.Action,
+			TargetType: event.TargetType,
+			TargetID:   event.TargetID,
+			Before:     rawJSON(event.Before),
+			After:      rawJSON(event.After),
+			IP:         event.IP,
+			UserAgent:  event.UserAgent,
+			PrevHash:   event.PrevHash,
+			Hash:       event.Hash,
+		}
+	}
+
+	pagination.PageCount = (pagination.TotalCount + pagination.PageSize - 1) / pagination.PageSize
+
+	return eventResponses, nil
+}
+
+// Verify walks the whole hash chain. A broken chain is a result, not an
+// error, so it is returned with 200 and valid set to false.
+func (service *AuditServiceImpl) Verify(ctx context.Context) (*audit.Verification, *response.CustomError) {
+	result, err := service.AuditRecorder.Verify(ctx)
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[AuditService] Failed to verify audit chain - Verify", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, response.GeneralError("Failed to verify audit chain: " + err.Error()).WithCause(err)
+	}
+	if !result.Valid {
+		service.Logger.WithContext(ctx).Error("[AuditService] Audit chain is broken - Verify", map[string]interface{}{
+			"broken_at": *result.BrokenAt,
+			"reason":    result.Reason,
+		})
+	}
+	return result, nil
+}
+
+func rawJSON(value []byte) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
@@ -3,12 +3,17 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"library-api-user/internal/audit"
 	"library-api-user/internal/commons/response"
+	"library-api-user/internal/events"
 	"library-api-user/internal/logger"
 	"library-api-user/internal/models"
 	"library-api-user/internal/params"
 	"library-api-user/internal/repositories"
+	"library-api-user/internal/webhooks"
+	"library-api-user/pkg/database"
 	"library-api-user/pkg/token"
 	"time"
 
@@ -21,16 +26,26 @@ type AuthService interface {
 }
 
 type AuthServiceImpl struct {
-	UserRepository repositories.UserRepository
-	DB             *sql.DB
-	Logger         logger.Logger
+	UserRepository   repositories.UserRepository
+	RoleRepository   repositories.RoleRepository
+	OutboxRepository repositories.OutboxRepository
+	Webhooks         *webhooks.Enqueuer
+	AuditRecorder    *audit.Recorder
+	DB               *sql.DB
+	Tx               *database.TxManager
+	Logger           logger.Logger
 }
 
-func NewAuthService(db *sql.DB, userRepository repositories.UserRepository, log logger.Logger) AuthService {
+func NewAuthService(db *sql.DB, userRepository repositories.UserRepository, roleRepository repositories.RoleRepository, outboxRepository repositories.OutboxRepository, webhookEnqueuer *webhooks.Enqueuer, auditRecorder *audit.Recorder, log logger.Logger) AuthService {
 	return &AuthServiceImpl{
-		UserRepository: userRepository,
-		DB:             db,
-		Logger:         log,
+		UserRepository:   userRepository,
+		RoleRepository:   roleRepository,
+		OutboxRepository: outboxRepository,
+		Webhooks:         webhookEnqueuer,
+		AuditRecorder:    auditRecorder,
+		DB:               db,
+		Tx:               database.NewTxManager(db),
+		Logger:           log,
 	}
 }
 
@@ -42,58 +57,93 @@ func (service *AuthServiceImpl) Register(ctx context.Context, req *params.Regist
 		for i, fieldError := range validationErrors {
 			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
 		}
-		service.Logger.Error("[AuthService] Validation failed - Register", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[AuthService] Validation failed - Register", map[string]interface{}{
 			"error": errors,
 		})
 		return response.BadRequestErrorWithAdditionalInfo(errors)
 	}
 
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[AuthService] Failed to begin transaction - Register", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return response.GeneralError("Failed to begin transaction: " + err.Error())
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[AuthService] Transaction rolled back due to panic - Register", map[string]interface{}{
-				"error": r,
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		existingUser, err := service.UserRepository.FindUserByEmail(ctx, tx, req.Email)
+		if existingUser != nil || err == nil {
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed email already exists! - Register", map[string]interface{}{
+				"email": req.Email,
 			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[AuthService] Transaction rolled back due to error - Register", map[string]interface{}{
+			return response.ConflictError("Email already exists!")
+		}
+
+		user := models.User{
+			Email:     req.Email,
+			Password:  req.Password,
+			Name:      req.Name,
+			Role:      models.DefaultRole,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		if err := service.UserRepository.CreateUser(ctx, tx, &user); err != nil {
+			// Someone registered the same email since it was checked above.
+			if err == repositories.ErrEmailTaken {
+				return response.ConflictError("Email already exists!")
+			}
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to create user - Register", map[string]interface{}{
+				"email": req.Email,
 				"error": err.Error(),
 			})
-		} else {
-			tx.Commit()
+			return response.GeneralError("Failed to create user: " + err.Error()).WithCause(err)
 		}
-	}()
 
-	existingUser, err := service.UserRepository.FindUserByEmail(ctx, tx, req.Email)
-	if existingUser != nil || err == nil {
-		service.Logger.Error("[AuthService] Failed email already exists! - Register", map[string]interface{}{
-			"email": req.Email,
+		event, err := events.NewOutboxEvent(events.UserRegistered, user.ID, events.UserRegisteredPayload{
+			UserID: user.ID,
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role,
 		})
-		return response.BadRequestError("Email already exists!")
-	}
+		if err == nil {
+			err = service.OutboxRepository.CreateEvent(ctx, tx, event)
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to record user registered event - Register", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record user registered event: " + err.Error()).WithCause(err)
+		}
 
-	user := models.User{
-		Email:     req.Email,
-		Password:  req.Password,
-		Name:      req.Name,
-		Role:      req.Role,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
+		err = service.Webhooks.Enqueue(ctx, tx, webhooks.AccountCreated, webhooks.AccountData{
+			UserID: user.ID,
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role,
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to enqueue webhook - Register", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to enqueue webhook: " + err.Error()).WithCause(err)
+		}
 
-	if err := service.UserRepository.CreateUser(ctx, tx, &user); err != nil {
-		service.Logger.Error("[AuthService] Failed to create user - Register", map[string]interface{}{
-			"email": req.Email,
-			"error": err.Error(),
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditRegister,
+			ActorID:    &user.ID,
+			ActorRole:  user.Role,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &user.ID,
+			After:      userAuditState(&user),
 		})
-		return response.GeneralError("Failed to create user: " + err.Error())
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to record audit event - Register", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return service.txFailure(ctx, err, "Register")
 	}
 
 	return nil
@@ -107,67 +157,141 @@ func (service *AuthServiceImpl) Login(ctx context.Context, req *params.LoginRequ
 		for i, fieldError := range validationErrors {
 			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
 		}
-		service.Logger.Error("[AuthService] Validation failed - Login", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[AuthService] Validation failed - Login", map[string]interface{}{
 			"error": errors,
 		})
 		return nil, response.BadRequestErrorWithAdditionalInfo(errors)
 	}
 
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[AuthService] Failed to begin transaction - Login", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return nil, response.GeneralError("Failed to begin transaction: " + err.Error())
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[AuthService] Transaction rolled back due to panic - Login", map[string]interface{}{
-				"error": r,
-			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[AuthService] Transaction rolled back due to error - Login", map[string]interface{}{
+	var user *models.User
+	var permissions []string
+	// A failed login is recorded once the transaction is over: WithinTx may
+	// run the function more than once.
+	var failure *loginFailure
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		failure = nil
+
+		var err error
+		user, err = service.UserRepository.FindUserByEmail(ctx, tx, req.Email)
+		if err != nil {
+			if err == repositories.ErrUserNotFound {
+				service.Logger.WithContext(ctx).Warn("[AuthService] User not found - Login", map[string]interface{}{
+					"email": req.Email,
+				})
+				failure = &loginFailure{reason: "unknown email"}
+				return response.BadRequestError("Invalid email or password")
+			}
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to find user by email - Login", map[string]interface{}{
+				"email": req.Email,
 				"error": err.Error(),
 			})
-		} else {
-			tx.Commit()
+			return response.GeneralError("Failed to find user: " + err.Error()).WithCause(err)
 		}
-	}()
 
-	user, err := service.UserRepository.FindUserByEmail(ctx, tx, req.Email)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			service.Logger.Warn("[AuthService] User not found - Login", map[string]interface{}{
+		if user.Password != req.Password {
+			service.Logger.WithContext(ctx).Warn("[AuthService] Invalid password - Login", map[string]interface{}{
 				"email": req.Email,
 			})
-			return nil, response.BadRequestError("Invalid email or password")
+			failure = &loginFailure{userID: &user.ID, reason: "invalid password"}
+			return response.BadRequestError("Invalid email or password")
+		}
+
+		if user.Status != models.UserStatusActive {
+			service.Logger.WithContext(ctx).Warn("[AuthService] Account is not active - Login", map[string]interface{}{
+				"user_id": user.ID,
+				"status":  user.Status,
+			})
+			failure = &loginFailure{userID: &user.ID, reason: "account is " + user.Status}
+			return response.ForbiddenError("Account is " + user.Status)
 		}
-		service.Logger.Error("[AuthService] Failed to find user by email - Login", map[string]interface{}{
-			"email": req.Email,
-			"error": err.Error(),
-		})
-		return nil, response.GeneralError("Failed to find user: " + err.Error())
-	}
 
-	if user.Password != req.Password {
-		service.Logger.Warn("[AuthService] Invalid password - Login", map[string]interface{}{
-			"email": req.Email,
+		permissions, err = service.RoleRepository.FindPermissionsByRole(ctx, tx, user.Role)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to resolve role permissions - Login", map[string]interface{}{
+				"user_id": user.ID,
+				"role":    user.Role,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to resolve role permissions: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditLogin,
+			ActorID:    &user.ID,
+			ActorRole:  user.Role,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &user.ID,
 		})
-		return nil, response.BadRequestError("Invalid email or password")
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[AuthService] Failed to record audit event - Login", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+
+		return nil
+	})
+	if failure != nil {
+		service.recordLoginFailure(ctx, failure.userID, req.Email, failure.reason)
+	}
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Login")
 	}
 
-	token, err := token.GenerateToken(int(user.ID), user.Role)
+	// The token is only handed out once the login is recorded.
+	token, err := token.GenerateToken(int(user.ID), user.Role, permissions)
 	if err != nil {
-		service.Logger.Error("[AuthService] Failed to generate token - Login", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[AuthService] Failed to generate token - Login", map[string]interface{}{
 			"user_id": user.ID,
 			"error":   err.Error(),
 		})
-		return nil, response.GeneralError("Failed to generate token: " + err.Error())
+		return nil, response.GeneralError("Failed to generate token: " + err.Error()).WithCause(err)
 	}
 
 	return &params.LoginResponse{
 		Token: token,
 	}, nil
 }
+
+// loginFailure is why a login was refused, for the audit log.
+type loginFailure struct {
+	userID *uint64
+	reason string
+}
+
+// recordLoginFailure writes a failed login to the audit log in a
+// transaction of its own, since the login transaction is not committed
+// with anything worth keeping. A failure to record is logged only, so the
+// caller still gets the same answer for a wrong password.
+func (service *AuthServiceImpl) recordLoginFailure(ctx context.Context, userID *uint64, email string, reason string) {
+	err := service.AuditRecorder.RecordNow(ctx, audit.Entry{
+		Action:     models.AuditLoginFailed,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+		After: map[string]interface{}{
+			"email":  email,
+			"reason": reason,
+		},
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[AuthService] Failed to record audit event - Login", map[string]interface{}{
+			"email": email,
+			"error": err.Error(),
+		})
+	}
+}
+
+// txFailure turns an error of WithinTx into a response. Errors of the
+// transaction function are responses already; the others come from
+// beginning or committing the transaction.
+func (service *AuthServiceImpl) txFailure(ctx context.Context, err error, method string) *response.CustomError {
+	var custErr *response.CustomError
+	if errors.As(err, &custErr) {
+		return custErr
+	}
+	service.Logger.WithContext(ctx).Error("[AuthService] Transaction failed - "+method, map[string]interface{}{
+		"error": err.Error(),
+	})
+	return response.GeneralError("Transaction failed: " + err.Error()).WithCause(err)
+}
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"library-api-user/internal/audit"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"library-api-user/internal/repositories"
+	"library-api-user/internal/storage"
+	"library-api-user/pkg/database"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultMaxAvatarSize is used when AVATAR_MAX_BYTES is not configured.
+const DefaultMaxAvatarSize = 2 << 20
+
+// avatarTypes are the accepted avatar formats. The type is sniffed from
+// the content; the type declared by the client is ignored.
+var avatarTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+type ProfileService interface {
+	Get(ctx context.Context, userID uint64) (*params.ProfileResponse, *response.CustomError)
+	Update(ctx context.Context, userID uint64, req *params.ProfilePatchRequest) (*params.ProfileResponse, *response.CustomError)
+	PutAvatar(ctx context.Context, userID uint64, content io.Reader) (*params.ProfileResponse, *response.CustomError)
+	Avatar(ctx context.Context, userID uint64) (io.ReadCloser, *params.AvatarResponse, *response.CustomError)
+	DeleteAvatar(ctx context.Context, userID uint64) *response.CustomError
+	MaxAvatarSize() int64
+}
+
+type ProfileServiceImpl struct {
+	UserRepository    repositories.UserRepository
+	ProfileRepository repositories.ProfileRepository
+	Blobs             storage.BlobStore
+	AuditRecorder     *audit.Recorder
+	MaxAvatarBytes    int64
+	DB                *sql.DB
+	Tx                *database.TxManager
+	Logger            logger.Logger
+}
+
+func NewProfileService(db *sql.DB, userRepository repositories.UserRepository, profileRepository repositories.ProfileRepository, blobs storage.BlobStore, maxAvatarBytes int64, auditRecorder *audit.Recorder, log logger.Logger) ProfileService {
+	if maxAvatarBytes <= 0 {
+		maxAvatarBytes = DefaultMaxAvatarSize
+	}
+	return &ProfileServiceImpl{
+		UserRepository:    userRepository,
+		ProfileRepository: profileRepository,
+		Blobs:             blobs,
+		AuditRecorder:     auditRecorder,
+		MaxAvatarBytes:    maxAvatarBytes,
+		DB:                db,
+		Tx:                database.NewTxManager(db),
+		Logger:            log,
+	}
+}
+
+func (service *ProfileServiceImpl) MaxAvatarSize() int64 {
+	return service.MaxAvatarBytes
+}
+
+// Get returns the profile of a user. Reads of another user's profile are
+// audited; reading your own is not.
+func (service *ProfileServiceImpl) Get(ctx context.Context, userID uint64) (*params.ProfileResponse, *response.CustomError) {
+	var profile *models.UserProfile
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		if custErr := service.checkUser(ctx, tx, userID, "Get"); custErr != nil {
+			return custErr
+		}
+
+		var err error
+		profile, err = service.ProfileRepository.FindProfile(ctx, tx, userID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to find profile - Get", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find profile: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Get")
+	}
+
+	if custErr := service.recordRead(ctx, userID, "profile", "Get"); custErr != nil {
+		return nil, custErr
+	}
+
+	return toProfileResponse(profile), nil
+}
+
+func (service *ProfileServiceImpl) Update(ctx context.Context, userID uint64, req *params.ProfilePatchRequest) (*params.ProfileResponse, *response.CustomError) {
+	val := validator.New()
+	err := val.Struct(req)
+	if err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
+		}
+		service.Logger.WithContext(ctx).Error("[ProfileService] Validation failed - Update", map[string]interface{}{
+			"error": errors,
+		})
+		return nil, response.BadRequestErrorWithAdditionalInfo(errors)
+	}
+
+	var profile *models.UserProfile
+	err = service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		if custErr := service.checkUser(ctx, tx, userID, "Update"); custErr != nil {
+			return custErr
+		}
+
+		var err error
+		profile, err = service.ProfileRepository.FindProfileForUpdate(ctx, tx, userID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to find profile - Update", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find profile: " + err.Error()).WithCause(err)
+		}
+		before := profileAuditState(profile)
+
+		if req.Phone != nil {
+			profile.Phone = *req.Phone
+		}
+		if req.Address != nil {
+			profile.AddressLine1 = req.Address.Line1
+			profile.AddressLine2 = req.Address.Line2
+			profile.City = req.Address.City
+			profile.Region = req.Address.Region
+			profile.PostalCode = req.Address.PostalCode
+			profile.Country = req.Address.Country
+		}
+		if req.Preferences != nil {
+			preferences := decodePreferences(profile.Preferences)
+			if req.Preferences.NotificationChannel != nil {
+				preferences.NotificationChannel = *req.Preferences.NotificationChannel
+			}
+			if req.Preferences.Language != nil {
+				preferences.Language = *req.Preferences.Language
+			}
+			profile.Preferences, err = json.Marshal(preferences)
+			if err != nil {
+				return response.GeneralError("Failed to encode preferences: " + err.Error()).WithCause(err)
+			}
+		}
+		profile.UpdatedAt = time.Now()
+
+		err = service.ProfileRepository.UpdateProfile(ctx, tx, profile)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to update profile - Update", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to update profile: " + err.Error()).WithCause(err)
+		}
+
+		changedBefore, changedAfter := audit.Diff(before, profileAuditState(profile))
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditProfileUpdate,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &userID,
+			Before:     changedBefore,
+			After:      changedAfter,
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to record audit event - Update", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Update")
+	}
+
+	return toProfileResponse(profile), nil
+}
+
+// PutAvatar checks the size and format of an uploaded avatar and stores it
+// under a new key. The previous avatar is removed once the new one is
+// committed.
+func (service *ProfileServiceImpl) PutAvatar(ctx context.Context, userID uint64, content io.Reader) (*params.ProfileResponse, *response.CustomError) {
+	data, err := io.ReadAll(io.LimitReader(content, service.MaxAvatarBytes+1))
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[ProfileService] Failed to read avatar - PutAvatar", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return nil, response.BadRequestError("Failed to read avatar: " + err.Error())
+	}
+	if int64(len(data)) > service.MaxAvatarBytes {
+		return nil, response.PayloadTooLargeError(fmt.Sprintf("Avatar must not be larger than %d bytes", service.MaxAvatarBytes))
+	}
+	if len(data) == 0 {
+		return nil, response.BadRequestError("Avatar is empty")
+	}
+	contentType := http.DetectContentType(data)
+	if !avatarTypes[contentType] {
+		service.Logger.WithContext(ctx).Warn("[ProfileService] Unsupported avatar type - PutAvatar", map[string]interface{}{
+			"user_id":      userID,
+			"content_type": contentType,
+		})
+		return nil, response.UnsupportedMediaTypeError("Avatar must be a JPEG, PNG or WebP image")
+	}
+
+	key := fmt.Sprintf("avatars/%d/%d", userID, time.Now().UnixNano())
+	if err := service.Blobs.Put(ctx, key, bytes.NewReader(data)); err != nil {
+		service.Logger.WithContext(ctx).Error("[ProfileService] Failed to store avatar - PutAvatar", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return nil, response.GeneralError("Failed to store avatar: " + err.Error()).WithCause(err)
+	}
+
+	profile, previousKey, custErr := service.setAvatar(ctx, userID, key, contentType, int64(len(data)), "PutAvatar")
+	if custErr != nil {
+		service.deleteBlob(ctx, userID, key, "PutAvatar")
+		return nil, custErr
+	}
+	service.deleteBlob(ctx, userID, previousKey, "PutAvatar")
+
+	return toProfileResponse(profile), nil
+}
+
+func (service *ProfileServiceImpl) Avatar(ctx context.Context, userID uint64) (io.ReadCloser, *params.AvatarResponse, *response.CustomError) {
+	var profile *models.UserProfile
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		if custErr := service.checkUser(ctx, tx, userID, "Avatar"); custErr != nil {
+			return custErr
+		}
+
+		var err error
+		profile, err = service.ProfileRepository.FindProfile(ctx, tx, userID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to find profile - Avatar", map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find profile: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, service.txFailure(ctx, err, "Avatar")
+	}
+	if !profile.HasAvatar() {
+		return nil, nil, response.NotFoundError("Avatar not found")
+	}
+
+	if custErr := service.recordRead(ctx, userID, "avatar", "Avatar"); custErr != nil {
+		return nil, nil, custErr
+	}
+
+	content, err := service.Blobs.Get(ctx, profile.AvatarKey)
+	if err == storage.ErrNotFound {
+		service.Logger.WithContext(ctx).Error("[ProfileService] Avatar missing from blob store - Avatar", map[string]interface{}{
+			"user_id": userID,
+			"key":     profile.AvatarKey,
+		})
+		return nil, nil, response.NotFoundError("Avatar not found")
+	}
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[ProfileService] Failed to read avatar - Avatar", map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return nil, nil, response.GeneralError("Failed to read avatar: " + err.Error()).WithCause(err)
+	}
+
+	return content, toAvatarResponse(profile), nil
+}
+
+func (service *ProfileServiceImpl) DeleteAvatar(ctx context.Context, userID uint64) *response.CustomError {
+	_, previousKey, custErr := service.setAvatar(ctx, userID, "", "", 0, "DeleteAvatar")
+	if custErr != nil {
+		return custErr
+	}
+	service.deleteBlob(ctx, userID, previousKey, "DeleteAvatar")
+	return nil
+}
+
+// setAvatar points the profile at a new avatar, or at none when key is
+// empty, and returns the key it replaced.
+func (service *ProfileServiceImpl) setAvatar(ctx context.Context, userID uint64, key string, contentType string, size int64, method string) (*models.UserProfile, string, *response.CustomError) {
+	var profile *models.UserProfile
+	var previousKey string
+	// The blob of the previous avatar is deleted by the caller, so the
+	// commit has to succeed first.
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		if custErr := service.checkUser(ctx, tx, userID, method); custErr != nil {
+			return custErr
+		}
+
+		var err error
+		profile, err = service.ProfileRepository.FindProfileForUpdate(ctx, tx, userID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to find profile - "+method, map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find profile: " + err.Error()).WithCause(err)
+		}
+		if key == "" && !profile.HasAvatar() {
+			return response.NotFoundError("Avatar not found")
+		}
+
+		previousKey = profile.AvatarKey
+		before := avatarAuditState(profile)
+		profile.AvatarKey = key
+		profile.AvatarContentType = contentType
+		profile.AvatarSize = size
+		profile.UpdatedAt = time.Now()
+
+		err = service.ProfileRepository.UpdateProfile(ctx, tx, profile)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to update profile - "+method, map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to update profile: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditAvatarUpdate,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &userID,
+			Before:     before,
+			After:      avatarAuditState(profile),
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[ProfileService] Failed to record audit event - "+method, map[string]interface{}{
+				"user_id": userID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", service.txFailure(ctx, err, method)
+	}
+
+	return profile, previousKey, nil
+}
+
+func (service *ProfileServiceImpl) txFailure(ctx context.Context, err error, method string) *response.CustomError {
+	var custErr *response.CustomError
+	if errors.As(err, &custErr) {
+		return custErr
+	}
+	service.Logger.WithContext(ctx).Error("[ProfileService] Transaction failed - "+method, map[string]interface{}{
+		"error": err.Error(),
+	})
+	return response.GeneralError("Transaction failed: " + err.Error()).WithCause(err)
+}
+
+// checkUser fails with 404 when the user does not exist or was deleted.
+func (service *ProfileServiceImpl) checkUser(ctx context.Context, tx *sql.Tx, userID uint64, method string) *response.CustomError {
+	_, err := service.UserRepository.FindUserStatus(ctx, tx, userID)
+	if err == sql.ErrNoRows {
+		return response.NotFoundError("User not found")
+	}
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[ProfileService] Failed to find user - "+method, map[string]interface{}{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return response.GeneralError("Failed to find user: " + err.Error()).WithCause(err)
+	}
+	return nil
+}
+
+// recordRead audits a read of someone else's profile before it is
+// returned. Users reading their own profile are not audited.
+func (service *ProfileServiceImpl) recordRead(ctx context.Context, userID uint64, resource string, method string) *response.CustomError {
+	if actor, ok := audit.ActorFromContext(ctx); ok && actor.ID == userID {
+		return nil
+	}
+	err := service.AuditRecorder.RecordNow(ctx, audit.Entry{
+		Action:     models.AuditProfileRead,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &userID,
+		After:      map[string]interface{}{"resource": resource},
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[ProfileService] Failed to record audit event - "+method, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+	}
+	return nil
+}
+
+// deleteBlob removes an avatar that is no longer referenced. A failure
+// only leaves an orphaned file behind, so it is logged and not returned.
+func (service *ProfileServiceImpl) deleteBlob(ctx context.Context, userID uint64, key string, method string) {
+	if key == "" {
+		return
+	}
+	if err := service.Blobs.Delete(ctx, key); err != nil {
+		service.Logger.WithContext(ctx).Warn("[ProfileService] Failed to delete avatar blob - "+method, map[string]interface{}{
+			"user_id": userID,
+			"key":     key,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// decodePreferences reads stored preferences. Keys the service does not
+// know are dropped on the next update.
+func decodePreferences(raw []byte) params.Preferences {
+	var preferences params.Preferences
+	json.Unmarshal(raw, &preferences)
+	return preferences
+}
+
+func profileAuditState(profile *models.UserProfile) map[string]interface{} {
+	preferences := decodePreferences(profile.Preferences)
+	return map[string]interface{}{
+		"phone":                profile.Phone,
+		"address_line1":        profile.AddressLine1,
+		"address_line2":        profile.AddressLine2,
+		"city":                 profile.City,
+		"region":               profile.Region,
+		"postal_code":          profile.PostalCode,
+		"country":              profile.Country,
+		"notification_channel": preferences.NotificationChannel,
+		"language":             preferences.Language,
+	}
+}
+
+func avatarAuditState(profile *models.UserProfile) map[string]interface{} {
+	if !profile.HasAvatar() {
+		return map[string]interface{}{"avatar": nil}
+	}
+	return map[string]interface{}{
+		"avatar": map[string]interface{}{
+			"content_type": profile.AvatarContentType,
+			"size":         profile.AvatarSize,
+		},
+	}
+}
+
+func toProfileResponse(profile *models.UserProfile) *params.ProfileResponse {
+	resp := &params.ProfileResponse{
+		UserID: profile.UserID,
+		Phone:  profile.Phone,
+		Address: params.Address{
+			Line1:      profile.AddressLine1,
+			Line2:      profile.AddressLine2,
+			City:       profile.City,
+			Region:     profile.Region,
+			PostalCode: profile.PostalCode,
+			Country:    profile.Country,
+		},
+		Preferences: decodePreferences(profile.Preferences),
+	}
+	if profile.HasAvatar() {
+		resp.Avatar = toAvatarResponse(profile)
+	}
+	if !profile.UpdatedAt.IsZero() {
+		resp.UpdatedAt = &profile.UpdatedAt
+	}
+	return resp
+}
+
+func toAvatarResponse(profile *models.UserProfile) *params.AvatarResponse {
+	return &params.AvatarResponse{
+		ContentType: profile.AvatarContentType,
+		Size:        profile.AvatarSize,
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/json"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Values that must never leave the service, set on the models the views
+// are mapped from.
+const (
+	passwordHash  = "$2a$10$password-hash-must-not-leak"
+	webhookSecret = "whsec_secret-must-not-leak"
+)
+
+// TestViewsDoNotExposeSecrets marshals every view built from a user or a
+// webhook subscription, the way they are sent to clients, and fails if a
+// credential ends up in the output.
+func TestViewsDoNotExposeSecrets(t *testing.T) {
+	now := time.Now()
+	user := &models.User{
+		ID:        1,
+		Email:     "reader@example.com",
+		Password:  passwordHash,
+		Name:      "Reader",
+		Role:      models.DefaultRole,
+		Status:    models.UserStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	subscription := &models.WebhookSubscription{
+		ID:         1,
+		URL:        "https://example.com/hooks",
+		Secret:     webhookSecret,
+		EventTypes: []string{"user.registered"},
+		Active:     true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	profile := &models.UserProfile{
+		UserID:      user.ID,
+		Phone:       "+6281234567890",
+		Preferences: []byte(`{}`),
+		UpdatedAt:   now,
+	}
+
+	views := []struct {
+		name string
+		view interface{}
+	}{
+		{"administrator view", toUserResponse(user)},
+		{"own account", toUserSelfResponse(user)},
+		{"export", params.UserExport{
+			ExportedAt: now,
+			Profile:    toExportProfile(user),
+			Contact:    toProfileResponse(profile),
+			Loans:      []*params.LoanResponse{},
+			Activities: []*params.ActivityResponse{},
+		}},
+		{"audit state", userAuditState(user)},
+		{"webhook subscription", toWebhookResponse(subscription)},
+		{"webhook audit state", webhookAuditState(subscription)},
+	}
+
+	for _, test := range views {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := json.Marshal(test.view)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			for _, secret := range []string{passwordHash, webhookSecret} {
+				if strings.Contains(string(body), secret) {
+					t.Errorf("output exposes %q: %s", secret, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library-api-user/internal/audit"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"library-api-user/internal/repositories"
+	"library-api-user/pkg/database"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type RoleService interface {
+	Create(ctx context.Context, req *params.RoleRequest) (*params.RoleResponse, *response.CustomError)
+	GetAll(ctx context.Context) ([]*params.RoleResponse, *response.CustomError)
+	Permissions(ctx context.Context) ([]*params.PermissionResponse, *response.CustomError)
+	Grant(ctx context.Context, roleName string, req *params.RolePermissionRequest) *response.CustomError
+	Revoke(ctx context.Context, roleName string, permissionName string) *response.CustomError
+}
+
+type RoleServiceImpl struct {
+	RoleRepository repositories.RoleRepository
+	DB             *sql.DB
+	Tx             *database.TxManager
+	AuditRecorder  *audit.Recorder
+	Logger         logger.Logger
+}
+
+func NewRoleService(db *sql.DB, roleRepository repositories.RoleRepository, auditRecorder *audit.Recorder, log logger.Logger) RoleService {
+	return &RoleServiceImpl{
+		RoleRepository: roleRepository,
+		DB:             db,
+		Tx:             database.NewTxManager(db),
+		AuditRecorder:  auditRecorder,
+		Logger:         log,
+	}
+}
+
+func (service *RoleServiceImpl) Create(ctx context.Context, req *params.RoleRequest) (*params.RoleResponse, *response.CustomError) {
+	val := validator.New()
+	if err := val.Struct(req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
+		}
+		service.Logger.WithContext(ctx).Error("[RoleService] Validation failed - Create", map[string]interface{}{
+			"error": errors,
+		})
+		return nil, response.BadRequestErrorWithAdditionalInfo(errors)
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: []string{},
+		CreatedAt:   time.Now(),
+	}
+
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		if existing, _ := service.RoleRepository.FindRoleByName(ctx, tx, req.Name); existing != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed role already exists! - Create", map[string]interface{}{
+				"role": req.Name,
+			})
+			return response.ConflictError("Role already exists!")
+		}
+
+		err := service.RoleRepository.CreateRole(ctx, tx, &role)
+		if err == repositories.ErrRoleExists {
+			return response.ConflictError("Role already exists!")
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed to create role - Create", map[string]interface{}{
+				"role":  req.Name,
+				"error": err.Error(),
+			})
+			return response.GeneralError("Failed to create role: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditRoleCreate,
+			TargetType: models.AuditTargetRole,
+			TargetID:   &role.ID,
+			After: map[string]interface{}{
+				"name":        role.Name,
+				"description": role.Description,
+			},
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed to record audit event - Create", map[string]interface{}{
+				"role":  req.Name,
+				"error": err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Create")
+	}
+
+	return toRoleResponse(&role), nil
+}
+
+func (service *RoleServiceImpl) GetAll(ctx context.Context) ([]*params.RoleResponse, *response.CustomError) {
+	var roles []*models.Role
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) (err error) {
+		roles, err = service.RoleRepository.FindRoles(ctx, tx)
+		return err
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[RoleService] Failed to fetch roles - GetAll", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, response.GeneralError("Failed to fetch roles: " + err.Error()).WithCause(err)
+	}
+
+	roleResponses := make([]*params.RoleResponse, len(roles))
+	for i, role := range roles {
+		roleResponses[i] = toRoleResponse(role)
+	}
+	return roleResponses, nil
+}
+
+func (service *RoleServiceImpl) Permissions(ctx context.Context) ([]*params.PermissionResponse, *response.CustomError) {
+	var permissions []*models.Permission
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) (err error) {
+		permissions, err = service.RoleRepository.FindPermissions(ctx, tx)
+		return err
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[RoleService] Failed to fetch permissions - Permissions", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, response.GeneralError("Failed to fetch permissions: " + err.Error()).WithCause(err)
+	}
+
+	permissionResponses := make([]*params.PermissionResponse, len(permissions))
+	for i, permission := range permissions {
+		permissionResponses[i] = &params.PermissionResponse{
+			ID:          permission.ID,
+			Name:        permission.Name,
+			Description: permission.Description,
+		}
+	}
+	return permissionResponses, nil
+}
+
+// Grant adds a permission to a role. It applies to the next request of
+// every holder of the role, including tokens issued before the grant.
+func (service *RoleServiceImpl) Grant(ctx context.Context, roleName string, req *params.RolePermissionRequest) *response.CustomError {
+	val := validator.New()
+	if err := val.Struct(req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
+		}
+		service.Logger.WithContext(ctx).Error("[RoleService] Validation failed - Grant", map[string]interface{}{
+			"error": errors,
+		})
+		return response.BadRequestErrorWithAdditionalInfo(errors)
+	}
+
+	return service.changeGrant(ctx, roleName, req.Permission, "Grant", models.AuditRoleGrant, service.RoleRepository.GrantPermission)
+}
+
+func (service *RoleServiceImpl) Revoke(ctx context.Context, roleName string, permissionName string) *response.CustomError {
+	return service.changeGrant(ctx, roleName, permissionName, "Revoke", models.AuditRoleRevoke, service.RoleRepository.RevokePermission)
+}
+
+func (service *RoleServiceImpl) changeGrant(ctx context.Context, roleName string, permissionName string, method string, action string, apply func(ctx context.Context, tx *sql.Tx, roleID uint64, permissionID uint64) error) *response.CustomError {
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		role, err := service.RoleRepository.FindRoleByName(ctx, tx, roleName)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed to find role by name - "+method, map[string]interface{}{
+				"role":  roleName,
+				"error": err.Error(),
+			})
+			return response.NotFoundError("Role not found")
+		}
+
+		permission, err := service.RoleRepository.FindPermissionByName(ctx, tx, permissionName)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed to find permission by name - "+method, map[string]interface{}{
+				"permission": permissionName,
+				"error":      err.Error(),
+			})
+			return response.NotFoundError("Permission not found")
+		}
+
+		err = apply(ctx, tx, role.ID, permission.ID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed to change role permission - "+method, map[string]interface{}{
+				"role":       roleName,
+				"permission": permissionName,
+				"error":      err.Error(),
+			})
+			return response.GeneralError("Failed to change role permission: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     action,
+			TargetType: models.AuditTargetRole,
+			TargetID:   &role.ID,
+			After: map[string]interface{}{
+				"role":       role.Name,
+				"permission": permission.Name,
+			},
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[RoleService] Failed to record audit event - "+method, map[string]interface{}{
+				"role":       roleName,
+				"permission": permissionName,
+				"error":      err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return service.txFailure(ctx, err, method)
+	}
+
+	return nil
+}
+
+func (service *RoleServiceImpl) txFailure(ctx context.Context, err error, method string) *response.CustomError {
+	var custErr *response.CustomError
+	if errors.As(err, &custErr) {
+		return custErr
+	}
+	service.Logger.WithContext(ctx).Error("[RoleService] Transaction failed - "+method, map[string]interface{}{
+		"error": err.Error(),
+	})
+	return response.GeneralError("Transaction failed: " + err.Error()).WithCause(err)
+}
+
+func toRoleResponse(role *models.Role) *params.RoleResponse {
+	return &params.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		CreatedAt:   role.CreatedAt,
+	}
+}
@@ -3,91 +3,139 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"library-api-user/internal/audit"
 	"library-api-user/internal/commons/response"
+	"library-api-user/internal/events"
 	"library-api-user/internal/grpc/client"
 	"library-api-user/internal/logger"
 	"library-api-user/internal/models"
 	"library-api-user/internal/params"
 	"library-api-user/internal/repositories"
+	"library-api-user/internal/storage"
+	"library-api-user/internal/webhooks"
+	"library-api-user/pkg/database"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 )
 
 type UserService interface {
-	Detail(ctx context.Context, id uint64) (*params.UserResponse, *response.CustomError)
-	Update(ctx context.Context, req *params.UserRequest, id uint64) *response.CustomError
-	GetAll(ctx context.Context, pagination *models.Pagination) ([]*params.UserResponse, *response.CustomError)
+	Detail(ctx context.Context, id uint64, current bool) (*params.UserResponse, *response.CustomError)
+	Me(ctx context.Context, id uint64) (*params.UserSelfResponse, *response.CustomError)
+	Update(ctx context.Context, id uint64, req *params.UserPatchRequest, expectedUpdatedAt *time.Time) (*params.UserResponse, *response.CustomError)
+	GetAll(ctx context.Context, query *params.UserListQuery, pagination *models.Pagination) ([]*params.UserResponse, *response.CustomError)
 	BorrowBook(ctx context.Context, userID uint64, bookID uint64) *response.CustomError
 	ReturnBook(ctx context.Context, userID uint64, bookID uint64) *response.CustomError
+	Loans(ctx context.Context, userID uint64, pagination *models.Pagination) ([]*params.LoanResponse, *response.CustomError)
+	Activities(ctx context.Context, userID uint64, pagination *models.Pagination) ([]*params.ActivityResponse, *response.CustomError)
+	Create(ctx context.Context, req *params.CreateUserRequest, actorID uint64) (*params.UserResponse, *response.CustomError)
+	Suspend(ctx context.Context, id uint64, actorID uint64, reason string) *response.CustomError
+	Reactivate(ctx context.Context, id uint64, actorID uint64, reason string) *response.CustomError
+	Delete(ctx context.Context, id uint64, actorID uint64, reason string, erase bool) *response.CustomError
+	Export(ctx context.Context, id uint64) (*params.UserExport, *response.CustomError)
+	Access(ctx context.Context, id uint64) (*models.Access, error)
 }
 
 type UserServiceImpl struct {
-	UserRepository     repositories.UserRepository
-	BorrowRepository   repositories.BorrowRepository
-	ActivityRepository repositories.UserActivityRepository
-	DB                 *sql.DB
-	BookClient         *client.BookClient
-	Logger             logger.Logger
+	UserRepository        repositories.UserRepository
+	RoleRepository        repositories.RoleRepository
+	AdminActionRepository repositories.UserAdminActionRepository
+	BorrowRepository      repositories.BorrowRepository
+	ActivityRepository    repositories.UserActivityRepository
+	OutboxRepository      repositories.OutboxRepository
+	BookRepository        repositories.BookProjectionRepository
+	ProfileRepository     repositories.ProfileRepository
+	Blobs                 storage.BlobStore
+	Webhooks              *webhooks.Enqueuer
+	AuditRecorder         *audit.Recorder
+	DB                    *sql.DB
+	Tx                    *database.TxManager
+	Reads                 *database.ReadPool
+	BookClient            *client.BookClient
+	Logger                logger.Logger
 }
 
-func NewUserService(db *sql.DB, bookClient *client.BookClient, userRepository repositories.UserRepository, borrowRepository repositories.BorrowRepository, activityRepository repositories.UserActivityRepository, log logger.Logger) UserService {
+func NewUserService(db *sql.DB, reads *database.ReadPool, bookClient *client.BookClient, userRepository repositories.UserRepository, roleRepository repositories.RoleRepository, adminActionRepository repositories.UserAdminActionRepository, borrowRepository repositories.BorrowRepository, activityRepository repositories.UserActivityRepository, outboxRepository repositories.OutboxRepository, bookRepository repositories.BookProjectionRepository, profileRepository repositories.ProfileRepository, blobs storage.BlobStore, webhookEnqueuer *webhooks.Enqueuer, auditRecorder *audit.Recorder, log logger.Logger) UserService {
 	return &UserServiceImpl{
-		UserRepository:     userRepository,
-		BorrowRepository:   borrowRepository,
-		ActivityRepository: activityRepository,
-		DB:                 db,
-		BookClient:         bookClient,
-		Logger:             log,
+		UserRepository:        userRepository,
+		RoleRepository:        roleRepository,
+		AdminActionRepository: adminActionRepository,
+		BorrowRepository:      borrowRepository,
+		ActivityRepository:    activityRepository,
+		OutboxRepository:      outboxRepository,
+		BookRepository:        bookRepository,
+		ProfileRepository:     profileRepository,
+		Blobs:                 blobs,
+		Webhooks:              webhookEnqueuer,
+		AuditRecorder:         auditRecorder,
+		DB:                    db,
+		Tx:                    database.NewTxManager(db),
+		Reads:                 reads,
+		BookClient:            bookClient,
+		Logger:                log,
 	}
 }
 
-func (service *UserServiceImpl) Detail(ctx context.Context, id uint64) (*params.UserResponse, *response.CustomError) {
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to begin transaction - Detail", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return nil, response.GeneralError("Failed to begin transaction: " + err.Error())
+// Detail reads from the replica, unless current is set: updated_at is the
+// ETag of the next conditional update, so callers revalidating an ETag ask
+// for the current row from the primary rather than a lagging copy.
+func (service *UserServiceImpl) Detail(ctx context.Context, id uint64, current bool) (*params.UserResponse, *response.CustomError) {
+	read := service.Reads.Read
+	if current {
+		read = service.Reads.ReadPrimary
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to panic - Detail", map[string]interface{}{
-				"error": r,
-			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to error - Detail", map[string]interface{}{
-				"error": err.Error(),
-			})
-		} else {
-			tx.Commit()
-		}
-	}()
 
-	user, err := service.UserRepository.FindUserByID(ctx, tx, id)
+	var user *models.User
+	err := read(ctx, func(tx *sql.Tx) (err error) {
+		user, err = service.UserRepository.FindUserByID(ctx, tx, id)
+		return err
+	})
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to retrieve user by ID - Detail", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[UserService] Failed to retrieve user by ID - Detail", map[string]interface{}{
 			"user_id": id,
 			"error":   err.Error(),
 		})
 		return nil, response.NotFoundError("User not found")
 	}
 
-	return &params.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Password:  user.Password,
-		Name:      user.Name,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}, nil
+	if custErr := service.recordRead(ctx, models.AuditUserRead, &id, nil, "Detail"); custErr != nil {
+		return nil, custErr
+	}
+
+	return toUserResponse(user), nil
 }
 
-func (service *UserServiceImpl) Update(ctx context.Context, req *params.UserRequest, id uint64) *response.CustomError {
+// Me returns the account of the authenticated user. Reading your own
+// account is not an administrator read, so it is not audited.
+func (service *UserServiceImpl) Me(ctx context.Context, id uint64) (*params.UserSelfResponse, *response.CustomError) {
+	var result *params.UserSelfResponse
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		user, err := service.UserRepository.FindUserByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to retrieve user by ID - Me", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.NotFoundError("User not found")
+		}
+
+		result = toUserSelfResponse(user)
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Me")
+	}
+
+	return result, nil
+}
+
+// Update applies a partial update to a user. Only the non-nil fields of req
+// change. When expectedUpdatedAt is set, the update is rejected with 412 if
+// the user was modified after that time.
+func (service *UserServiceImpl) Update(ctx context.Context, id uint64, req *params.UserPatchRequest, expectedUpdatedAt *time.Time) (*params.UserResponse, *response.CustomError) {
 	val := validator.New()
 	err := val.Struct(req)
 	if err != nil {
@@ -96,253 +144,1046 @@ func (service *UserServiceImpl) Update(ctx context.Context, req *params.UserRequ
 		for _, fieldError := range validationErrors {
 			errors = append(errors, fmt.Sprintf("error %s on tag %s", fieldError.Field(), fieldError.Tag()))
 		}
-		service.Logger.Error("[UserService] Validation failed - Update", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[UserService] Validation failed - Update", map[string]interface{}{
 			"error": errors,
 		})
-		return response.BadRequestErrorWithAdditionalInfo(errors)
+		return nil, response.BadRequestErrorWithAdditionalInfo(errors)
 	}
 
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to begin transaction - Update", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return response.GeneralError("Failed to begin transaction: " + err.Error())
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to panic - Update", map[string]interface{}{
-				"error": r,
+	var result *params.UserResponse
+	err = service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		user, err := service.UserRepository.FindUserByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find user by ID - Update", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
 			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to error - Update", map[string]interface{}{
-				"error": err.Error(),
+			return response.NotFoundError("User not found")
+		}
+		before := userAuditState(user)
+
+		if req.Email != nil && *req.Email != user.Email {
+			existingUser, findErr := service.UserRepository.FindUserByEmail(ctx, tx, *req.Email)
+			if findErr == nil && existingUser.ID != id {
+				service.Logger.WithContext(ctx).Warn("[UserService] Email already used by another user - Update", map[string]interface{}{
+					"user_id": id,
+					"email":   *req.Email,
+				})
+				return response.ConflictError("Email already exists!")
+			}
+			user.Email = *req.Email
+		}
+		if req.Password != nil {
+			user.Password = *req.Password
+		}
+		if req.Name != nil {
+			user.Name = *req.Name
+		}
+		if req.Role != nil && *req.Role != user.Role {
+			if _, err := service.RoleRepository.FindRoleByName(ctx, tx, *req.Role); err != nil {
+				service.Logger.WithContext(ctx).Error("[UserService] Failed role not found - Update", map[string]interface{}{
+					"role": *req.Role,
+				})
+				return response.BadRequestError("Role not found")
+			}
+			user.Role = *req.Role
+		}
+		// updated_at doubles as the ETag, so keep it at the precision the
+		// database stores.
+		user.UpdatedAt = time.Now().Truncate(time.Microsecond)
+
+		err = service.UserRepository.UpdateUser(ctx, tx, user, expectedUpdatedAt)
+		if err == repositories.ErrUserModified {
+			service.Logger.WithContext(ctx).Warn("[UserService] User modified concurrently - Update", map[string]interface{}{
+				"user_id": id,
 			})
-		} else {
-			tx.Commit()
+			return response.PreconditionFailedError("User was modified, fetch it again and retry")
+		}
+		if err == repositories.ErrEmailTaken {
+			service.Logger.WithContext(ctx).Warn("[UserService] Email already used by another user - Update", map[string]interface{}{
+				"user_id": id,
+				"email":   user.Email,
+			})
+			return response.ConflictError("Email already exists!")
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to update user - Update", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to update user: " + err.Error()).WithCause(err)
 		}
-	}()
 
-	_, err = service.UserRepository.FindUserByID(ctx, tx, id)
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to find user by ID - Update", map[string]interface{}{
-			"user_id": id,
-			"error":   err.Error(),
+		event, err := events.NewOutboxEvent(events.UserUpdated, id, events.UserUpdatedPayload{
+			UserID: id,
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role,
 		})
-		return response.NotFoundError("User not found")
-	}
+		if err == nil {
+			err = service.OutboxRepository.CreateEvent(ctx, tx, event)
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record user updated event - Update", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record user updated event: " + err.Error()).WithCause(err)
+		}
 
-	user := models.User{
-		ID:        id,
-		Email:     req.Email,
-		Password:  req.Password,
-		Name:      req.Name,
-		Role:      req.Role,
-		UpdatedAt: time.Now(),
-	}
+		err = service.Webhooks.Enqueue(ctx, tx, webhooks.AccountUpdated, webhooks.AccountData{
+			UserID: id,
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role,
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to enqueue webhook - Update", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to enqueue webhook: " + err.Error()).WithCause(err)
+		}
 
-	err = service.UserRepository.UpdateUser(ctx, tx, &user)
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to update user - Update", map[string]interface{}{
-			"user_id": id,
-			"error":   err.Error(),
+		action := models.AuditUserUpdate
+		if before["role"] != user.Role {
+			action = models.AuditUserRoleChange
+		}
+		changedBefore, changedAfter := audit.Diff(before, userAuditState(user))
+		if req.Password != nil {
+			changedBefore["password"] = audit.Redacted
+			changedAfter["password"] = audit.Redacted
+		}
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     action,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &id,
+			Before:     changedBefore,
+			After:      changedAfter,
 		})
-		return response.GeneralError("Failed to update user: " + err.Error())
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record audit event - Update", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+
+		result = toUserResponse(user)
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Update")
 	}
 
-	return nil
+	return result, nil
 }
 
-func (service *UserServiceImpl) GetAll(ctx context.Context, pagination *models.Pagination) ([]*params.UserResponse, *response.CustomError) {
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to begin transaction - GetAll", map[string]interface{}{
-			"error": err.Error(),
+// GetAll lists users matching query. Unknown or repeated sort fields and an
+// empty creation date range are rejected with 400.
+func (service *UserServiceImpl) GetAll(ctx context.Context, query *params.UserListQuery, pagination *models.Pagination) ([]*params.UserResponse, *response.CustomError) {
+	val := validator.New()
+	if err := val.Struct(query); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
+		}
+		service.Logger.WithContext(ctx).Error("[UserService] Validation failed - GetAll", map[string]interface{}{
+			"error": errors,
 		})
-		return nil, response.GeneralError("Failed to begin transaction: " + err.Error())
+		return nil, response.BadRequestErrorWithAdditionalInfo(errors)
+	}
+	if query.CreatedFrom != nil && query.CreatedTo != nil && !query.CreatedFrom.Before(*query.CreatedTo) {
+		return nil, response.BadRequestError("created_from must be before created_to")
+	}
+	sort, err := parseUserSort(query.Sort)
+	if err != nil {
+		return nil, response.BadRequestError(err.Error())
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to panic - GetAll", map[string]interface{}{
-				"error": r,
-			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to error - GetAll", map[string]interface{}{
-				"error": err.Error(),
-			})
-		} else {
-			tx.Commit()
-		}
-	}()
 
-	pagination.Offset = (pagination.Page - 1) * pagination.PageSize
+	filter := models.UserFilter{
+		Search:      strings.TrimSpace(query.Q),
+		Role:        query.Role,
+		Status:      query.Status,
+		CreatedFrom: query.CreatedFrom,
+		CreatedTo:   query.CreatedTo,
+		Sort:        sort,
+	}
 
-	users, err := service.UserRepository.GetAllUsers(ctx, tx, pagination)
+	var users []*models.User
+	err = service.Reads.Read(ctx, func(tx *sql.Tx) (err error) {
+		users, err = service.UserRepository.GetAllUsers(ctx, tx, &filter, pagination)
+		return err
+	})
+	if err == repositories.ErrCursorMismatch {
+		return nil, response.BadRequestError(err.Error())
+	}
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to fetch users - GetAll", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[UserService] Failed to fetch users - GetAll", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return nil, response.GeneralError("Failed to fetch users: " + err.Error())
+		return nil, response.GeneralError("Failed to fetch users: " + err.Error()).WithCause(err)
+	}
+
+	userIDs := make([]uint64, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	if custErr := service.recordRead(ctx, models.AuditUserList, nil, map[string]interface{}{
+		"page":     pagination.Page,
+		"per_page": pagination.PageSize,
+		"query":    query,
+		"user_ids": userIDs,
+	}, "GetAll"); custErr != nil {
+		return nil, custErr
 	}
 
 	userResponses := make([]*params.UserResponse, len(users))
 	for i, user := range users {
-		userResponses[i] = &params.UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			Password:  user.Password,
-			Name:      user.Name,
-			Role:      user.Role,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		}
+		userResponses[i] = toUserResponse(user)
 	}
 
-	pagination.PageCount = (pagination.TotalCount + pagination.PageSize - 1) / pagination.PageSize
+	if pagination.CountTotal() {
+		pagination.PageCount = (pagination.TotalCount + pagination.PageSize - 1) / pagination.PageSize
+	}
 
 	return userResponses, nil
 }
 
+// BorrowBook takes a copy from the book service and records the loan. The
+// stock change is remote and cannot be part of the transaction, so it is
+// given back when the loan is not recorded, including when the commit
+// fails.
 func (service *UserServiceImpl) BorrowBook(ctx context.Context, userID uint64, bookID uint64) *response.CustomError {
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to begin transaction - BorrowBook", map[string]interface{}{
-			"error": err.Error(),
+	stockTaken := false
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{NoRetry: true}, func(tx *sql.Tx) error {
+		book, err := service.BookRepository.FindBookByID(ctx, tx, bookID)
+		if err != nil && err != sql.ErrNoRows {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find book projection - BorrowBook", map[string]interface{}{
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find book: " + err.Error()).WithCause(err)
+		}
+		// The projection can lag behind the book service, so only a book known to
+		// be gone or out of stock is rejected locally; otherwise the remote stock
+		// decrement stays authoritative.
+		if book != nil && (book.Deleted || book.Stock <= 0) {
+			service.Logger.WithContext(ctx).Warn("[UserService] Book is not available - BorrowBook", map[string]interface{}{
+				"book_id": bookID,
+			})
+			return response.BadRequestError("Book is not available")
+		}
+
+		_, err = service.BorrowRepository.FindBorrow(ctx, tx, userID, bookID)
+		if err == nil {
+			service.Logger.WithContext(ctx).Warn("[UserService] Book already borrowed by user - BorrowBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+			})
+			return response.ConflictError("Book is already borrowed by this user")
+		}
+		if err != sql.ErrNoRows {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find open loan - BorrowBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find open loan: " + err.Error()).WithCause(err)
+		}
+
+		err = service.BookClient.DecreaseStock(ctx, bookID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to decrease book stock - BorrowBook", map[string]interface{}{
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to decrease book stock: " + err.Error()).WithCause(err)
+		}
+		stockTaken = true
+
+		borrowRecord := models.BorrowRecord{
+			UserID:     userID,
+			BookID:     bookID,
+			BorrowedAt: time.Now(),
+		}
+		err = service.BorrowRepository.CreateBorrow(ctx, tx, &borrowRecord)
+		if err == repositories.ErrLoanOpen {
+			// A concurrent request opened the loan after the check above.
+			return response.ConflictError("Book is already borrowed by this user")
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to create borrow record - BorrowBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to create borrow record: " + err.Error()).WithCause(err)
+		}
+
+		userActivity := models.UserActivity{
+			UserID:            userID,
+			BookID:            bookID,
+			ActivityType:      "borrowed",
+			ActivityTimestamp: time.Now(),
+		}
+		err = service.ActivityRepository.CreateActivity(ctx, tx, &userActivity)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to create user activity - BorrowBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to create user activity: " + err.Error()).WithCause(err)
+		}
+
+		event, err := events.NewOutboxEvent(events.BookBorrowed, userID, events.BookBorrowedPayload{
+			UserID:     userID,
+			BookID:     bookID,
+			BorrowedAt: borrowRecord.BorrowedAt.UTC(),
 		})
-		return response.GeneralError("Failed to begin transaction: " + err.Error())
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to panic - BorrowBook", map[string]interface{}{
-				"error": r,
+		if err == nil {
+			err = service.OutboxRepository.CreateEvent(ctx, tx, event)
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record book borrowed event - BorrowBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
 			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to error - BorrowBook", map[string]interface{}{
-				"error": err.Error(),
+			return response.GeneralError("Failed to record book borrowed event: " + err.Error()).WithCause(err)
+		}
+
+		err = service.Webhooks.Enqueue(ctx, tx, webhooks.LoanCreated, webhooks.LoanData{
+			UserID:     userID,
+			BookID:     bookID,
+			BorrowedAt: &borrowRecord.BorrowedAt,
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to enqueue webhook - BorrowBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
 			})
-		} else {
-			tx.Commit()
+			return response.GeneralError("Failed to enqueue webhook: " + err.Error()).WithCause(err)
 		}
-	}()
 
-	err = service.BookClient.DecreaseStock(ctx, bookID)
+		return nil
+	})
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to decrease book stock - BorrowBook", map[string]interface{}{
-			"book_id": bookID,
-			"error":   err.Error(),
+		if stockTaken {
+			if restoreErr := service.BookClient.IncreaseStock(context.WithoutCancel(ctx), bookID); restoreErr != nil {
+				service.Logger.WithContext(ctx).Error("[UserService] Failed to restore book stock - BorrowBook", map[string]interface{}{
+					"book_id": bookID,
+					"error":   restoreErr.Error(),
+				})
+			}
+		}
+		return service.txFailure(ctx, err, "BorrowBook")
+	}
+
+	return nil
+}
+
+// ReturnBook closes the open loan and gives the copy back to the book
+// service. As in BorrowBook, the stock is taken again if the return is not
+// recorded.
+func (service *UserServiceImpl) ReturnBook(ctx context.Context, userID uint64, bookID uint64) *response.CustomError {
+	stockReturned := false
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{NoRetry: true}, func(tx *sql.Tx) error {
+		// The stock only goes back up for a book that is actually on loan.
+		borrowRecord, err := service.BorrowRepository.FindBorrow(ctx, tx, userID, bookID)
+		if err == sql.ErrNoRows {
+			return response.NotFoundError("Borrow record not found")
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find borrow record - ReturnBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to find borrow record: " + err.Error()).WithCause(err)
+		}
+
+		err = service.BookClient.IncreaseStock(ctx, bookID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to increase book stock - ReturnBook", map[string]interface{}{
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to increase book stock: " + err.Error()).WithCause(err)
+		}
+		stockReturned = true
+
+		returnedAt := time.Now()
+		borrowRecord.ReturnedAt = &returnedAt
+		err = service.BorrowRepository.UpdateBorrow(ctx, tx, borrowRecord)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to update borrow record - ReturnBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to update borrow record: " + err.Error()).WithCause(err)
+		}
+
+		userActivity := models.UserActivity{
+			UserID:            userID,
+			BookID:            bookID,
+			ActivityType:      "returned",
+			ActivityTimestamp: time.Now(),
+		}
+		err = service.ActivityRepository.CreateActivity(ctx, tx, &userActivity)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to create user activity - ReturnBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to create user activity: " + err.Error()).WithCause(err)
+		}
+
+		event, err := events.NewOutboxEvent(events.BookReturned, userID, events.BookReturnedPayload{
+			UserID:     userID,
+			BookID:     bookID,
+			ReturnedAt: returnedAt.UTC(),
 		})
-		return response.GeneralError("Failed to decrease book stock: " + err.Error())
+		if err == nil {
+			err = service.OutboxRepository.CreateEvent(ctx, tx, event)
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record book returned event - ReturnBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record book returned event: " + err.Error()).WithCause(err)
+		}
+
+		err = service.Webhooks.Enqueue(ctx, tx, webhooks.LoanReturned, webhooks.LoanData{
+			UserID:     userID,
+			BookID:     bookID,
+			BorrowedAt: &borrowRecord.BorrowedAt,
+			ReturnedAt: &returnedAt,
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to enqueue webhook - ReturnBook", map[string]interface{}{
+				"user_id": userID,
+				"book_id": bookID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to enqueue webhook: " + err.Error()).WithCause(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if stockReturned {
+			if restoreErr := service.BookClient.DecreaseStock(context.WithoutCancel(ctx), bookID); restoreErr != nil {
+				service.Logger.WithContext(ctx).Error("[UserService] Failed to take book stock back - ReturnBook", map[string]interface{}{
+					"book_id": bookID,
+					"error":   restoreErr.Error(),
+				})
+			}
+		}
+		return service.txFailure(ctx, err, "ReturnBook")
 	}
 
-	borrowRecord := models.BorrowRecord{
-		UserID:     userID,
-		BookID:     bookID,
-		BorrowedAt: time.Now(),
+	return nil
+}
+
+func (service *UserServiceImpl) Loans(ctx context.Context, userID uint64, pagination *models.Pagination) ([]*params.LoanResponse, *response.CustomError) {
+	var loans []*models.Loan
+	err := service.Reads.Read(ctx, func(tx *sql.Tx) (err error) {
+		loans, err = service.BorrowRepository.FindLoansByUser(ctx, tx, userID, pagination)
+		return err
+	})
+	if err == repositories.ErrCursorMismatch {
+		return nil, response.BadRequestError(err.Error())
 	}
-	err = service.BorrowRepository.CreateBorrow(ctx, tx, &borrowRecord)
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to create borrow record - BorrowBook", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[UserService] Failed to fetch loans - Loans", map[string]interface{}{
 			"user_id": userID,
-			"book_id": bookID,
 			"error":   err.Error(),
 		})
-		return response.GeneralError("Failed to create borrow record: " + err.Error())
+		return nil, response.GeneralError("Failed to fetch loans: " + err.Error()).WithCause(err)
+	}
+
+	loanResponses := make([]*params.LoanResponse, len(loans))
+	for i, loan := range loans {
+		loanResponses[i] = toLoanResponse(loan)
 	}
+	if pagination.CountTotal() {
+		pagination.PageCount = (pagination.TotalCount + pagination.PageSize - 1) / pagination.PageSize
+	}
+
+	return loanResponses, nil
+}
 
-	userActivity := models.UserActivity{
-		UserID:            userID,
-		BookID:            bookID,
-		ActivityType:      "borrowed",
-		ActivityTimestamp: time.Now(),
+// Activities lists the borrow and return activity of a user, newest first.
+func (service *UserServiceImpl) Activities(ctx context.Context, userID uint64, pagination *models.Pagination) ([]*params.ActivityResponse, *response.CustomError) {
+	var activities []*models.UserActivity
+	err := service.Reads.Read(ctx, func(tx *sql.Tx) (err error) {
+		activities, err = service.ActivityRepository.FindActivitiesByUser(ctx, tx, userID, pagination)
+		return err
+	})
+	if err == repositories.ErrCursorMismatch {
+		return nil, response.BadRequestError(err.Error())
 	}
-	err = service.ActivityRepository.CreateActivity(ctx, tx, &userActivity)
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to create user activity - BorrowBook", map[string]interface{}{
+		service.Logger.WithContext(ctx).Error("[UserService] Failed to fetch activities - Activities", map[string]interface{}{
 			"user_id": userID,
-			"book_id": bookID,
 			"error":   err.Error(),
 		})
-		return response.GeneralError("Failed to create user activity: " + err.Error())
+		return nil, response.GeneralError("Failed to fetch activities: " + err.Error()).WithCause(err)
 	}
 
-	return nil
+	activityResponses := make([]*params.ActivityResponse, len(activities))
+	for i, activity := range activities {
+		activityResponses[i] = toActivityResponse(activity)
+	}
+	if pagination.CountTotal() {
+		pagination.PageCount = (pagination.TotalCount + pagination.PageSize - 1) / pagination.PageSize
+	}
+
+	return activityResponses, nil
 }
 
-func (service *UserServiceImpl) ReturnBook(ctx context.Context, userID uint64, bookID uint64) *response.CustomError {
-	tx, err := service.DB.Begin()
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to begin transaction - ReturnBook", map[string]interface{}{
-			"error": err.Error(),
+// Create adds an account on behalf of an administrator. Unlike Register,
+// the caller may pick any existing role.
+func (service *UserServiceImpl) Create(ctx context.Context, req *params.CreateUserRequest, actorID uint64) (*params.UserResponse, *response.CustomError) {
+	val := validator.New()
+	if err := val.Struct(req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
+		}
+		service.Logger.WithContext(ctx).Error("[UserService] Validation failed - Create", map[string]interface{}{
+			"error": errors,
 		})
-		return response.GeneralError("Failed to begin transaction: " + err.Error())
+		return nil, response.BadRequestErrorWithAdditionalInfo(errors)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to panic - ReturnBook", map[string]interface{}{
-				"error": r,
+
+	var result *params.UserResponse
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		existingUser, err := service.UserRepository.FindUserByEmail(ctx, tx, req.Email)
+		if existingUser != nil || err == nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed email already exists! - Create", map[string]interface{}{
+				"email": req.Email,
 			})
-		} else if err != nil {
-			tx.Rollback()
-			service.Logger.Error("[UserService] Transaction rolled back due to error - ReturnBook", map[string]interface{}{
+			return response.ConflictError("Email already exists!")
+		}
+
+		if _, err := service.RoleRepository.FindRoleByName(ctx, tx, req.Role); err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed role not found - Create", map[string]interface{}{
+				"role": req.Role,
+			})
+			return response.BadRequestError("Role not found")
+		}
+
+		user := models.User{
+			Email:     req.Email,
+			Password:  req.Password,
+			Name:      req.Name,
+			Role:      req.Role,
+			Status:    models.UserStatusActive,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		err = service.UserRepository.CreateUser(ctx, tx, &user)
+		if err == repositories.ErrEmailTaken {
+			return response.ConflictError("Email already exists!")
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to create user - Create", map[string]interface{}{
+				"email": req.Email,
 				"error": err.Error(),
 			})
-		} else {
-			tx.Commit()
+			return response.GeneralError("Failed to create user: " + err.Error()).WithCause(err)
 		}
-	}()
 
-	err = service.BookClient.IncreaseStock(ctx, bookID)
-	if err != nil {
-		service.Logger.Error("[UserService] Failed to increase book stock - ReturnBook", map[string]interface{}{
-			"book_id": bookID,
-			"error":   err.Error(),
+		err = service.recordAction(ctx, tx, user.ID, models.UserActionCreated, actorID, "")
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record admin action - Create", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record admin action: " + err.Error()).WithCause(err)
+		}
+
+		event, err := events.NewOutboxEvent(events.UserRegistered, user.ID, events.UserRegisteredPayload{
+			UserID: user.ID,
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role,
+		})
+		if err == nil {
+			err = service.OutboxRepository.CreateEvent(ctx, tx, event)
+		}
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record user registered event - Create", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record user registered event: " + err.Error()).WithCause(err)
+		}
+
+		err = service.Webhooks.Enqueue(ctx, tx, webhooks.AccountCreated, webhooks.AccountData{
+			UserID: user.ID,
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role,
 		})
-		return response.GeneralError("Failed to increase book stock: " + err.Error())
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to enqueue webhook - Create", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to enqueue webhook: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditUserCreate,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &user.ID,
+			After:      userAuditState(&user),
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record audit event - Create", map[string]interface{}{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+
+		result = toUserResponse(&user)
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Create")
+	}
+
+	return result, nil
+}
+
+// Suspend blocks login and rejects the tokens already issued to the account
+// until it is reactivated.
+func (service *UserServiceImpl) Suspend(ctx context.Context, id uint64, actorID uint64, reason string) *response.CustomError {
+	return service.changeStatus(ctx, id, actorID, reason, models.UserStatusSuspended, models.UserActionSuspended, models.AuditUserSuspend, "Suspend")
+}
+
+func (service *UserServiceImpl) Reactivate(ctx context.Context, id uint64, actorID uint64, reason string) *response.CustomError {
+	return service.changeStatus(ctx, id, actorID, reason, models.UserStatusActive, models.UserActionReactivated, models.AuditUserReactivate, "Reactivate")
+}
+
+func (service *UserServiceImpl) changeStatus(ctx context.Context, id uint64, actorID uint64, reason string, status string, action string, auditAction string, method string) *response.CustomError {
+	if id == actorID {
+		return response.BadRequestError("You cannot change the status of your own account")
 	}
 
-	borrowRecord, err := service.BorrowRepository.FindBorrow(ctx, tx, userID, bookID)
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		user, err := service.UserRepository.FindUserByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find user by ID - "+method, map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.NotFoundError("User not found")
+		}
+
+		if user.Status == status {
+			return response.BadRequestError("User is already " + status)
+		}
+
+		err = service.UserRepository.UpdateStatus(ctx, tx, id, status, time.Now())
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to update user status - "+method, map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to update user status: " + err.Error()).WithCause(err)
+		}
+
+		err = service.recordAction(ctx, tx, id, action, actorID, reason)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record admin action - "+method, map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record admin action: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     auditAction,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &id,
+			Before:     map[string]interface{}{"status": user.Status},
+			After:      map[string]interface{}{"status": status, "reason": reason},
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record audit event - "+method, map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to find borrow record - ReturnBook", map[string]interface{}{
-			"user_id": userID,
-			"book_id": bookID,
-			"error":   err.Error(),
+		return service.txFailure(ctx, err, method)
+	}
+
+	return nil
+}
+
+// Delete soft deletes an account that has no open loans. Its personal data
+// is erased by the privacy eraser after the grace period, or right away
+// when erase is set. The deletion is kept in user_admin_actions together
+// with the administrator who made it.
+func (service *UserServiceImpl) Delete(ctx context.Context, id uint64, actorID uint64, reason string, erase bool) *response.CustomError {
+	if id == actorID {
+		return response.BadRequestError("You cannot delete your own account")
+	}
+
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		user, err := service.UserRepository.FindUserByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find user by ID - Delete", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.NotFoundError("User not found")
+		}
+
+		openLoans, err := service.BorrowRepository.CountOpenBorrows(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to count open loans - Delete", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to count open loans: " + err.Error()).WithCause(err)
+		}
+		if openLoans > 0 {
+			service.Logger.WithContext(ctx).Warn("[UserService] User still has open loans - Delete", map[string]interface{}{
+				"user_id":    id,
+				"open_loans": openLoans,
+			})
+			return response.BadRequestErrorWithAdditionalInfo(map[string]int{"open_loans": openLoans}, "User still has books to return")
+		}
+
+		now := time.Now()
+		err = service.UserRepository.DeleteUser(ctx, tx, id, now)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to delete user - Delete", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to delete user: " + err.Error()).WithCause(err)
+		}
+
+		err = service.recordAction(ctx, tx, id, models.UserActionDeleted, actorID, reason)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record admin action - Delete", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record admin action: " + err.Error()).WithCause(err)
+		}
+
+		if erase {
+			var avatarKey string
+			err = service.UserRepository.AnonymizeUser(ctx, tx, id, now)
+			if err == nil {
+				avatarKey, err = service.ProfileRepository.DeleteProfile(ctx, tx, id)
+			}
+			if err == nil {
+				err = service.recordAction(ctx, tx, id, models.UserActionErased, actorID, reason)
+			}
+			if err != nil {
+				service.Logger.WithContext(ctx).Error("[UserService] Failed to erase user - Delete", map[string]interface{}{
+					"user_id": id,
+					"error":   err.Error(),
+				})
+				return response.GeneralError("Failed to erase user: " + err.Error()).WithCause(err)
+			}
+			// The avatar goes before the commit. Should the commit fail, the
+			// profile keeps a key without a blob and the avatar reads as
+			// missing, which is the outcome the caller asked for.
+			if avatarKey != "" {
+				err = service.Blobs.Delete(ctx, avatarKey)
+				if err != nil {
+					service.Logger.WithContext(ctx).Error("[UserService] Failed to delete avatar - Delete", map[string]interface{}{
+						"user_id": id,
+						"error":   err.Error(),
+					})
+					return response.GeneralError("Failed to delete avatar: " + err.Error()).WithCause(err)
+				}
+			}
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditUserDelete,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &id,
+			Before:     map[string]interface{}{"status": user.Status},
+			After:      map[string]interface{}{"deleted": true, "erased": erase, "reason": reason},
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to record audit event - Delete", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+
+		service.Logger.WithContext(ctx).Info("[UserService] User deleted - Delete", map[string]interface{}{
+			"user_id":      id,
+			"performed_by": actorID,
+			"erased":       erase,
 		})
-		return response.GeneralError("Failed to find borrow record: " + err.Error())
+
+		return nil
+	})
+	if err != nil {
+		return service.txFailure(ctx, err, "Delete")
+	}
+
+	return nil
+}
+
+// Access returns the current role and permissions of the account, or nil
+// when it does not exist or is suspended. It is used by the authentication
+// middleware on every authenticated request, so grant and role changes
+// apply without waiting for the token to expire.
+func (service *UserServiceImpl) Access(ctx context.Context, id uint64) (*models.Access, error) {
+	var access *models.Access
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		var err error
+		access, err = service.UserRepository.FindActiveUserAccess(ctx, tx, id)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+func (service *UserServiceImpl) recordAction(ctx context.Context, tx *sql.Tx, userID uint64, action string, actorID uint64, reason string) error {
+	return service.AdminActionRepository.CreateAction(ctx, tx, &models.UserAdminAction{
+		UserID:      userID,
+		Action:      action,
+		PerformedBy: actorID,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// Export packages everything the service stores about a user: the account
+// without credentials, the contact details and preferences, every loan and
+// every recorded activity. The avatar itself is left out; it can be
+// downloaded on its own.
+func (service *UserServiceImpl) Export(ctx context.Context, id uint64) (*params.UserExport, *response.CustomError) {
+	var result *params.UserExport
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx *sql.Tx) error {
+		user, err := service.UserRepository.FindUserByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to find user by ID - Export", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.NotFoundError("User not found")
+		}
 
-	borrowRecord.ReturnedAt = time.Now()
-	err = service.BorrowRepository.UpdateBorrow(ctx, tx, borrowRecord)
+		loans, err := service.BorrowRepository.FindLoansByUser(ctx, tx, id, nil)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to fetch loans - Export", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to fetch loans: " + err.Error()).WithCause(err)
+		}
+
+		activities, err := service.ActivityRepository.FindActivitiesByUser(ctx, tx, id, nil)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to fetch activities - Export", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to fetch activities: " + err.Error()).WithCause(err)
+		}
+
+		profile, err := service.ProfileRepository.FindProfile(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[UserService] Failed to fetch profile - Export", map[string]interface{}{
+				"user_id": id,
+				"error":   err.Error(),
+			})
+			return response.GeneralError("Failed to fetch profile: " + err.Error()).WithCause(err)
+		}
+
+		if custErr := service.recordRead(ctx, models.AuditUserExport, &id, nil, "Export"); custErr != nil {
+			return custErr
+		}
+
+		export := params.UserExport{
+			ExportedAt: time.Now(),
+			Profile:    toExportProfile(user),
+			Contact:    toProfileResponse(profile),
+			Loans:      make([]*params.LoanResponse, len(loans)),
+			Activities: make([]*params.ActivityResponse, len(activities)),
+		}
+		for i, loan := range loans {
+			export.Loans[i] = toLoanResponse(loan)
+		}
+		for i, activity := range activities {
+			export.Activities[i] = toActivityResponse(activity)
+		}
+
+		result = &export
+		return nil
+	})
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to update borrow record - ReturnBook", map[string]interface{}{
-			"user_id": userID,
-			"book_id": bookID,
-			"error":   err.Error(),
-		})
-		return response.GeneralError("Failed to update borrow record: " + err.Error())
+		return nil, service.txFailure(ctx, err, "Export")
+	}
+
+	return result, nil
+}
+
+// parseUserSort reads a sort parameter such as "name,-created_at". Fields
+// must be listed in models.UserSortColumns and may appear once.
+func parseUserSort(sort string) ([]models.SortField, error) {
+	if strings.TrimSpace(sort) == "" {
+		return nil, nil
+	}
+
+	var fields []models.SortField
+	seen := map[string]bool{}
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		field := models.SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if _, ok := models.UserSortColumns[field.Field]; !ok {
+			return nil, fmt.Errorf("cannot sort by %q", field.Field)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("sort field %q is repeated", field.Field)
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
 	}
+	return fields, nil
+}
 
-	userActivity := models.UserActivity{
-		UserID:            userID,
-		BookID:            bookID,
-		ActivityType:      "returned",
-		ActivityTimestamp: time.Now(),
+// txFailure turns an error of WithinTx into a response. Errors of the
+// transaction function are responses already; the others come from
+// beginning or committing the transaction.
+func (service *UserServiceImpl) txFailure(ctx context.Context, err error, method string) *response.CustomError {
+	var custErr *response.CustomError
+	if errors.As(err, &custErr) {
+		return custErr
 	}
-	err = service.ActivityRepository.CreateActivity(ctx, tx, &userActivity)
+	service.Logger.WithContext(ctx).Error("[UserService] Transaction failed - "+method, map[string]interface{}{
+		"error": err.Error(),
+	})
+	return response.GeneralError("Transaction failed: " + err.Error()).WithCause(err)
+}
+
+// recordRead writes a read of personal data to the audit log before the
+// data is returned, so that nothing is disclosed without a record.
+func (service *UserServiceImpl) recordRead(ctx context.Context, action string, targetID *uint64, details map[string]interface{}, method string) *response.CustomError {
+	err := service.AuditRecorder.RecordNow(ctx, audit.Entry{
+		Action:     action,
+		TargetType: models.AuditTargetUser,
+		TargetID:   targetID,
+		After:      details,
+	})
 	if err != nil {
-		service.Logger.Error("[UserService] Failed to create user activity - ReturnBook", map[string]interface{}{
-			"user_id": userID,
-			"book_id": bookID,
-			"error":   err.Error(),
+		service.Logger.WithContext(ctx).Error("[UserService] Failed to record audit event - "+method, map[string]interface{}{
+			"error": err.Error(),
 		})
-		return response.GeneralError("Failed to create user activity: " + err.Error())
+		return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
 	}
-
 	return nil
 }
+
+// userAuditState is the part of a user that audit events track. The
+// password is left out; changes to it are recorded as redacted.
+func userAuditState(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"email":  user.Email,
+		"name":   user.Name,
+		"role":   user.Role,
+		"status": user.Status,
+	}
+}
+
+// toUserResponse maps a user to the administrator view. Every field is
+// copied explicitly, so new model fields stay out of responses until they
+// are added here.
+func toUserResponse(user *models.User) *params.UserResponse {
+	return &params.UserResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		Status:    user.Status,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+// toUserSelfResponse maps a user to the view of their own account.
+func toUserSelfResponse(user *models.User) *params.UserSelfResponse {
+	return &params.UserSelfResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func toExportProfile(user *models.User) params.ExportProfile {
+	return params.ExportProfile{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		Status:    user.Status,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func toActivityResponse(activity *models.UserActivity) *params.ActivityResponse {
+	return &params.ActivityResponse{
+		ID:           activity.ID,
+		BookID:       activity.BookID,
+		ActivityType: activity.ActivityType,
+		OccurredAt:   activity.ActivityTimestamp,
+	}
+}
+
+func toLoanResponse(loan *models.Loan) *params.LoanResponse {
+	loanResponse := &params.LoanResponse{
+		ID:         loan.ID,
+		BookID:     loan.BookID,
+		BorrowedAt: loan.BorrowedAt,
+		ReturnedAt: loan.ReturnedAt,
+	}
+	if loan.BookKnown {
+		loanResponse.Book = &params.BookSnapshot{
+			Title:     loan.BookTitle,
+			Author:    loan.BookAuthor,
+			Available: !loan.BookDeleted && loan.BookStock > 0,
+			Deleted:   loan.BookDeleted,
+		}
+	}
+	return loanResponse
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library-api-user/internal/audit"
+	"library-api-user/internal/commons/response"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/params"
+	"library-api-user/internal/repositories"
+	"library-api-user/internal/webhooks"
+	"library-api-user/pkg/database"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type WebhookService interface {
+	Create(ctx context.Context, req *params.WebhookRequest) (*params.WebhookResponse, *response.CustomError)
+	Detail(ctx context.Context, id uint64) (*params.WebhookResponse, *response.CustomError)
+	GetAll(ctx context.Context) ([]*params.WebhookResponse, *response.CustomError)
+	Update(ctx context.Context, req *params.WebhookRequest, id uint64) *response.CustomError
+	Delete(ctx context.Context, id uint64) *response.CustomError
+	Deliveries(ctx context.Context, subscriptionID uint64, status string, pagination *models.Pagination) ([]*params.WebhookDeliveryResponse, *response.CustomError)
+	Redeliver(ctx context.Context, deliveryID uint64) *response.CustomError
+}
+
+type WebhookServiceImpl struct {
+	WebhookRepository repositories.WebhookRepository
+	DB                *sql.DB
+	Tx                *database.TxManager
+	AuditRecorder     *audit.Recorder
+	Logger            logger.Logger
+}
+
+func NewWebhookService(db *sql.DB, webhookRepository repositories.WebhookRepository, auditRecorder *audit.Recorder, log logger.Logger) WebhookService {
+	return &WebhookServiceImpl{
+		WebhookRepository: webhookRepository,
+		DB:                db,
+		Tx:                database.NewTxManager(db),
+		AuditRecorder:     auditRecorder,
+		Logger:            log,
+	}
+}
+
+func (service *WebhookServiceImpl) Create(ctx context.Context, req *params.WebhookRequest) (*params.WebhookResponse, *response.CustomError) {
+	if custErr := service.validate(ctx, req, "Create"); custErr != nil {
+		return nil, custErr
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[WebhookService] Failed to generate secret - Create", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, response.GeneralError("Failed to generate secret: " + err.Error()).WithCause(err)
+	}
+
+	subscription := models.WebhookSubscription{
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		Active:     req.Active == nil || *req.Active,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	err = service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		err := service.WebhookRepository.CreateSubscription(ctx, tx, &subscription)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to create subscription - Create", map[string]interface{}{
+				"url":   req.URL,
+				"error": err.Error(),
+			})
+			return response.GeneralError("Failed to create webhook: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditWebhookCreate,
+			TargetType: models.AuditTargetWebhook,
+			TargetID:   &subscription.ID,
+			After:      webhookAuditState(&subscription),
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to record audit event - Create", map[string]interface{}{
+				"url":   req.URL,
+				"error": err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Create")
+	}
+
+	// The secret is only returned once, when the subscription is created.
+	resp := toWebhookResponse(&subscription)
+	resp.Secret = subscription.Secret
+	return resp, nil
+}
+
+func (service *WebhookServiceImpl) Detail(ctx context.Context, id uint64) (*params.WebhookResponse, *response.CustomError) {
+	var subscription *models.WebhookSubscription
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		var err error
+		subscription, err = service.WebhookRepository.FindSubscriptionByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to retrieve subscription by ID - Detail", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.NotFoundError("Webhook not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, service.txFailure(ctx, err, "Detail")
+	}
+
+	return toWebhookResponse(subscription), nil
+}
+
+func (service *WebhookServiceImpl) GetAll(ctx context.Context) ([]*params.WebhookResponse, *response.CustomError) {
+	var subscriptions []*models.WebhookSubscription
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) (err error) {
+		subscriptions, err = service.WebhookRepository.FindSubscriptions(ctx, tx)
+		return err
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[WebhookService] Failed to fetch subscriptions - GetAll", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, response.GeneralError("Failed to fetch webhooks: " + err.Error()).WithCause(err)
+	}
+
+	webhookResponses := make([]*params.WebhookResponse, len(subscriptions))
+	for i, subscription := range subscriptions {
+		webhookResponses[i] = toWebhookResponse(subscription)
+	}
+	return webhookResponses, nil
+}
+
+func (service *WebhookServiceImpl) Update(ctx context.Context, req *params.WebhookRequest, id uint64) *response.CustomError {
+	if custErr := service.validate(ctx, req, "Update"); custErr != nil {
+		return custErr
+	}
+
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		subscription, err := service.WebhookRepository.FindSubscriptionByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to find subscription by ID - Update", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.NotFoundError("Webhook not found")
+		}
+
+		before := webhookAuditState(subscription)
+		subscription.URL = req.URL
+		subscription.EventTypes = req.EventTypes
+		if req.Active != nil {
+			subscription.Active = *req.Active
+		}
+		subscription.UpdatedAt = time.Now()
+
+		err = service.WebhookRepository.UpdateSubscription(ctx, tx, subscription)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to update subscription - Update", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.GeneralError("Failed to update webhook: " + err.Error()).WithCause(err)
+		}
+
+		changedBefore, changedAfter := audit.Diff(before, webhookAuditState(subscription))
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditWebhookUpdate,
+			TargetType: models.AuditTargetWebhook,
+			TargetID:   &id,
+			Before:     changedBefore,
+			After:      changedAfter,
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to record audit event - Update", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return service.txFailure(ctx, err, "Update")
+	}
+
+	return nil
+}
+
+func (service *WebhookServiceImpl) Delete(ctx context.Context, id uint64) *response.CustomError {
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		subscription, err := service.WebhookRepository.FindSubscriptionByID(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to find subscription by ID - Delete", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.NotFoundError("Webhook not found")
+		}
+
+		err = service.WebhookRepository.DeleteSubscription(ctx, tx, id)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to delete subscription - Delete", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.GeneralError("Failed to delete webhook: " + err.Error()).WithCause(err)
+		}
+
+		err = service.AuditRecorder.Record(ctx, tx, audit.Entry{
+			Action:     models.AuditWebhookDelete,
+			TargetType: models.AuditTargetWebhook,
+			TargetID:   &id,
+			Before:     webhookAuditState(subscription),
+		})
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to record audit event - Delete", map[string]interface{}{
+				"webhook_id": id,
+				"error":      err.Error(),
+			})
+			return response.GeneralError("Failed to record audit event: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return service.txFailure(ctx, err, "Delete")
+	}
+
+	return nil
+}
+
+func (service *WebhookServiceImpl) Deliveries(ctx context.Context, subscriptionID uint64, status string, pagination *models.Pagination) ([]*params.WebhookDeliveryResponse, *response.CustomError) {
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		return nil, response.BadRequestError("Unknown delivery status: " + status)
+	}
+
+	pagination.Offset = (pagination.Page - 1) * pagination.PageSize
+
+	var deliveries []*models.WebhookDelivery
+	err := service.Tx.WithinTx(ctx, &database.TxOptions{ReadOnly: true}, func(tx *sql.Tx) (err error) {
+		deliveries, err = service.WebhookRepository.FindDeliveries(ctx, tx, subscriptionID, status, pagination)
+		return err
+	})
+	if err != nil {
+		service.Logger.WithContext(ctx).Error("[WebhookService] Failed to fetch deliveries - Deliveries", map[string]interface{}{
+			"webhook_id": subscriptionID,
+			"error":      err.Error(),
+		})
+		return nil, response.GeneralError("Failed to fetch deliveries: " + err.Error()).WithCause(err)
+	}
+
+	deliveryResponses := make([]*params.WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		deliveryResponses[i] = &params.WebhookDeliveryResponse{
+			ID:             delivery.ID,
+			SubscriptionID: delivery.SubscriptionID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        delivery.Payload,
+			Status:         delivery.Status,
+			Attempts:       delivery.Attempts,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			CreatedAt:      delivery.CreatedAt,
+			DeliveredAt:    delivery.DeliveredAt,
+		}
+	}
+
+	pagination.PageCount = (pagination.TotalCount + pagination.PageSize - 1) / pagination.PageSize
+
+	return deliveryResponses, nil
+}
+
+// Redeliver puts a delivery back in the queue with a fresh attempt budget,
+// whether it was delivered already or sits in the dead-letter list.
+func (service *WebhookServiceImpl) Redeliver(ctx context.Context, deliveryID uint64) *response.CustomError {
+	err := service.Tx.WithinTx(ctx, nil, func(tx *sql.Tx) error {
+		delivery, err := service.WebhookRepository.FindDeliveryByID(ctx, tx, deliveryID)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to find delivery by ID - Redeliver", map[string]interface{}{
+				"delivery_id": deliveryID,
+				"error":       err.Error(),
+			})
+			return response.NotFoundError("Webhook delivery not found")
+		}
+
+		delivery.Status = models.WebhookDeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+		delivery.DeliveredAt = nil
+
+		err = service.WebhookRepository.UpdateDelivery(ctx, tx, delivery)
+		if err != nil {
+			service.Logger.WithContext(ctx).Error("[WebhookService] Failed to requeue delivery - Redeliver", map[string]interface{}{
+				"delivery_id": deliveryID,
+				"error":       err.Error(),
+			})
+			return response.GeneralError("Failed to requeue delivery: " + err.Error()).WithCause(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return service.txFailure(ctx, err, "Redeliver")
+	}
+
+	return nil
+}
+
+func (service *WebhookServiceImpl) txFailure(ctx context.Context, err error, method string) *response.CustomError {
+	var custErr *response.CustomError
+	if errors.As(err, &custErr) {
+		return custErr
+	}
+	service.Logger.WithContext(ctx).Error("[WebhookService] Transaction failed - "+method, map[string]interface{}{
+		"error": err.Error(),
+	})
+	return response.GeneralError("Transaction failed: " + err.Error()).WithCause(err)
+}
+
+func (service *WebhookServiceImpl) validate(ctx context.Context, req *params.WebhookRequest, method string) *response.CustomError {
+	val := validator.New()
+	if err := val.Struct(req); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fmt.Sprintf("Field '%s' failed validation with tag '%s'", fieldError.Field(), fieldError.Tag())
+		}
+		service.Logger.WithContext(ctx).Error("[WebhookService] Validation failed - "+method, map[string]interface{}{
+			"error": errors,
+		})
+		return response.BadRequestErrorWithAdditionalInfo(errors)
+	}
+	return nil
+}
+
+// webhookAuditState is the part of a subscription recorded in the audit
+// log. The signing secret is left out.
+func webhookAuditState(subscription *models.WebhookSubscription) map[string]interface{} {
+	return map[string]interface{}{
+		"url":         subscription.URL,
+		"event_types": subscription.EventTypes,
+		"active":      subscription.Active,
+	}
+}
+
+func toWebhookResponse(subscription *models.WebhookSubscription) *params.WebhookResponse {
+	return &params.WebhookResponse{
+		ID:         subscription.ID,
+		URL:        subscription.URL,
+		EventTypes: subscription.EventTypes,
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt,
+		UpdatedAt:  subscription.UpdatedAt,
+	}
+}
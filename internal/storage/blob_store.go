@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+var (
+	ErrNotFound   = errors.New("blob is not found")
+	ErrInvalidKey = errors.New("blob key is invalid")
+)
+
+// BlobStore keeps binary objects, such as avatars, under keys made of
+// slash separated segments. Put replaces an existing object as a whole;
+// readers never see a partially written one.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9_-]+(/[a-z0-9_-]+)*$`)
+
+// validKey rejects keys that could leave the store, such as ones with ..
+// segments or a leading slash.
+func validKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+	return nil
+}
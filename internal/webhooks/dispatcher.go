@@ -0,0 +1,205 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"library-api-user/internal/repositories"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	dispatchInterval  = 5 * time.Second
+	dispatchBatchSize = 20
+	requestTimeout    = 10 * time.Second
+
+	// claimLease is how long claimed deliveries are kept from other
+	// dispatchers: enough to send a whole batch one by one.
+	claimLease = dispatchBatchSize*requestTimeout + time.Minute
+
+	// MaxAttempts is the number of tries before a delivery is moved to the
+	// dead-letter list. With the backoff below the last try happens roughly
+	// four hours after the first one.
+	MaxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 2 * time.Hour
+)
+
+type Dispatcher struct {
+	DB                *sql.DB
+	WebhookRepository repositories.WebhookRepository
+	HTTPClient        *http.Client
+	Logger            logger.Logger
+}
+
+func NewDispatcher(db *sql.DB, webhookRepository repositories.WebhookRepository, log logger.Logger) *Dispatcher {
+	return &Dispatcher{
+		DB:                db,
+		WebhookRepository: webhookRepository,
+		HTTPClient:        &http.Client{Timeout: requestTimeout},
+		Logger:            log,
+	}
+}
+
+func (dispatcher *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := dispatcher.DispatchDue(ctx); err != nil {
+				dispatcher.Logger.Error("[WebhookDispatcher] Failed to dispatch deliveries", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// DispatchDue claims the deliveries whose retry time has come and posts
+// them one by one. No transaction is held while posting: each result is
+// saved in a short transaction of its own.
+func (dispatcher *Dispatcher) DispatchDue(ctx context.Context) error {
+	claimed, err := dispatcher.claimDue(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, claim := range claimed {
+		dispatcher.attempt(ctx, claim.subscription, claim.delivery)
+
+		if err := dispatcher.saveDelivery(ctx, claim.delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type claimedDelivery struct {
+	subscription *models.WebhookSubscription
+	delivery     *models.WebhookDelivery
+}
+
+// claimDue leases a batch of due deliveries to this dispatcher and loads
+// their subscriptions.
+func (dispatcher *Dispatcher) claimDue(ctx context.Context) ([]claimedDelivery, error) {
+	tx, err := dispatcher.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	deliveries, err := dispatcher.WebhookRepository.FindDueDeliveries(ctx, tx, now, dispatchBatchSize)
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint64, len(deliveries))
+	claimed := make([]claimedDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		subscription, err := dispatcher.WebhookRepository.FindSubscriptionByID(ctx, tx, delivery.SubscriptionID)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = delivery.ID
+		claimed[i] = claimedDelivery{subscription: subscription, delivery: delivery}
+	}
+
+	if err := dispatcher.WebhookRepository.ClaimDeliveries(ctx, tx, ids, now.Add(claimLease)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (dispatcher *Dispatcher) saveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	tx, err := dispatcher.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := dispatcher.WebhookRepository.UpdateDelivery(ctx, tx, delivery); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// attempt posts the delivery once and updates its state in place.
+func (dispatcher *Dispatcher) attempt(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	delivery.Attempts++
+
+	statusCode, err := dispatcher.send(ctx, subscription, delivery, now)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= MaxAttempts {
+		delivery.Status = models.WebhookDeliveryDead
+		dispatcher.Logger.Warn("[WebhookDispatcher] Delivery moved to dead-letter list", map[string]interface{}{
+			"delivery_id":     delivery.ID,
+			"subscription_id": subscription.ID,
+			"error":           err.Error(),
+		})
+		return
+	}
+	delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+}
+
+func (dispatcher *Dispatcher) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "library-api-user-webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := dispatcher.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the wait before the next try after the given number of
+// failed attempts: 30s, 1m, 2m, 4m, ... capped at two hours.
+func Backoff(attempts int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
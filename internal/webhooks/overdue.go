@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/repositories"
+	"time"
+)
+
+const (
+	overdueInterval  = time.Minute
+	overdueBatchSize = 100
+	// DefaultLoanPeriod is used when LOAN_PERIOD_DAYS is not configured.
+	DefaultLoanPeriod = 14 * 24 * time.Hour
+)
+
+// OverdueScanner emits loan.overdue once for every open loan older than the
+// loan period.
+type OverdueScanner struct {
+	DB               *sql.DB
+	BorrowRepository repositories.BorrowRepository
+	Enqueuer         *Enqueuer
+	LoanPeriod       time.Duration
+	Logger           logger.Logger
+}
+
+func NewOverdueScanner(db *sql.DB, borrowRepository repositories.BorrowRepository, enqueuer *Enqueuer, loanPeriod time.Duration, log logger.Logger) *OverdueScanner {
+	if loanPeriod <= 0 {
+		loanPeriod = DefaultLoanPeriod
+	}
+	return &OverdueScanner{
+		DB:               db,
+		BorrowRepository: borrowRepository,
+		Enqueuer:         enqueuer,
+		LoanPeriod:       loanPeriod,
+		Logger:           log,
+	}
+}
+
+func (scanner *OverdueScanner) Run(ctx context.Context) {
+	ticker := time.NewTicker(overdueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := scanner.ScanOverdue(ctx); err != nil {
+				scanner.Logger.Error("[OverdueScanner] Failed to scan overdue loans", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+func (scanner *OverdueScanner) ScanOverdue(ctx context.Context) error {
+	tx, err := scanner.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	borrows, err := scanner.BorrowRepository.FindOverdueBorrows(ctx, tx, now.Add(-scanner.LoanPeriod), overdueBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, borrow := range borrows {
+		borrowedAt := borrow.BorrowedAt.UTC()
+		dueAt := borrowedAt.Add(scanner.LoanPeriod)
+		err := scanner.Enqueuer.Enqueue(ctx, tx, LoanOverdue, LoanData{
+			UserID:     borrow.UserID,
+			BookID:     borrow.BookID,
+			BorrowedAt: &borrowedAt,
+			DueAt:      &dueAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := scanner.BorrowRepository.MarkOverdueNotified(ctx, tx, borrow.ID, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
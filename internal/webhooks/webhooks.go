@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"library-api-user/internal/events"
+	"library-api-user/internal/models"
+	"library-api-user/internal/repositories"
+	"time"
+)
+
+const (
+	LoanCreated    = "loan.created"
+	LoanReturned   = "loan.returned"
+	LoanOverdue    = "loan.overdue"
+	AccountCreated = "account.created"
+	AccountUpdated = "account.updated"
+
+	SignatureHeader = "X-Library-Signature"
+	TimestampHeader = "X-Library-Timestamp"
+	EventHeader     = "X-Library-Event"
+	DeliveryHeader  = "X-Library-Delivery"
+)
+
+// EventTypes lists the events partners can subscribe to.
+var EventTypes = []string{LoanCreated, LoanReturned, LoanOverdue, AccountCreated, AccountUpdated}
+
+// Payload is the body posted to subscribers.
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type LoanData struct {
+	UserID     uint64     `json:"user_id"`
+	BookID     uint64     `json:"book_id"`
+	BorrowedAt *time.Time `json:"borrowed_at,omitempty"`
+	ReturnedAt *time.Time `json:"returned_at,omitempty"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+}
+
+type AccountData struct {
+	UserID uint64 `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// subscription secret. Receivers recompute it to authenticate the call and
+// reject stale timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Enqueuer records a pending delivery for every active subscription of an
+// event. It runs inside the caller's transaction, so deliveries only exist
+// for changes that were committed.
+type Enqueuer struct {
+	WebhookRepository repositories.WebhookRepository
+}
+
+func NewEnqueuer(webhookRepository repositories.WebhookRepository) *Enqueuer {
+	return &Enqueuer{
+		WebhookRepository: webhookRepository,
+	}
+}
+
+func (enqueuer *Enqueuer) Enqueue(ctx context.Context, tx *sql.Tx, eventType string, data interface{}) error {
+	subscriptions, err := enqueuer.WebhookRepository.FindActiveSubscriptionsByEvent(ctx, tx, eventType)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	id, err := events.NewID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	body, err := json.Marshal(Payload{
+		ID:        id,
+		Type:      eventType,
+		CreatedAt: now.UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*models.WebhookDelivery, len(subscriptions))
+	for i, subscription := range subscriptions {
+		deliveries[i] = &models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        id,
+			EventType:      eventType,
+			Payload:        body,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+	}
+	return enqueuer.WebhookRepository.CreateDeliveries(ctx, tx, deliveries)
+}
@@ -0,0 +1,146 @@
+package webhooks
+
+import (
+	"context"
+	"library-api-user/internal/logger"
+	"library-api-user/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		want      string
+	}{
+		{
+			name:      "known signature",
+			secret:    "whsec_test",
+			timestamp: 1700000000,
+			body:      body,
+			want:      "sha256=11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5",
+		},
+		{
+			name:      "other secret",
+			secret:    "other",
+			timestamp: 1700000000,
+			body:      body,
+			want:      "sha256=0c9dcd041b074d1b31727e0c1f821d11366e9db9f94c18bf202eb66cd0bd4d40",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Sign(test.secret, test.timestamp, test.body); got != test.want {
+				t.Errorf("Sign = %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	// The timestamp is signed, so a captured call cannot be replayed later.
+	if Sign("whsec_test", 1700000000, body) == Sign("whsec_test", 1700000001, body) {
+		t.Errorf("signature does not depend on the timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{8, 64 * time.Minute},
+		{9, maxBackoff},
+		{20, maxBackoff},
+	}
+
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.attempts), func(t *testing.T) {
+			if got := Backoff(test.attempts); got != test.want {
+				t.Errorf("Backoff(%d) = %v, want %v", test.attempts, got, test.want)
+			}
+		})
+	}
+}
+
+// TestAttempt posts a delivery to a subscriber answering with each status
+// and checks the state the delivery is left in.
+func TestAttempt(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		attempts     int
+		wantStatus   string
+		wantAttempts int
+		wantBackoff  time.Duration
+	}{
+		{"delivered", http.StatusNoContent, 0, models.WebhookDeliveryDelivered, 1, 0},
+		{"first failure", http.StatusInternalServerError, 0, models.WebhookDeliveryPending, 1, 30 * time.Second},
+		{"third failure", http.StatusBadGateway, 2, models.WebhookDeliveryPending, 3, 2 * time.Minute},
+		{"last failure", http.StatusNotFound, MaxAttempts - 1, models.WebhookDeliveryDead, MaxAttempts, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				signature = Sign("whsec_test", mustParseInt(t, r.Header.Get(TimestampHeader)), []byte(`{"id":"1"}`))
+				if r.Header.Get(SignatureHeader) != signature {
+					t.Errorf("signature = %s, want %s", r.Header.Get(SignatureHeader), signature)
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			dispatcher := &Dispatcher{HTTPClient: server.Client(), Logger: nopLogger{}}
+			subscription := &models.WebhookSubscription{ID: 1, URL: server.URL, Secret: "whsec_test"}
+			delivery := &models.WebhookDelivery{ID: 1, Payload: []byte(`{"id":"1"}`), Status: models.WebhookDeliveryPending, Attempts: test.attempts}
+
+			before := time.Now()
+			dispatcher.attempt(context.Background(), subscription, delivery)
+
+			if delivery.Status != test.wantStatus || delivery.Attempts != test.wantAttempts || delivery.LastStatusCode != test.status {
+				t.Errorf("delivery = %s after %d attempts with %d, want %s after %d with %d",
+					delivery.Status, delivery.Attempts, delivery.LastStatusCode, test.wantStatus, test.wantAttempts, test.status)
+			}
+			if signature == "" {
+				t.Fatalf("subscriber was not called")
+			}
+			if test.wantBackoff > 0 {
+				if wait := delivery.NextAttemptAt.Sub(before); wait < test.wantBackoff || wait > test.wantBackoff+time.Second {
+					t.Errorf("next attempt in %v, want %v", wait, test.wantBackoff)
+				}
+			}
+			if test.wantStatus == models.WebhookDeliveryDelivered && (delivery.DeliveredAt == nil || delivery.LastError != "") {
+				t.Errorf("delivered at %v with error %q, want a time and no error", delivery.DeliveredAt, delivery.LastError)
+			}
+		})
+	}
+}
+
+func mustParseInt(t *testing.T, value string) int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		t.Errorf("invalid timestamp %q", value)
+	}
+	return n
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, map[string]interface{})         {}
+func (nopLogger) Error(string, map[string]interface{})        {}
+func (nopLogger) Warn(string, map[string]interface{})         {}
+func (nopLogger) Debug(string, map[string]interface{})        {}
+func (l nopLogger) WithContext(context.Context) logger.Logger { return l }
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CopyFrom bulk loads rows into table with COPY, within tx. Each row holds
+// one value per column. It returns the number of rows copied.
+func CopyFrom(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]any) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	copier := &copier{table: table, columns: columns, rows: rows}
+	if _, err := tx.ExecContext(ctx, "", copier); err != nil {
+		return 0, err
+	}
+	return copier.copied, nil
+}
+
+// copier runs COPY on the connection of the transaction. database/sql does
+// not expose that connection, but pgx hands it to a QueryRewriter passed
+// as the first argument, before running the rewritten, here empty, query.
+type copier struct {
+	table   string
+	columns []string
+	rows    [][]any
+	copied  int64
+}
+
+func (copier *copier) RewriteQuery(ctx context.Context, conn *pgx.Conn, sql string, args []any) (string, []any, error) {
+	copied, err := conn.CopyFrom(ctx, pgx.Identifier{copier.table}, copier.columns, pgx.CopyFromRows(copier.rows))
+	if err != nil {
+		return "", nil, err
+	}
+	copier.copied = copied
+	return "", nil, nil
+}
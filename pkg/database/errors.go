@@ -0,0 +1,42 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SQLSTATE codes the service reacts to.
+const (
+	UniqueViolation      = "23505"
+	SerializationFailure = "40001"
+	DeadlockDetected     = "40P01"
+)
+
+// Code returns the SQLSTATE of a Postgres error, or "" when err is not
+// one.
+func Code(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// IsUniqueViolation reports whether err violates the unique constraint
+// named constraint, or any unique constraint when constraint is "".
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != UniqueViolation {
+		return false
+	}
+	return constraint == "" || pgErr.ConstraintName == constraint
+}
+
+// Array scans a Postgres array into dest, a pointer to a slice such as
+// *[]string. Arrays are passed as arguments as plain slices.
+func Array(dest any) sql.Scanner {
+	return pgtype.NewMap().SQLScanner(dest)
+}
@@ -0,0 +1,14 @@
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    event_id VARCHAR(36) UNIQUE NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    schema_version INT NOT NULL DEFAULT 1,
+    aggregate_id INT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE book_projections (
+    book_id INT PRIMARY KEY NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    author VARCHAR(255) NOT NULL DEFAULT '',
+    stock INT NOT NULL DEFAULT 0,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    last_event_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE processed_events (
+    event_id VARCHAR(36) PRIMARY KEY NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
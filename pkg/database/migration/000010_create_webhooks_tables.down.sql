@@ -0,0 +1,3 @@
+ALTER TABLE borrows DROP COLUMN IF EXISTS overdue_notified_at;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,41 @@
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY NOT NULL,
+    name VARCHAR(20) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY NOT NULL,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_id INT NOT NULL,
+    permission_id INT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Library member'),
+    ('author', 'Book author'),
+    ('admin', 'Library staff with full access');
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'List and view users'),
+    ('users:write', 'Update users'),
+    ('loans:read', 'View own loans'),
+    ('loans:write', 'Borrow and return books'),
+    ('webhooks:manage', 'Manage webhook subscriptions and deliveries'),
+    ('roles:manage', 'Manage roles and permission grants');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin'
+   OR (r.name IN ('user', 'author') AND p.name IN ('loans:read', 'loans:write'));
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_admin_actions;
+
+ALTER TABLE users DROP COLUMN IF EXISTS status;
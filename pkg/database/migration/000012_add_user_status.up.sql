@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended'));
+
+CREATE TABLE user_admin_actions (
+    id SERIAL PRIMARY KEY NOT NULL,
+    user_id INT NOT NULL,
+    action VARCHAR(20) CHECK (action IN ('created', 'suspended', 'reactivated', 'deleted')) NOT NULL,
+    performed_by INT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_admin_actions_user_id ON user_admin_actions (user_id);
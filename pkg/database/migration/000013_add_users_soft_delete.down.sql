@@ -0,0 +1,12 @@
+DELETE FROM user_admin_actions WHERE action = 'erased';
+ALTER TABLE user_admin_actions DROP CONSTRAINT IF EXISTS user_admin_actions_action_check;
+ALTER TABLE user_admin_actions ADD CONSTRAINT user_admin_actions_action_check CHECK (action IN ('created', 'suspended', 'reactivated', 'deleted'));
+
+DROP INDEX IF EXISTS idx_users_erasable;
+DROP INDEX IF EXISTS users_email_active_key;
+
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE users DROP COLUMN IF EXISTS erased_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
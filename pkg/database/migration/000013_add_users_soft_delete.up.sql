@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN erased_at TIMESTAMP;
+
+-- A deleted account must not keep its email reserved.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+
+CREATE INDEX idx_users_erasable ON users (deleted_at) WHERE deleted_at IS NOT NULL AND erased_at IS NULL;
+
+ALTER TABLE user_admin_actions DROP CONSTRAINT IF EXISTS user_admin_actions_action_check;
+ALTER TABLE user_admin_actions ADD CONSTRAINT user_admin_actions_action_check CHECK (action IN ('created', 'suspended', 'reactivated', 'deleted', 'erased'));
@@ -0,0 +1,41 @@
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    actor_id INT,
+    actor_role VARCHAR(20) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(30) NOT NULL DEFAULT '',
+    target_id INT,
+    before JSON,
+    after JSON,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) UNIQUE NOT NULL
+);
+
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id);
+CREATE INDEX idx_audit_events_action ON audit_events (action);
+CREATE INDEX idx_audit_events_occurred_at ON audit_events (occurred_at);
+
+-- The table is append-only: rows can be inserted but never changed or
+-- removed, whoever is connected.
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+INSERT INTO permissions (name, description) VALUES
+    ('audit:read', 'Query and verify the audit log');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'audit:read';
@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Substring searches (ILIKE '%term%') on name and email.
+CREATE INDEX idx_users_name_trgm ON users USING gin (name gin_trgm_ops) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_email_trgm ON users USING gin (email gin_trgm_ops) WHERE deleted_at IS NULL;
+
+-- Filters and sort keys of the user listing.
+CREATE INDEX idx_users_created_at ON users (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_role ON users (role) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_status ON users (status) WHERE deleted_at IS NULL;
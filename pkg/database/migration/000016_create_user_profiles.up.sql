@@ -0,0 +1,17 @@
+CREATE TABLE user_profiles (
+    user_id INT PRIMARY KEY NOT NULL,
+    phone VARCHAR(16) NOT NULL DEFAULT '',
+    address_line1 VARCHAR(200) NOT NULL DEFAULT '',
+    address_line2 VARCHAR(200) NOT NULL DEFAULT '',
+    city VARCHAR(100) NOT NULL DEFAULT '',
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    country CHAR(2) NOT NULL DEFAULT '',
+    preferences JSONB NOT NULL DEFAULT '{}',
+    avatar_key VARCHAR(100) NOT NULL DEFAULT '',
+    avatar_content_type VARCHAR(50) NOT NULL DEFAULT '',
+    avatar_size INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CHECK (jsonb_typeof(preferences) = 'object')
+);
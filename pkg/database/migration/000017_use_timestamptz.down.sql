@@ -0,0 +1,45 @@
+ALTER TABLE users
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deleted_at TYPE TIMESTAMP USING deleted_at AT TIME ZONE 'UTC',
+    ALTER COLUMN erased_at TYPE TIMESTAMP USING erased_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_activities
+    ALTER COLUMN activity_timestamp TYPE TIMESTAMP USING activity_timestamp AT TIME ZONE 'UTC';
+
+ALTER TABLE borrows
+    ALTER COLUMN borrowed_at TYPE TIMESTAMP USING borrowed_at AT TIME ZONE 'UTC',
+    ALTER COLUMN returned_at TYPE TIMESTAMP USING returned_at AT TIME ZONE 'UTC',
+    ALTER COLUMN overdue_notified_at TYPE TIMESTAMP USING overdue_notified_at AT TIME ZONE 'UTC';
+
+ALTER TABLE outbox_events
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN published_at TYPE TIMESTAMP USING published_at AT TIME ZONE 'UTC';
+
+ALTER TABLE book_projections
+    ALTER COLUMN last_event_at TYPE TIMESTAMP USING last_event_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE processed_events
+    ALTER COLUMN processed_at TYPE TIMESTAMP USING processed_at AT TIME ZONE 'UTC';
+
+ALTER TABLE webhook_subscriptions
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMP USING next_attempt_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN delivered_at TYPE TIMESTAMP USING delivered_at AT TIME ZONE 'UTC';
+
+ALTER TABLE roles
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_admin_actions
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE audit_events
+    ALTER COLUMN occurred_at TYPE TIMESTAMP USING occurred_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_profiles
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
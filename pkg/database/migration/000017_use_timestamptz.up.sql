@@ -0,0 +1,48 @@
+-- Timestamps were stored as the wall clock of the service, which runs in
+-- UTC, so existing values are read as UTC. The tables of the book service
+-- (books, authors, categories) are left to that service.
+ALTER TABLE users
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN deleted_at TYPE TIMESTAMPTZ USING deleted_at AT TIME ZONE 'UTC',
+    ALTER COLUMN erased_at TYPE TIMESTAMPTZ USING erased_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_activities
+    ALTER COLUMN activity_timestamp TYPE TIMESTAMPTZ USING activity_timestamp AT TIME ZONE 'UTC';
+
+ALTER TABLE borrows
+    ALTER COLUMN borrowed_at TYPE TIMESTAMPTZ USING borrowed_at AT TIME ZONE 'UTC',
+    ALTER COLUMN returned_at TYPE TIMESTAMPTZ USING returned_at AT TIME ZONE 'UTC',
+    ALTER COLUMN overdue_notified_at TYPE TIMESTAMPTZ USING overdue_notified_at AT TIME ZONE 'UTC';
+
+ALTER TABLE outbox_events
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN published_at TYPE TIMESTAMPTZ USING published_at AT TIME ZONE 'UTC';
+
+ALTER TABLE book_projections
+    ALTER COLUMN last_event_at TYPE TIMESTAMPTZ USING last_event_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE processed_events
+    ALTER COLUMN processed_at TYPE TIMESTAMPTZ USING processed_at AT TIME ZONE 'UTC';
+
+ALTER TABLE webhook_subscriptions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ USING next_attempt_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN delivered_at TYPE TIMESTAMPTZ USING delivered_at AT TIME ZONE 'UTC';
+
+ALTER TABLE roles
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_admin_actions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE audit_events
+    ALTER COLUMN occurred_at TYPE TIMESTAMPTZ USING occurred_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_profiles
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
@@ -0,0 +1,12 @@
+-- Emails are unique regardless of case. Registering Alice@example.com next
+-- to alice@example.com used to succeed; such pairs have to be merged by
+-- hand before this migration can run.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE deleted_at IS NULL GROUP BY lower(email) HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'active users share an email that differs only in case, merge them before migrating';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS users_email_active_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (lower(email)) WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_user_activities_user_id;
+DROP INDEX IF EXISTS idx_user_activities_book_id;
+DROP INDEX IF EXISTS idx_borrows_book_id;
+DROP INDEX IF EXISTS idx_borrows_overdue;
+DROP INDEX IF EXISTS idx_borrows_user_borrowed_at;
+DROP INDEX IF EXISTS borrows_open_loan_key;
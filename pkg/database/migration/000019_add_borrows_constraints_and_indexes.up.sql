@@ -0,0 +1,24 @@
+-- A user holds at most one open loan of a book. Extra open loans have to be
+-- returned before this migration can run.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM borrows WHERE returned_at IS NULL GROUP BY user_id, book_id HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'users hold several open loans of the same book, return the extra ones before migrating';
+    END IF;
+END $$;
+
+-- It also serves FindBorrow, which looks up exactly that open loan.
+CREATE UNIQUE INDEX borrows_open_loan_key ON borrows (user_id, book_id) WHERE returned_at IS NULL;
+
+-- Loan history of a user, newest first, and the count of open loans.
+CREATE INDEX idx_borrows_user_borrowed_at ON borrows (user_id, borrowed_at DESC, id DESC);
+
+-- Open loans not yet reported overdue, scanned by the overdue scanner.
+CREATE INDEX idx_borrows_overdue ON borrows (borrowed_at) WHERE returned_at IS NULL AND overdue_notified_at IS NULL;
+
+-- Deleting a book cascades to its loans and activities.
+CREATE INDEX idx_borrows_book_id ON borrows (book_id);
+CREATE INDEX idx_user_activities_book_id ON user_activities (book_id);
+
+-- Activity history of a user, newest first.
+CREATE INDEX idx_user_activities_user_id ON user_activities (user_id, id DESC);
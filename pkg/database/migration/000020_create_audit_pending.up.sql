@@ -0,0 +1,16 @@
+-- Audited actions are written here in the transaction of the action, and
+-- moved onto the audit_events hash chain right after by the service, so
+-- that the lock serializing the chain is only held while appending.
+CREATE TABLE audit_pending (
+    id BIGSERIAL PRIMARY KEY NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    actor_id INT,
+    actor_role VARCHAR(20) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(30) NOT NULL DEFAULT '',
+    target_id INT,
+    before JSON,
+    after JSON,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT ''
+);
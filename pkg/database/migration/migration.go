@@ -0,0 +1,263 @@
+// Package migration applies the SQL migrations of this directory, which
+// are embedded into the binary. Versions are tracked in schema_migrations
+// the same way the migrate CLI does, so databases migrated with the CLI
+// carry on from where they are.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock held while migrating ("migr").
+const lockKey = 0x6d696772
+
+var (
+	ErrDirty     = errors.New("database is dirty, fix the failed migration by hand and force its version")
+	ErrNoVersion = errors.New("version is not a known migration")
+)
+
+var filePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is what schema_migrations records: the last applied version, 0
+// when none, and whether a migration failed halfway.
+type State struct {
+	Version uint64
+	Dirty   bool
+}
+
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a migrator for the embedded migrations.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Load reads NNN_name.up.sql and NNN_name.down.sql files, ordered by
+// version. Every version needs an up file; the down file is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files named %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns how many it applied.
+// Each migration runs in a transaction together with its version update,
+// so a failing one leaves the database as it was. Running Up from several
+// processes at once is safe: the others wait for the lock and find
+// nothing left to apply.
+func (migrator *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := migrator.locked(ctx, func(conn *sql.Conn) error {
+		state, err := readState(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if state.Dirty {
+			return ErrDirty
+		}
+
+		for _, migration := range migrator.Migrations {
+			if migration.Version <= state.Version {
+				continue
+			}
+			if err := apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations and returns how many it
+// reverted.
+func (migrator *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := migrator.locked(ctx, func(conn *sql.Conn) error {
+		state, err := readState(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if state.Dirty {
+			return ErrDirty
+		}
+
+		for i := len(migrator.Migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := migrator.Migrations[i]
+			if migration.Version > state.Version {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			var previous uint64
+			if i > 0 {
+				previous = migrator.Migrations[i-1].Version
+			}
+			if err := apply(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status returns the recorded state of the database.
+func (migrator *Migrator) Status(ctx context.Context) (*State, error) {
+	var state *State
+	err := migrator.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		state, err = readState(ctx, conn)
+		return err
+	})
+	return state, err
+}
+
+// Force records version as applied and clears the dirty flag without
+// running anything. It is the way out after a failed migration was
+// repaired by hand. Version 0 records that nothing is applied.
+func (migrator *Migrator) Force(ctx context.Context, version uint64) error {
+	if version != 0 && !migrator.known(version) {
+		return ErrNoVersion
+	}
+	return migrator.locked(ctx, func(conn *sql.Conn) error {
+		return apply(ctx, conn, "", version)
+	})
+}
+
+func (migrator *Migrator) known(version uint64) bool {
+	for _, migration := range migrator.Migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// locked runs fn on one connection while it holds the migration lock.
+// The lock is a session lock, so it is released if the process dies.
+func (migrator *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := migrator.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func readState(ctx context.Context, conn *sql.Conn) (*State, error) {
+	var state State
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&state.Version, &state.Dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// apply runs script and records version in the same transaction.
+func apply(ctx context.Context, conn *sql.Conn, script string, version uint64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if script != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if version != 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
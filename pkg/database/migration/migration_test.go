@@ -0,0 +1,115 @@
+package migration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(content string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(content)} }
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			files: fstest.MapFS{},
+			want:  []Migration{},
+		},
+		{
+			name: "ordered by version, not by name",
+			files: fstest.MapFS{
+				"000010_add_index.up.sql":    file("CREATE INDEX"),
+				"000010_add_index.down.sql":  file("DROP INDEX"),
+				"000002_add_table.up.sql":    file("CREATE TABLE"),
+				"000002_add_table.down.sql":  file("DROP TABLE"),
+				"1_first.up.sql":             file("SELECT 1"),
+				"000003_irreversible.up.sql": file("UPDATE"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "first", Up: "SELECT 1"},
+				{Version: 2, Name: "add_table", Up: "CREATE TABLE", Down: "DROP TABLE"},
+				{Version: 3, Name: "irreversible", Up: "UPDATE"},
+				{Version: 10, Name: "add_index", Up: "CREATE INDEX", Down: "DROP INDEX"},
+			},
+		},
+		{
+			name: "other files are ignored",
+			files: fstest.MapFS{
+				"000001_users.up.sql": file("CREATE TABLE users"),
+				"migration.go":        file("package migration"),
+				"README.md":           file("notes"),
+				"000002_users.sql":    file("not a migration"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "users", Up: "CREATE TABLE users"},
+			},
+		},
+		{
+			name: "down file without up file",
+			files: fstest.MapFS{
+				"000001_users.down.sql": file("DROP TABLE users"),
+			},
+			wantErr: "migration 1_users has no up file",
+		},
+		{
+			name: "one version with two names",
+			files: fstest.MapFS{
+				"000001_users.up.sql":   file("CREATE TABLE users"),
+				"000001_members.up.sql": file("CREATE TABLE members"),
+			},
+			wantErr: "migration 1 has files named",
+		},
+		{
+			name: "version zero",
+			files: fstest.MapFS{
+				"000000_users.up.sql": file("CREATE TABLE users"),
+			},
+			wantErr: "migration 000000_users.up.sql: invalid version",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations, err := Load(test.files)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Load error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !reflect.DeepEqual(migrations, test.want) {
+				t.Errorf("Load = %+v, want %+v", migrations, test.want)
+			}
+		})
+	}
+}
+
+// The embedded migrations are numbered without gaps and each one can be
+// reverted, so that migrate down works from any version.
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("no migrations embedded")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != uint64(i+1) {
+			t.Errorf("migration %d_%s follows version %d", migration.Version, migration.Name, i)
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
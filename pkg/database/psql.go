@@ -1,38 +1,128 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"database/sql/driver"
 	"library-api-user/internal/config"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
+// NewPqSQLClient connects to the primary database.
 func NewPqSQLClient() (*sql.DB, error) {
-	var (
-		DB_User   = config.ENV.DBUserName
-		DB_Pass   = config.ENV.DBUserPassword
-		DB_Host   = config.ENV.DBHost
-		DB_Port   = config.ENV.DBPort
-		DB_DbName = config.ENV.DBName
-	)
-	dsn := fmt.Sprintf(
-		"postgresql://%s:%s@%s:%s/%s?sslmode=disable",
-		DB_User, DB_Pass, DB_Host, DB_Port, DB_DbName,
-	)
-	db, err := sql.Open("postgres", dsn)
+	db, err := open(config.ENV.DBHost, config.ENV.DBPort)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetConnMaxLifetime(time.Minute * 60)
-	db.SetMaxOpenConns(20)
-	db.SetMaxIdleConns(10)
-
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 	return db, nil
 }
+
+// NewReplicaClient connects to the read replica, or returns nil when
+// DB_REPLICA_HOST is not set. The replica uses the primary's credentials
+// and, unless DB_REPLICA_PORT says otherwise, its port. A replica that is
+// down at startup is not an error; reads fall back to the primary until it
+// is back.
+func NewReplicaClient() (*sql.DB, error) {
+	if config.ENV.DBReplicaHost == "" {
+		return nil, nil
+	}
+	port := config.ENV.DBReplicaPort
+	if port == "" {
+		port = config.ENV.DBPort
+	}
+	db, err := open(config.ENV.DBReplicaHost, port)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Printf("[Database] Read replica %s is not reachable yet: %v\n", config.ENV.DBReplicaHost, err)
+	}
+	return db, nil
+}
+
+// open creates a pgx pool and exposes it as a *sql.DB, so repositories
+// keep working on database/sql while pgx caches prepared statements per
+// connection. The pool does the pooling; closing the *sql.DB closes it.
+func open(host, port string) (*sql.DB, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn(host, port))
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.MaxConns = int32(config.ENV.DBMaxOpenConns)
+	poolConfig.MinConns = int32(config.ENV.DBMaxIdleConns)
+	poolConfig.MaxConnLifetime = duration(config.ENV.DBConnLifetime)
+	if idleTime := duration(config.ENV.DBConnIdleTime); idleTime > 0 {
+		poolConfig.MaxConnIdleTime = idleTime
+	}
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(poolConnector{Connector: stdlib.GetPoolConnector(pool), pool: pool})
+	db.SetMaxIdleConns(0)
+	return db, nil
+}
+
+// poolConnector closes the pool together with the *sql.DB using it.
+type poolConnector struct {
+	driver.Connector
+	pool *pgxpool.Pool
+}
+
+func (connector poolConnector) Close() error {
+	connector.pool.Close()
+	return nil
+}
+
+// dsn builds the connection URL. Credentials and parameters are escaped,
+// so passwords may contain any character. statement_timeout is sent as a
+// run-time parameter and applies to every session of the pool.
+func dsn(host, port string) string {
+	query := url.Values{}
+	query.Set("sslmode", config.ENV.DBSSLMode)
+	if config.ENV.DBSSLRootCert != "" {
+		query.Set("sslrootcert", config.ENV.DBSSLRootCert)
+	}
+	if config.ENV.DBSSLCert != "" {
+		query.Set("sslcert", config.ENV.DBSSLCert)
+		query.Set("sslkey", config.ENV.DBSSLKey)
+	}
+	if config.ENV.DBAppName != "" {
+		query.Set("application_name", config.ENV.DBAppName)
+	}
+	if timeout := duration(config.ENV.DBStmtTimeout); timeout > 0 {
+		query.Set("statement_timeout", strconv.FormatInt(timeout.Milliseconds(), 10))
+	}
+
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(config.ENV.DBUserName, config.ENV.DBUserPassword),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + config.ENV.DBName,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// duration parses a validated configuration duration.
+func duration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
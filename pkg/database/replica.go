@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"library-api-user/internal/config"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// replicaRetry is how long reads stay on the primary after the replica
+// failed, so an unreachable replica does not slow down every read.
+const replicaRetry = 10 * time.Second
+
+// lagCheckInterval is how often the lag of the replica is measured.
+const lagCheckInterval = time.Second
+
+// replicaLagQuery returns how far behind the primary the replica is, in
+// seconds. A replica that replayed everything it received is not behind,
+// however long ago the last write was.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+// ReadPool runs read-only work on the replica when there is one and falls
+// back to the primary when the replica fails or lags more than MaxLag
+// behind. Reads from the replica may still miss the latest writes, so only
+// use it where that is acceptable, and ReadPrimary where it is not.
+type ReadPool struct {
+	Primary *sql.DB
+	Replica *sql.DB
+	// MaxLag is the replication lag above which reads go to the primary.
+	// Zero disables the check.
+	MaxLag time.Duration
+
+	downUntil    atomic.Int64
+	lagCheckedAt atomic.Int64
+	lagging      atomic.Bool
+}
+
+// NewReadPool returns a pool reading from replica, or from primary alone
+// when replica is nil, with the DB_REPLICA_MAX_LAG of the configuration.
+func NewReadPool(primary, replica *sql.DB) *ReadPool {
+	return &ReadPool{Primary: primary, Replica: replica, MaxLag: duration(config.ENV.DBReplicaMaxLag)}
+}
+
+// Read runs fn in a read-only transaction. If the replica cannot be
+// reached or drops the connection, fn runs again on the primary; errors
+// of fn itself, like sql.ErrNoRows, are returned as they are.
+func (pool *ReadPool) Read(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if pool.Replica != nil && time.Now().UnixNano() >= pool.downUntil.Load() && !pool.lagsBehind(ctx) {
+		err := read(ctx, pool.Replica, fn)
+		if err == nil || !replicaFailed(err) || ctx.Err() != nil {
+			return err
+		}
+		pool.downUntil.Store(time.Now().Add(replicaRetry).UnixNano())
+		log.Printf("[Database] Read replica failed, reading from the primary for %s: %v\n", replicaRetry, err)
+	}
+	return read(ctx, pool.Primary, fn)
+}
+
+// ReadPrimary runs fn in a read-only transaction on the primary, for reads
+// that must see every committed write.
+func (pool *ReadPool) ReadPrimary(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return read(ctx, pool.Primary, fn)
+}
+
+// lagsBehind reports whether the replica lagged more than MaxLag at the
+// last measurement, measuring again once lagCheckInterval has passed. A
+// failed measurement counts as no lag; the read then finds out whether
+// the replica is down.
+func (pool *ReadPool) lagsBehind(ctx context.Context) bool {
+	if pool.MaxLag <= 0 {
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	checkedAt := pool.lagCheckedAt.Load()
+	if now < checkedAt+int64(lagCheckInterval) || !pool.lagCheckedAt.CompareAndSwap(checkedAt, now) {
+		return pool.lagging.Load()
+	}
+
+	var lag float64
+	if err := pool.Replica.QueryRowContext(ctx, replicaLagQuery).Scan(&lag); err != nil {
+		pool.lagging.Store(false)
+		return false
+	}
+	lagging := lag > pool.MaxLag.Seconds()
+	if lagging != pool.lagging.Swap(lagging) {
+		if lagging {
+			log.Printf("[Database] Read replica is %.1fs behind, reading from the primary\n", lag)
+		} else {
+			log.Printf("[Database] Read replica caught up, reading from it again\n")
+		}
+	}
+	return lagging
+}
+
+func read(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(tx)
+}
+
+// replicaFailed reports whether err is a failure of the server or the
+// connection rather than of the query: network errors, connection
+// exceptions (class 08), running out of resources (53), shutdowns (57)
+// and queries cancelled by replication conflicts (40001).
+func replicaFailed(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || pgconn.SafeToRetry(err) {
+		return true
+	}
+	var netErr net.Error
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &netErr) || errors.As(err, &connectErr) {
+		return true
+	}
+	code := Code(err)
+	if len(code) == 5 {
+		switch code[:2] {
+		case "08", "53", "57":
+			return true
+		}
+	}
+	return code == SerializationFailure
+}
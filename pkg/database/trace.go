@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer records a client span for every statement and COPY run on a
+// connection of the pool, as a child of the span on the query's context.
+// Statements outside a trace, like the polling of background workers, are
+// not recorded. Statements are recorded with their placeholders, never
+// with arguments.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation := operationName(data.SQL)
+	return startSpan(ctx, conn, operation,
+		semconv.DBOperationName(operation),
+		semconv.DBQueryText(data.SQL),
+	)
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSpan(ctx, data.Err)
+}
+
+func (queryTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return startSpan(ctx, conn, "COPY",
+		semconv.DBOperationName("COPY"),
+		semconv.DBCollectionName(data.TableName.Sanitize()),
+	)
+}
+
+func (queryTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endSpan(ctx, data.Err)
+}
+
+func startSpan(ctx context.Context, conn *pgx.Conn, operation string, attributes ...attribute.KeyValue) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	connConfig := conn.Config()
+	ctx, _ = otel.Tracer("library-api-user/pkg/database").Start(ctx, operation+" "+connConfig.Database,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBNamespace(connConfig.Database), semconv.ServerAddress(connConfig.Host)),
+		trace.WithAttributes(attributes...),
+	)
+	return ctx
+}
+
+// endSpan ends the span started for the query; without one, ctx holds a
+// no-op span. No rows is an answer, not a failure of the query.
+func endSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// operationName returns the first keyword of a statement, such as SELECT
+// or WITH.
+func operationName(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultTxRetries is how many times WithinTx runs the work again after a
+// serialization failure or deadlock.
+const DefaultTxRetries = 3
+
+// txRetryDelay is the wait before the first retry; it doubles after each.
+const txRetryDelay = 20 * time.Millisecond
+
+// TxOptions configures a transaction of TxManager.WithinTx. The zero value
+// is a read-write transaction at the database's default isolation level.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// NoRetry runs the work once even if it hits a serialization failure,
+	// for work with effects outside the database.
+	NoRetry bool
+}
+
+// TxManager runs units of work in transactions.
+type TxManager struct {
+	DB      *sql.DB
+	Retries int
+}
+
+// NewTxManager returns a manager retrying DefaultTxRetries times.
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{DB: db, Retries: DefaultTxRetries}
+}
+
+// WithinTx runs fn in a transaction and commits it when fn returns nil. An
+// error from fn rolls the transaction back and is returned as it is; a
+// failed commit is returned too. A panic in fn rolls back and panics on.
+// When fn or the commit fails with a serialization failure or deadlock,
+// fn runs again in a new transaction, so it must not keep state from a
+// previous run. opts may be nil.
+func (manager *TxManager) WithinTx(ctx context.Context, opts *TxOptions, fn func(tx *sql.Tx) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	retries := manager.Retries
+	if opts.NoRetry {
+		retries = 0
+	}
+
+	delay := txRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := manager.run(ctx, opts, fn)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (manager *TxManager) run(ctx context.Context, opts *TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := manager.DB.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// retryable reports whether err means the transaction lost a conflict with
+// another one and would likely succeed if run again.
+func retryable(err error) bool {
+	code := Code(err)
+	return code == SerializationFailure || code == DeadlockDetected
+}
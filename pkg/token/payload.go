@@ -3,7 +3,8 @@ package token
 import "time"
 
 type Token struct {
-	AuthId  int
-	Role    string
-	Expired time.Time
+	AuthId      int
+	Role        string
+	Permissions []string
+	Expired     time.Time
 }
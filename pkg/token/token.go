@@ -10,21 +10,38 @@ import (
 )
 
 const (
-	TOKEN_Key    = "FSUnIQPYBvUeUbo6loiJp4HVk515eA7i"
 	TOKEN_Expiry = 24 * time.Hour
 )
 
-func GenerateToken(authId int, role string) (string, error) {
+// ErrNoKey is returned when a token is signed or validated before SetKey
+// is called with a key.
+var ErrNoKey = errors.New("token signing key is not configured")
+
+var signingKey []byte
+
+// SetKey replaces the signing key. An empty key keeps the current one.
+// Tokens signed with the previous key stop validating.
+func SetKey(key string) {
+	if key != "" {
+		signingKey = []byte(key)
+	}
+}
+
+func GenerateToken(authId int, role string, permissions []string) (string, error) {
+	if len(signingKey) == 0 {
+		return "", ErrNoKey
+	}
 	payload := Token{
-		AuthId:  authId,
-		Role:    role,
-		Expired: time.Now().Add(TOKEN_Expiry),
+		AuthId:      authId,
+		Role:        role,
+		Permissions: permissions,
+		Expired:     time.Now().Add(TOKEN_Expiry),
 	}
 	claims := jwt.MapClaims{
 		"payload": payload,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(TOKEN_Key))
+	tokenStr, err := token.SignedString(signingKey)
 	if err != nil {
 		return "", err
 	}
@@ -32,12 +49,15 @@ func GenerateToken(authId int, role string) (string, error) {
 }
 
 func ValidateToken(tokenString string) (*Token, error) {
+	if len(signingKey) == 0 {
+		return nil, ErrNoKey
+	}
 	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
 
-		return []byte(TOKEN_Key), nil
+		return signingKey, nil
 	})
 
 	if err != nil {
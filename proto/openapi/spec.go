@@ -0,0 +1,11 @@
+package openapi
+
+import (
+	_ "embed"
+)
+
+// Spec is the OpenAPI document generated from the HTTP annotations of the
+// auth, user and loan services.
+//
+//go:embed api.swagger.json
+var Spec []byte